@@ -7,13 +7,16 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/automation-platform/agent/internal/agent"
 	"github.com/automation-platform/agent/internal/centrifugo"
 	"github.com/automation-platform/agent/internal/controlplane"
+	"github.com/automation-platform/agent/internal/logs"
 	"github.com/yogzblr/probe"
+	"github.com/yogzblr/probe/facts"
 )
 
 func main() {
@@ -24,6 +27,14 @@ func main() {
 	projectID := getEnv("PROJECT_ID", "")
 	agentID := getEnv("AGENT_ID", generateAgentID())
 	jwtToken := getEnv("JWT_TOKEN", "")
+	quickwitURL := getEnv("QUICKWIT_URL", "")
+	quickwitIndex := getEnv("QUICKWIT_INDEX", "automation-logs")
+	secretsDir := getEnv("SECRETS_DIR", "")
+	workflowLibrary := getEnv("WORKFLOW_LIBRARY", "")
+	pluginDir := getEnv("PLUGIN_DIR", "")
+	trustedKeysDir := getEnv("TRUSTED_KEYS_DIR", "")
+	artifactCacheDir := getEnv("ARTIFACT_CACHE_DIR", "")
+	artifactCacheMaxMB := getEnv("ARTIFACT_CACHE_MAX_MB", "")
 	
 	if tenantID == "" || projectID == "" || jwtToken == "" {
 		log.Fatal("TENANT_ID, PROJECT_ID, and JWT_TOKEN are required")
@@ -59,6 +70,60 @@ func main() {
 	// Initialize probe executor with all built-in tasks
 	probeExecutor := probe.New()
 	
+	// Secrets are looked up in local files, then the environment, then the control plane
+	if secretsDir != "" {
+		probeExecutor.RegisterSecretResolver(probe.FileSecrets{Dir: secretsDir})
+	}
+	probeExecutor.RegisterSecretResolver(probe.EnvSecrets{Prefix: "PROBE_SECRET_"})
+	probeExecutor.RegisterSecretResolver(probe.SecretResolverFunc(cpClient.GetSecret))
+	
+	// Task types provided by plugin executables; broken plugins are skipped
+	if pluginDir != "" {
+		if err := probeExecutor.LoadPlugins(context.Background(), pluginDir); err != nil {
+			log.Printf("[Agent] Failed to load some plugins: %v", err)
+		}
+	}
+	
+	// Keys trusted to sign downloadexec artifacts, and where verified artifacts are kept
+	if trustedKeysDir != "" {
+		if err := probeExecutor.LoadKeyring(trustedKeysDir); err != nil {
+			log.Fatalf("Failed to load trusted keys: %v", err)
+		}
+	}
+	if artifactCacheDir != "" {
+		probeExecutor.SetArtifactCache(artifactCacheDir)
+	}
+	if artifactCacheMaxMB != "" {
+		maxMB, err := strconv.ParseInt(artifactCacheMaxMB, 10, 64)
+		if err != nil || maxMB <= 0 {
+			log.Fatalf("Invalid ARTIFACT_CACHE_MAX_MB: %q", artifactCacheMaxMB)
+		}
+		probeExecutor.SetArtifactCacheSize(maxMB << 20)
+	}
+	
+	// Host facts are reported as inventory and visible to workflows as agent.facts
+	hostFacts, err := facts.Gather()
+	if err != nil {
+		log.Printf("[Agent] Failed to gather some host facts: %v", err)
+	}
+	probeExecutor.SetFacts(hostFacts)
+	
+	// Shared workflows runnable by name from workflow tasks
+	if workflowLibrary != "" {
+		if err := probeExecutor.LoadWorkflows(workflowLibrary); err != nil {
+			log.Fatalf("Failed to load workflow library: %v", err)
+		}
+	}
+	
+	// Job progress is streamed to Quickwit when it is configured
+	var logClient *logs.Client
+	if quickwitURL != "" {
+		logClient = logs.NewClient(logs.Config{
+			URL:   quickwitURL,
+			Index: quickwitIndex,
+		})
+	}
+	
 	// Start agent
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -73,6 +138,7 @@ func main() {
 		ProjectID: projectID,
 		OS:        osName,
 		Labels:    make(map[string]interface{}),
+		Inventory: hostFacts,
 	}); err != nil {
 		log.Fatalf("Failed to register agent: %v", err)
 	}
@@ -88,6 +154,7 @@ func main() {
 		agent:         ag,
 		cpClient:      cpClient,
 		probeExecutor: probeExecutor,
+		logClient:     logClient,
 	}
 
 	// IMPORTANT: Set up message handlers BEFORE connecting
@@ -133,6 +200,7 @@ type MessageHandler struct {
 	agent         *agent.Agent
 	cpClient      *controlplane.Client
 	probeExecutor *probe.Probe
+	logClient     *logs.Client
 }
 
 func (h *MessageHandler) HandleJobAvailable(jobID string) {
@@ -179,10 +247,18 @@ func (h *MessageHandler) HandleJobAvailable(jobID string) {
 		return
 	}
 	
-	log.Printf("[Agent] Decoded workflow YAML: %s", workflowYAML)
+	// The workflow is not logged as it may carry credentials
+	log.Printf("[Agent] Decoded workflow for job %s (%d bytes)", job.JobID, len(workflowYAML))
 	
-	// Execute workflow using probe
-	results, err := h.probeExecutor.ExecuteYAML(ctx, []byte(workflowYAML))
+	// Execute workflow using probe, reporting task progress as it runs
+	observer := logs.NewJobObserver(h.logClient, logs.LogEntry{
+		JobID:     job.JobID,
+		AgentID:   h.agent.ID,
+		TenantID:  h.agent.TenantID,
+		ProjectID: h.agent.ProjectID,
+	})
+	results, err := h.probeExecutor.ExecuteYAML(probe.WithObserver(ctx, observer), []byte(workflowYAML))
+	observer.Close()
 	
 	// Complete job
 	success := err == nil && results.Success
@@ -8,7 +8,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"time"
+
+	"github.com/yogzblr/probe"
+	"github.com/yogzblr/probe/facts"
 )
 
 // Client provides HTTPS client for control plane API
@@ -46,6 +50,7 @@ type RegisterAgentRequest struct {
 	ProjectID string                 `json:"project_id"`
 	OS        string                 `json:"os"`
 	Labels    map[string]interface{} `json:"labels"`
+	Inventory *facts.Facts           `json:"inventory,omitempty"`
 }
 
 // RegisterAgent registers the agent with the control plane
@@ -166,3 +171,42 @@ func (c *Client) CompleteJob(ctx context.Context, jobID string, success bool) er
 	log.Printf("[ControlPlaneClient] Successfully completed job %s", jobID)
 	return nil
 }
+
+// SecretResponse represents a resolved project secret
+type SecretResponse struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GetSecret resolves a secret of the agent's project. Use it as a
+// probe.SecretResolverFunc to make control plane secrets available to workflows.
+func (c *Client) GetSecret(ctx context.Context, name string) (string, error) {
+	url := fmt.Sprintf("%s/api/secrets/%s", c.baseURL, neturl.PathEscape(name))
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: not defined in the control plane", probe.ErrSecretNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("secret lookup failed: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var secret SecretResponse
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return secret.Value, nil
+}
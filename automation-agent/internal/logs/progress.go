@@ -0,0 +1,227 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yogzblr/probe"
+)
+
+const (
+	// progressBatchSize is the number of entries sent to Quickwit at once
+	progressBatchSize = 100
+
+	// progressFlushInterval is how long entries wait before being sent
+	progressFlushInterval = time.Second
+
+	// progressQueueSize bounds the entries waiting to be sent; further entries
+	// are dropped so that a slow Quickwit never blocks the workflow
+	progressQueueSize = 10000
+)
+
+// JobObserver reports the progress of a job's workflow as it runs. Task starts
+// and ends are written to the agent log; when a Quickwit client is set, every
+// event, including task output lines, is also streamed to Quickwit.
+type JobObserver struct {
+	client *Client
+	base   LogEntry
+
+	entries chan LogEntry
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// mu guards closed, so that tasks still writing output after the workflow
+	// ended never send on the closed entries channel
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewJobObserver creates an observer for one job. The base entry carries the
+// job, agent, tenant and project IDs added to every log entry. Call Close when
+// the workflow has finished.
+func NewJobObserver(client *Client, base LogEntry) *JobObserver {
+	o := &JobObserver{
+		client:  client,
+		base:    base,
+		entries: make(chan LogEntry, progressQueueSize),
+		done:    make(chan struct{}),
+	}
+	if client != nil {
+		go o.ship()
+	} else {
+		close(o.done)
+	}
+	return o
+}
+
+// OnWorkflowStart logs the start of the workflow
+func (o *JobObserver) OnWorkflowStart(event probe.WorkflowEvent) {
+	log.Printf("[Job %s] Workflow %s started", o.base.JobID, event.Workflow)
+	o.send(event.Time, "info", fmt.Sprintf("Workflow %s started", event.Workflow), map[string]string{
+		"event":    "workflow_start",
+		"workflow": event.Workflow,
+	})
+}
+
+// OnTaskStart logs the start of a task
+func (o *JobObserver) OnTaskStart(event probe.TaskEvent) {
+	name := taskName(event.Task, event.Index)
+	log.Printf("[Job %s] Task %s (%s) started", o.base.JobID, name, event.Type)
+	o.send(event.Time, "info", fmt.Sprintf("Task %s started", name), taskFields("task_start", event))
+}
+
+// OnTaskOutput streams a line of task output
+func (o *JobObserver) OnTaskOutput(event probe.TaskOutputEvent) {
+	fields := map[string]string{
+		"event":    "task_output",
+		"workflow": event.Workflow,
+		"task":     event.Task,
+		"stream":   event.Stream,
+	}
+	if event.Index != nil {
+		fields["index"] = strconv.Itoa(*event.Index)
+	}
+	level := "info"
+	if event.Stream == "stderr" {
+		level = "warn"
+	}
+	o.send(event.Time, level, event.Line, fields)
+}
+
+// OnTaskEnd logs the result of a task
+func (o *JobObserver) OnTaskEnd(event probe.TaskEvent) {
+	name := taskName(event.Task, event.Index)
+	status := event.Result.Status
+	level := "info"
+	message := fmt.Sprintf("Task %s %s in %s", name, status, event.Duration.Round(time.Millisecond))
+	if event.Result.Error != "" {
+		level = "error"
+		message += ": " + event.Result.Error
+	}
+	log.Printf("[Job %s] %s", o.base.JobID, message)
+
+	fields := taskFields("task_end", event)
+	fields["status"] = string(status)
+	fields["duration_ms"] = strconv.FormatInt(event.Duration.Milliseconds(), 10)
+	o.send(event.Time, level, message, fields)
+}
+
+// OnWorkflowEnd logs the outcome of the workflow
+func (o *JobObserver) OnWorkflowEnd(event probe.WorkflowEvent) {
+	level := "info"
+	outcome := "succeeded"
+	if !event.Result.Success {
+		level = "error"
+		outcome = "failed"
+	}
+	message := fmt.Sprintf("Workflow %s %s in %s", event.Workflow, outcome, event.Duration.Round(time.Millisecond))
+	log.Printf("[Job %s] %s", o.base.JobID, message)
+	o.send(event.Time, level, message, map[string]string{
+		"event":       "workflow_end",
+		"workflow":    event.Workflow,
+		"success":     strconv.FormatBool(event.Result.Success),
+		"duration_ms": strconv.FormatInt(event.Duration.Milliseconds(), 10),
+	})
+}
+
+// Close sends the remaining entries to Quickwit and waits until they are sent.
+// Entries sent afterwards, by tasks abandoned at a timeout, are dropped.
+func (o *JobObserver) Close() {
+	o.mu.Lock()
+	if o.client != nil && !o.closed {
+		close(o.entries)
+	}
+	o.closed = true
+	o.mu.Unlock()
+	<-o.done
+	if n := o.dropped.Load(); n > 0 {
+		log.Printf("[Job %s] Dropped %d progress log entries", o.base.JobID, n)
+	}
+}
+
+// send queues an entry for Quickwit without blocking
+func (o *JobObserver) send(t time.Time, level, message string, fields map[string]string) {
+	if o.client == nil {
+		return
+	}
+	entry := o.base
+	entry.Timestamp = t.UTC().Format(time.RFC3339Nano)
+	entry.Level = level
+	entry.Message = message
+	entry.Fields = fields
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.entries <- entry:
+	default:
+		o.dropped.Add(1)
+	}
+}
+
+// ship sends queued entries to Quickwit in batches
+func (o *JobObserver) ship() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(progressFlushInterval)
+	defer ticker.Stop()
+
+	var batch []LogEntry
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := o.client.StreamBatch(ctx, batch); err != nil {
+			log.Printf("[Job %s] Failed to stream progress to Quickwit: %v", o.base.JobID, err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case entry, ok := <-o.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= progressBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// taskName names a task, including the instance index of looped tasks
+func taskName(task string, index *int) string {
+	if index != nil {
+		return fmt.Sprintf("%s[%d]", task, *index)
+	}
+	return task
+}
+
+// taskFields returns the log fields identifying a task
+func taskFields(kind string, event probe.TaskEvent) map[string]string {
+	fields := map[string]string{
+		"event":    kind,
+		"workflow": event.Workflow,
+		"task":     event.Task,
+		"type":     event.Type,
+	}
+	if event.Index != nil {
+		fields["index"] = strconv.Itoa(*event.Index)
+	}
+	return fields
+}
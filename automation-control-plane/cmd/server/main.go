@@ -14,6 +14,7 @@ import (
 	"github.com/automation-platform/control-plane/internal/centrifugo"
 	"github.com/automation-platform/control-plane/internal/store/mysql"
 	"github.com/automation-platform/control-plane/internal/store/redis"
+	"github.com/yogzblr/probe"
 )
 
 func main() {
@@ -25,7 +26,9 @@ func main() {
 	jwtSecret := getEnv("JWT_SECRET", "change-me-in-production")
 	centrifugoURL := getEnv("CENTRIFUGO_URL", "http://localhost:8000")
 	centrifugoAPIKey := getEnv("CENTRIFUGO_API_KEY", "change-me-in-production")
+	secretsKey := getEnv("SECRETS_KEY", "change-me-in-production")
 	port := getEnv("PORT", "8080")
+	pluginDir := getEnv("PLUGIN_DIR", "")
 
 	// Initialize MySQL store
 	mysqlStore, err := mysql.NewStore(ctx, mysql.Config{
@@ -69,11 +72,23 @@ func main() {
 	}
 	rbacAuthorizer := auth.NewRBACAuthorizer(projectRolesGetter)
 
+	// Workflows are validated against the built-in tasks and the same plugins as the agents
+	workflowProbe := probe.New()
+	if pluginDir != "" {
+		if err := workflowProbe.LoadPlugins(ctx, pluginDir); err != nil {
+			log.Printf("Failed to load some plugins: %v", err)
+		}
+	}
+
 	// Initialize API handlers
-	jobsHandler := api.NewJobsHandler(mysqlStore, rbacAuthorizer, centrifugoClient)
+	jobsHandler := api.NewJobsHandler(mysqlStore, rbacAuthorizer, centrifugoClient, workflowProbe)
 	projectsHandler := api.NewProjectsHandler(mysqlStore, rbacAuthorizer)
 	agentsHandler := api.NewAgentsHandler(mysqlStore, rbacAuthorizer)
 	auditHandler := api.NewAuditHandler(mysqlStore, rbacAuthorizer)
+	secretsHandler, err := api.NewSecretsHandler(mysqlStore, rbacAuthorizer, secretsKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize secrets handler: %v", err)
+	}
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -126,6 +141,7 @@ func main() {
 		}
 	})
 	apiMux.HandleFunc("/audit/logs", auditHandler.ListAuditLogs)
+	apiMux.HandleFunc("/secrets/", secretsHandler.HandleSecret)
 
 	// Apply auth middleware
 	handler := auth.AuthMiddleware(jwtValidator)(apiMux)
@@ -29,6 +29,7 @@ type RegisterAgentRequest struct {
 	ProjectID string          `json:"project_id"`
 	OS        string          `json:"os"`
 	Labels    json.RawMessage `json:"labels"`
+	Inventory json.RawMessage `json:"inventory"`
 }
 
 // RegisterAgent handles POST /agents/register
@@ -64,6 +65,7 @@ func (h *AgentsHandler) RegisterAgent(w http.ResponseWriter, r *http.Request) {
 		ProjectID: req.ProjectID,
 		OS:        &req.OS,
 		Labels:    req.Labels,
+		Inventory: req.Inventory,
 	}
 
 	if err := h.store.CreateOrUpdateAgent(r.Context(), qb, agent); err != nil {
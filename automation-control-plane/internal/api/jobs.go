@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/automation-platform/control-plane/internal/centrifugo"
 	"github.com/automation-platform/control-plane/internal/store/mysql"
 	"github.com/google/uuid"
+	"github.com/yogzblr/probe"
 )
 
 // JobsHandler handles job-related API requests
@@ -18,14 +20,17 @@ type JobsHandler struct {
 	store            *mysql.Store
 	authorizer       *auth.RBACAuthorizer
 	centrifugoClient *centrifugo.Client
+	probe            *probe.Probe
 }
 
-// NewJobsHandler creates a new jobs handler
-func NewJobsHandler(store *mysql.Store, authorizer *auth.RBACAuthorizer, centrifugoClient *centrifugo.Client) *JobsHandler {
+// NewJobsHandler creates a new jobs handler. Workflows are validated against
+// the task types registered on the given probe.
+func NewJobsHandler(store *mysql.Store, authorizer *auth.RBACAuthorizer, centrifugoClient *centrifugo.Client, workflowProbe *probe.Probe) *JobsHandler {
 	return &JobsHandler{
 		store:            store,
 		authorizer:       authorizer,
 		centrifugoClient: centrifugoClient,
+		probe:            workflowProbe,
 	}
 }
 
@@ -65,6 +70,13 @@ func (h *JobsHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject invalid workflows before they reach an agent
+	payload, err := h.validateWorkflow(req.Workflow)
+	if err != nil {
+		http.Error(w, "invalid workflow:\n"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get authorized projects for query builder
 	authorizedProjects, err := h.authorizer.GetAuthorizedProjects(r.Context(), claims)
 	if err != nil {
@@ -87,7 +99,7 @@ func (h *JobsHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		TenantID:  tenantID,
 		ProjectID: req.ProjectID,
 		State:     "pending",
-		Payload:   req.Workflow,
+		Payload:   payload,
 	}
 
 	if err := h.store.CreateJob(r.Context(), qb, job); err != nil {
@@ -123,6 +135,30 @@ func (h *JobsHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// validateWorkflow checks a submitted workflow with probe without running it,
+// and returns the job payload. The workflow is normally a YAML document encoded
+// as a JSON string; a JSON object is stored as a string of its JSON, which is
+// valid YAML, since agents expect a string.
+func (h *JobsHandler) validateWorkflow(workflow json.RawMessage) (json.RawMessage, error) {
+	if len(workflow) == 0 || string(workflow) == "null" {
+		return nil, fmt.Errorf("workflow is required")
+	}
+
+	var text string
+	if err := json.Unmarshal(workflow, &text); err != nil {
+		var object map[string]interface{}
+		if err := json.Unmarshal(workflow, &object); err != nil {
+			return nil, fmt.Errorf("workflow must be a YAML string or an object")
+		}
+		text = string(workflow)
+	}
+
+	if err := h.probe.ValidateYAML([]byte(text)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(text)
+}
+
 // LeaseJobRequest represents a job lease request
 type LeaseJobRequest struct {
 	AgentID string `json:"agent_id"`
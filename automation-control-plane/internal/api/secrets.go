@@ -0,0 +1,235 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/automation-platform/control-plane/internal/auth"
+	"github.com/automation-platform/control-plane/internal/store/mysql"
+)
+
+// secretNamePattern restricts secret names to what workflows can reference
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// maxSecretSize bounds the size of a secret value
+const maxSecretSize = 8 * 1024
+
+// SecretsHandler handles project secrets. Values are encrypted at rest and
+// only ever returned to agents of the secret's project.
+type SecretsHandler struct {
+	store      *mysql.Store
+	authorizer *auth.RBACAuthorizer
+	aead       cipher.AEAD
+}
+
+// NewSecretsHandler creates a new secrets handler encrypting values with a
+// key derived from the given passphrase
+func NewSecretsHandler(store *mysql.Store, authorizer *auth.RBACAuthorizer, key string) (*SecretsHandler, error) {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return &SecretsHandler{
+		store:      store,
+		authorizer: authorizer,
+		aead:       aead,
+	}, nil
+}
+
+// PutSecretRequest represents a secret update request
+type PutSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// SecretResponse represents a resolved secret
+type SecretResponse struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HandleSecret routes /secrets/{name} requests
+func (h *SecretsHandler) HandleSecret(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/secrets/")
+	if !secretNamePattern.MatchString(name) {
+		http.Error(w, "invalid secret name", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.GetSecret(w, r, name)
+	case http.MethodPut:
+		h.PutSecret(w, r, name)
+	case http.MethodDelete:
+		h.DeleteSecret(w, r, name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GetSecret handles GET /secrets/{name}. Only agents may read secret values,
+// and only those of their own project.
+func (h *SecretsHandler) GetSecret(w http.ResponseWriter, r *http.Request, name string) {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing claims", http.StatusInternalServerError)
+		return
+	}
+
+	if claims.AgentID == "" {
+		http.Error(w, "only agents can read secrets", http.StatusForbidden)
+		return
+	}
+
+	if err := h.authorizer.Authorize(r.Context(), claims, claims.ProjectID, auth.PermissionJobRead); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	qb := mysql.NewQueryBuilder(claims.TenantID, []string{claims.ProjectID})
+
+	secret, err := h.store.GetSecret(r.Context(), qb, claims.ProjectID, name)
+	if errors.Is(err, mysql.ErrSecretNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	value, err := h.decrypt(secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(SecretResponse{Name: name, Value: value})
+}
+
+// PutSecret handles PUT /secrets/{name}?project_id=...
+func (h *SecretsHandler) PutSecret(w http.ResponseWriter, r *http.Request, name string) {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing claims", http.StatusInternalServerError)
+		return
+	}
+
+	projectID := r.URL.Query().Get("project_id")
+	if projectID == "" {
+		http.Error(w, "project_id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authorizer.Authorize(r.Context(), claims, projectID, auth.PermissionProjectAdmin); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req PutSecretRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*maxSecretSize)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Value) > maxSecretSize {
+		http.Error(w, fmt.Sprintf("secret value exceeds %d bytes", maxSecretSize), http.StatusBadRequest)
+		return
+	}
+
+	secret := &mysql.Secret{
+		TenantID:  claims.TenantID,
+		ProjectID: projectID,
+		Name:      name,
+	}
+	value, err := h.encrypt(secret, req.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	secret.Value = value
+
+	qb := mysql.NewQueryBuilder(claims.TenantID, []string{projectID})
+	if err := h.store.PutSecret(r.Context(), qb, secret); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSecret handles DELETE /secrets/{name}?project_id=...
+func (h *SecretsHandler) DeleteSecret(w http.ResponseWriter, r *http.Request, name string) {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing claims", http.StatusInternalServerError)
+		return
+	}
+
+	projectID := r.URL.Query().Get("project_id")
+	if projectID == "" {
+		http.Error(w, "project_id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authorizer.Authorize(r.Context(), claims, projectID, auth.PermissionProjectAdmin); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	qb := mysql.NewQueryBuilder(claims.TenantID, []string{projectID})
+	err := h.store.DeleteSecret(r.Context(), qb, projectID, name)
+	if errors.Is(err, mysql.ErrSecretNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// secretAAD binds an encrypted value to its tenant, project and name, so that
+// it cannot be moved to another secret
+func secretAAD(secret *mysql.Secret) []byte {
+	return []byte(secret.TenantID + "\x00" + secret.ProjectID + "\x00" + secret.Name)
+}
+
+// encrypt seals a secret value, prefixed with its nonce
+func (h *SecretsHandler) encrypt(secret *mysql.Secret, value string) ([]byte, error) {
+	nonce := make([]byte, h.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return h.aead.Seal(nonce, nonce, []byte(value), secretAAD(secret)), nil
+}
+
+// decrypt opens a secret value sealed by encrypt
+func (h *SecretsHandler) decrypt(secret *mysql.Secret) (string, error) {
+	n := h.aead.NonceSize()
+	if len(secret.Value) < n {
+		return "", fmt.Errorf("failed to decrypt secret: value too short")
+	}
+	value, err := h.aead.Open(nil, secret.Value[:n], secret.Value[n:], secretAAD(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(value), nil
+}
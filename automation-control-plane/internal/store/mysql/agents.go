@@ -15,6 +15,9 @@ type Agent struct {
 	ProjectID string
 	OS        *string
 	Labels    json.RawMessage
+	// Inventory holds the host facts the agent reported when it registered;
+	// it is empty for agents that registered before reporting facts
+	Inventory json.RawMessage
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -25,14 +28,15 @@ func (s *Store) CreateOrUpdateAgent(ctx context.Context, qb *QueryBuilder, agent
 		return err
 	}
 
-	query := `INSERT INTO agents (agent_id, tenant_id, project_id, os, labels, created_at, updated_at)
-	          VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+	query := `INSERT INTO agents (agent_id, tenant_id, project_id, os, labels, inventory, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())
 	          ON DUPLICATE KEY UPDATE
 	          os = VALUES(os),
 	          labels = VALUES(labels),
+	          inventory = VALUES(inventory),
 	          updated_at = NOW()`
 	
-	_, err := s.db.ExecContext(ctx, query, agent.AgentID, agent.TenantID, agent.ProjectID, agent.OS, agent.Labels)
+	_, err := s.db.ExecContext(ctx, query, agent.AgentID, agent.TenantID, agent.ProjectID, agent.OS, agent.Labels, agent.Inventory)
 	if err != nil {
 		return fmt.Errorf("failed to create/update agent: %w", err)
 	}
@@ -45,15 +49,16 @@ func (s *Store) GetAgent(ctx context.Context, qb *QueryBuilder, agentID string)
 	where, args := qb.BuildWhereClause("agent_id = ?")
 	args = append([]interface{}{agentID}, args...)
 	
-	query := fmt.Sprintf(`SELECT agent_id, tenant_id, project_id, os, labels, created_at, updated_at
+	query := fmt.Sprintf(`SELECT agent_id, tenant_id, project_id, os, labels, inventory, created_at, updated_at
 	                     FROM agents WHERE %s`, where)
 	
 	var agent Agent
 	var os sql.NullString
+	var inventory []byte
 	
 	err := s.db.QueryRowContext(ctx, query, args...).Scan(
 		&agent.AgentID, &agent.TenantID, &agent.ProjectID,
-		&os, &agent.Labels, &agent.CreatedAt, &agent.UpdatedAt,
+		&os, &agent.Labels, &inventory, &agent.CreatedAt, &agent.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("agent not found")
@@ -65,6 +70,7 @@ func (s *Store) GetAgent(ctx context.Context, qb *QueryBuilder, agentID string)
 	if os.Valid {
 		agent.OS = &os.String
 	}
+	agent.Inventory = inventory
 	
 	return &agent, nil
 }
@@ -79,7 +85,7 @@ func (s *Store) ListAgents(ctx context.Context, qb *QueryBuilder, projectID stri
 		args = append(args, cursor)
 	}
 	
-	query := fmt.Sprintf(`SELECT agent_id, tenant_id, project_id, os, labels, created_at, updated_at
+	query := fmt.Sprintf(`SELECT agent_id, tenant_id, project_id, os, labels, inventory, created_at, updated_at
 	                     FROM agents WHERE %s ORDER BY agent_id LIMIT ?`, where)
 	args = append(args, limit+1)
 	
@@ -95,10 +101,11 @@ func (s *Store) ListAgents(ctx context.Context, qb *QueryBuilder, projectID stri
 	for rows.Next() {
 		var agent Agent
 		var os sql.NullString
+		var inventory []byte
 		
 		err := rows.Scan(
 			&agent.AgentID, &agent.TenantID, &agent.ProjectID,
-			&os, &agent.Labels, &agent.CreatedAt, &agent.UpdatedAt,
+			&os, &agent.Labels, &inventory, &agent.CreatedAt, &agent.UpdatedAt,
 		)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to scan agent: %w", err)
@@ -107,6 +114,7 @@ func (s *Store) ListAgents(ctx context.Context, qb *QueryBuilder, projectID stri
 		if os.Valid {
 			agent.OS = &os.String
 		}
+		agent.Inventory = inventory
 		
 		if len(agents) < limit {
 			agents = append(agents, &agent)
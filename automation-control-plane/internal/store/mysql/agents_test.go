@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestAgentsWithoutInventory(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE agents (
+		agent_id TEXT, tenant_id TEXT, project_id TEXT, os TEXT,
+		labels JSON, inventory JSON, created_at DATETIME, updated_at DATETIME)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An agent registered before inventory was reported
+	_, err = db.Exec(`INSERT INTO agents VALUES ('a1', 't1', 'p1', 'linux', X'7B7D', NULL, '2026-01-10 12:00:00', '2026-01-10 12:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &Store{db: db}
+	qb := NewQueryBuilder("t1", []string{"p1"})
+	agent, err := store.GetAgent(context.Background(), qb, "a1")
+	if err != nil {
+		t.Fatalf("GetAgent failed: %v", err)
+	}
+	if agent.Inventory != nil {
+		t.Errorf("Expected no inventory, got %s", agent.Inventory)
+	}
+
+	agents, _, err := store.ListAgents(context.Background(), qb, "p1", 10, "")
+	if err != nil {
+		t.Fatalf("ListAgents failed: %v", err)
+	}
+	if len(agents) != 1 || agents[0].Inventory != nil {
+		t.Errorf("Expected one agent without inventory, got %v", agents)
+	}
+}
@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSecretNotFound is returned when a project has no secret with a name
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Secret represents a project secret in the database. Value holds the
+// encrypted secret value.
+type Secret struct {
+	TenantID  string
+	ProjectID string
+	Name      string
+	Value     []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PutSecret creates or replaces a secret
+func (s *Store) PutSecret(ctx context.Context, qb *QueryBuilder, secret *Secret) error {
+	if err := qb.ValidateTenantProject(secret.ProjectID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO secrets (tenant_id, project_id, name, value, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, NOW(), NOW())
+	          ON DUPLICATE KEY UPDATE
+	          value = VALUES(value),
+	          updated_at = NOW()`
+
+	_, err := s.db.ExecContext(ctx, query, secret.TenantID, secret.ProjectID, secret.Name, secret.Value)
+	if err != nil {
+		return fmt.Errorf("failed to put secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetSecret retrieves a secret of a project by name
+func (s *Store) GetSecret(ctx context.Context, qb *QueryBuilder, projectID, name string) (*Secret, error) {
+	where, args := qb.BuildWhereClause("project_id = ? AND name = ?")
+	args = append([]interface{}{projectID, name}, args...)
+
+	query := fmt.Sprintf(`SELECT tenant_id, project_id, name, value, created_at, updated_at
+	                     FROM secrets WHERE %s`, where)
+
+	var secret Secret
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&secret.TenantID, &secret.ProjectID, &secret.Name, &secret.Value,
+		&secret.CreatedAt, &secret.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrSecretNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	return &secret, nil
+}
+
+// DeleteSecret deletes a secret of a project
+func (s *Store) DeleteSecret(ctx context.Context, qb *QueryBuilder, projectID, name string) error {
+	where, args := qb.BuildWhereClause("project_id = ? AND name = ?")
+	args = append([]interface{}{projectID, name}, args...)
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM secrets WHERE %s", where), args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+-- Migration: Add project secrets
+-- Description: Stores secrets that agents resolve for workflows with {{ secret "name" }}
+-- Date: 2026-10-17
+
+-- Values are encrypted by the control plane with SECRETS_KEY (AES-256-GCM)
+CREATE TABLE IF NOT EXISTS secrets (
+  tenant_id VARCHAR(64) NOT NULL,
+  project_id VARCHAR(64) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  value VARBINARY(16384) NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (tenant_id, project_id, name),
+  FOREIGN KEY (tenant_id) REFERENCES tenants(tenant_id) ON DELETE CASCADE,
+  FOREIGN KEY (project_id) REFERENCES projects(project_id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- Migration: Add agent inventory
+-- Description: Stores the host facts agents report when they register
+-- Date: 2026-10-17
+
+-- OS, distribution, CPUs, memory, disks, network interfaces and uptime
+ALTER TABLE agents
+ADD COLUMN inventory JSON
+AFTER labels;
@@ -0,0 +1,248 @@
+package probe
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveFormats are the supported archive formats by file suffix
+var archiveFormats = map[string]string{
+	".tar.gz": "tar.gz",
+	".tgz":    "tar.gz",
+	".zip":    "zip",
+}
+
+// archiveFormat detects the archive format of a file name, or returns ""
+func archiveFormat(name string) string {
+	for suffix, format := range archiveFormats {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return format
+		}
+	}
+	return ""
+}
+
+// archiveExtractor writes the entries of an archive below dir, refusing
+// entries outside of it and stopping once more than remaining bytes are written
+type archiveExtractor struct {
+	dir       string
+	remaining int64
+
+	// links are the extracted symlinks, checked once all entries are written
+	links []string
+}
+
+// extractArchive extracts a tar.gz or zip archive into dir
+func extractArchive(format, path, dir string, limit int64) error {
+	x := &archiveExtractor{dir: dir, remaining: limit}
+	var err error
+	switch format {
+	case "tar.gz":
+		err = x.tarGz(path)
+	case "zip":
+		err = x.zip(path)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return x.checkLinks()
+}
+
+func (x *archiveExtractor) tarGz(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("invalid tar.gz archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar.gz archive: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.dirEntry(hdr.Name)
+		case tar.TypeReg:
+			err = x.fileEntry(hdr.Name, tr, os.FileMode(hdr.Mode).Perm())
+		case tar.TypeSymlink:
+			err = x.symlinkEntry(hdr.Name, hdr.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *archiveExtractor) zip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if err := x.zipEntry(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) zipEntry(f *zip.File) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return x.dirEntry(f.Name)
+	}
+	if !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer r.Close()
+	if mode&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(r, 4096))
+		if err != nil {
+			return fmt.Errorf("invalid zip archive: %w", err)
+		}
+		return x.symlinkEntry(f.Name, string(target))
+	}
+	return x.fileEntry(f.Name, r, mode.Perm())
+}
+
+// path returns the extraction path of an archive entry. Entries below or at
+// an extracted symlink are refused, since writing them would follow the link.
+func (x *archiveExtractor) path(name string) (string, error) {
+	path := filepath.Join(x.dir, filepath.FromSlash(name))
+	if !isWithin(x.dir, path) {
+		return "", fmt.Errorf("archive entry %s is outside of the archive", name)
+	}
+	rel, _ := filepath.Rel(x.dir, path)
+	if x.throughSymlink(x.dir, rel) {
+		return "", fmt.Errorf("archive entry %s is outside of the archive through a symlink", name)
+	}
+	return path, nil
+}
+
+// throughSymlink reports whether an existing component of rel below base is a
+// symlink. Components are walked without cleaning, so that "link/.." counts.
+func (x *archiveExtractor) throughSymlink(base, rel string) bool {
+	current := base
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			return false
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLinks makes sure no extracted symlink resolves outside of the archive,
+// as links made of other links can only be resolved once all are written
+func (x *archiveExtractor) checkLinks() error {
+	dir, err := filepath.EvalSymlinks(x.dir)
+	if err != nil {
+		return err
+	}
+	for _, link := range x.links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		if !isWithin(dir, target) {
+			rel, _ := filepath.Rel(x.dir, link)
+			return fmt.Errorf("archive entry %s links outside of the archive", filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) dirEntry(name string) error {
+	path, err := x.path(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+func (x *archiveExtractor) fileEntry(name string, r io.Reader, mode os.FileMode) error {
+	path, err := x.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(file, io.LimitReader(r, x.remaining+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	x.remaining -= n
+	if x.remaining < 0 {
+		return fmt.Errorf("extracted archive exceeds max_size")
+	}
+	return nil
+}
+
+func (x *archiveExtractor) symlinkEntry(name, target string) error {
+	path, err := x.path(name)
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(target) || !isWithin(x.dir, filepath.Join(filepath.Dir(path), target)) ||
+		x.throughSymlink(filepath.Dir(path), target) {
+		return fmt.Errorf("archive entry %s links outside of the archive", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, path); err != nil {
+		return err
+	}
+	x.links = append(x.links, path)
+	return nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
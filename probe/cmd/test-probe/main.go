@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -11,12 +13,35 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: test-probe <workflow.yaml>")
+	validateOnly := flag.Bool("validate", false, "validate the workflow without executing it")
+	printSchema := flag.Bool("schema", false, "print the workflow JSON Schema and exit")
+	pluginDir := flag.String("plugins", "", "load task plugins from this directory")
+	flag.Parse()
+
+	// Create probe instance
+	p := probe.New()
+	if *pluginDir != "" {
+		if err := p.LoadPlugins(context.Background(), *pluginDir); err != nil {
+			log.Printf("Failed to load plugins: %v", err)
+		}
+	}
+
+	if *printSchema {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(p.Schema()); err != nil {
+			log.Fatalf("Failed to encode schema: %v", err)
+		}
+		return
+	}
+
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: test-probe [-plugins dir] [-validate] <workflow.yaml>")
+		fmt.Println("       test-probe [-plugins dir] -schema")
 		os.Exit(1)
 	}
 
-	workflowFile := os.Args[1]
+	workflowFile := flag.Arg(0)
 
 	// Read workflow file
 	data, err := os.ReadFile(workflowFile)
@@ -24,32 +49,35 @@ func main() {
 		log.Fatalf("Failed to read workflow file: %v", err)
 	}
 
-	fmt.Printf("=== Testing Workflow: %s ===\n\n", filepath.Base(workflowFile))
+	if *validateOnly {
+		if err := p.ValidateYAML(data); err != nil {
+			fmt.Printf("%s is invalid:\n%v\n", filepath.Base(workflowFile), err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", filepath.Base(workflowFile))
+		return
+	}
 
-	// Create probe instance
-	p := probe.New()
+	fmt.Printf("=== Testing Workflow: %s ===\n\n", filepath.Base(workflowFile))
 
 	// Execute workflow
 	result, err := p.ExecuteYAML(context.Background(), data)
+	if result == nil {
+		log.Fatalf("Workflow Error: %v", err)
+	}
 
 	// Print results
 	fmt.Printf("Workflow: %s\n", result.Name)
 	fmt.Printf("Overall Success: %v\n\n", result.Success)
 
 	for i, task := range result.Tasks {
-		fmt.Printf("Task %d: %s (%s)\n", i+1, task.Name, task.Type)
-		if task.Success {
-			fmt.Printf("  ✓ SUCCESS\n")
-		} else {
-			fmt.Printf("  ✗ FAILED\n")
-			if task.Error != "" {
-				fmt.Printf("  Error: %s\n", task.Error)
-			}
-		}
-		if task.Output != nil {
-			fmt.Printf("  Output: %+v\n", task.Output)
-		}
-		fmt.Println()
+		fmt.Printf("Task %d: %s (%s)\n", i+1, taskLabel(task), task.Type)
+		printTask(task)
+	}
+
+	for i, task := range result.Finally {
+		fmt.Printf("Finally %d: %s (%s)\n", i+1, taskLabel(task), task.Type)
+		printTask(task)
 	}
 
 	if err != nil {
@@ -63,3 +91,31 @@ func main() {
 
 	fmt.Println("=== All tasks completed successfully ===")
 }
+
+// taskLabel names a task result, including the instance index of looped tasks
+func taskLabel(task probe.TaskResult) string {
+	if task.Index != nil {
+		return fmt.Sprintf("%s[%d]", task.Name, *task.Index)
+	}
+	return task.Name
+}
+
+func printTask(task probe.TaskResult) {
+	switch {
+	case task.Success:
+		fmt.Printf("  ✓ SUCCESS\n")
+	case task.Status == probe.StatusSkipped:
+		fmt.Printf("  - SKIPPED\n")
+	case task.Status == probe.StatusTimedOut:
+		fmt.Printf("  ✗ TIMED OUT\n")
+	default:
+		fmt.Printf("  ✗ FAILED\n")
+	}
+	if !task.Success && task.Error != "" {
+		fmt.Printf("  Error: %s\n", task.Error)
+	}
+	if task.Output != nil {
+		fmt.Printf("  Output: %+v\n", task.Output)
+	}
+	fmt.Println()
+}
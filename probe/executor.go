@@ -0,0 +1,680 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// execution holds the state of a single workflow run
+type execution struct {
+	workflow    *Workflow
+	graph       *taskGraph
+	factories   []TaskFactory
+	maxParallel int
+	timeout     time.Duration
+
+	// finallyFactories create the tasks of the workflow's finally block
+	finallyFactories []TaskFactory
+
+	// agent describes the host running the workflow, for when conditions
+	agent map[string]interface{}
+
+	// outcome summarises the main tasks once they are done, for finally tasks
+	outcome map[string]interface{}
+
+	// timedOut is set when the workflow deadline interrupted the run
+	timedOut bool
+
+	// vars holds the workflow variables after their templates were resolved
+	vars map[string]interface{}
+
+	// observers are notified of task progress; ended is set once the run is over
+	observers observerList
+	ended     atomic.Bool
+
+	// secrets resolves the secret template function and redacts the values
+	// it returned from results and output
+	secrets *secretStore
+
+	// mu guards tasks, which records finished tasks for templates by name, and
+	// outcome, read by tasks abandoned at a timeout that may still be running
+	mu    sync.RWMutex
+	tasks map[string]interface{}
+}
+
+// newScope returns the template scope visible to the next task: workflow
+// variables, environment variables, the results of finished tasks and, for
+// looped tasks, the current item
+func (e *execution) newScope(item *loopItem) *scope {
+	e.mu.RLock()
+	tasks := make(map[string]interface{}, len(e.tasks))
+	for name, state := range e.tasks {
+		tasks[name] = state
+	}
+	outcome := e.outcome
+	e.mu.RUnlock()
+
+	s := &scope{
+		values: map[string]interface{}{
+			"vars":  e.vars,
+			"env":   envScope{},
+			"tasks": tasks,
+			"agent": e.agent,
+		},
+		funcs: map[string]exprFunc{"secret": e.secrets.secret},
+	}
+	if outcome != nil {
+		s.values["workflow"] = outcome
+	}
+	if item != nil {
+		s.values["item"] = item.value
+		s.values["index"] = item.index
+	}
+	return s
+}
+
+// agentInfo describes the host running the workflow, with its facts when
+// they were set
+func agentInfo(facts map[string]interface{}) map[string]interface{} {
+	hostname, _ := os.Hostname()
+	info := map[string]interface{}{
+		"os":       runtime.GOOS,
+		"arch":     runtime.GOARCH,
+		"hostname": hostname,
+	}
+	if facts != nil {
+		info["facts"] = facts
+	}
+	return info
+}
+
+// recordTask makes a finished task's results available to later templates. For a
+// looped task, output holds the list of instance outputs in item order.
+func (e *execution) recordTask(taskDef TaskDefinition, results []TaskResult) {
+	if taskDef.Name == "" {
+		return
+	}
+
+	state := map[string]interface{}{}
+	if !taskDef.looped() && len(results) == 1 {
+		r := results[0]
+		state["output"] = r.Output
+		state["success"] = r.Success
+		state["status"] = string(r.Status)
+		state["error"] = r.Error
+	} else {
+		outputs := make([]interface{}, len(results))
+		success := true
+		status := StatusSuccess
+		errMsg := ""
+		for i, r := range results {
+			outputs[i] = r.Output
+			if !r.Success && r.Status != StatusSkipped {
+				success = false
+				status = StatusFailed
+				if errMsg == "" {
+					errMsg = r.Error
+				}
+			}
+		}
+		state["output"] = outputs
+		state["success"] = success
+		state["status"] = string(status)
+		state["error"] = errMsg
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tasks[taskDef.Name] = state
+}
+
+// resolveVars renders the workflow variables, which may reference the environment
+// and secrets. Inputs replace the variables of the same name as they are, without
+// rendering templates.
+func resolveVars(vars, inputs map[string]interface{}, secrets *secretStore) (map[string]interface{}, error) {
+	if len(inputs) > 0 {
+		defaults := make(map[string]interface{}, len(vars))
+		for name, value := range vars {
+			if _, ok := inputs[name]; !ok {
+				defaults[name] = value
+			}
+		}
+		vars = defaults
+	}
+
+	resolved, err := renderConfig(vars, &scope{
+		values: map[string]interface{}{"env": envScope{}},
+		funcs:  map[string]exprFunc{"secret": secrets.secret},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resolved == nil {
+		resolved = make(map[string]interface{})
+	}
+	for name, value := range inputs {
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+// loopItem is the item a looped task instance runs for
+type loopItem struct {
+	index int
+	value interface{}
+}
+
+// taskInstance is a single run of a task definition. Tasks with a loop or matrix
+// expand into one instance per item; other tasks have exactly one instance.
+type taskInstance struct {
+	task int
+	item *loopItem
+}
+
+// key identifies an instance within a run
+func (t taskInstance) key() [2]int {
+	if t.item == nil {
+		return [2]int{t.task, -1}
+	}
+	return [2]int{t.task, t.item.index}
+}
+
+// taskOutcome is reported by a task goroutine once the task has finished
+type taskOutcome struct {
+	instance taskInstance
+	result   TaskResult
+	err      error
+}
+
+// taskProgress tracks the instances of one task definition during a run
+type taskProgress struct {
+	// expanded is set once the task's dependencies are done and its instances queued
+	expanded bool
+
+	// results holds the result of each instance, nil until it finished
+	results []*TaskResult
+
+	// remaining counts the instances that have not finished yet
+	remaining int
+
+	// failed is set when an instance failed without continue_on_error
+	failed bool
+}
+
+// errWorkflowTimeout is the cancellation cause used when the workflow deadline fires
+var errWorkflowTimeout = errors.New("workflow timeout exceeded")
+
+// run schedules the workflow tasks in dependency order. When all dependencies of a
+// task are done, it is expanded into its instances, which are started in declaration
+// order, at most maxParallel at a time. After the first failure of a task without
+// continue_on_error no new instances are started; instances already running are
+// allowed to finish. When the workflow timeout fires, running instances are cancelled
+// and reported as timed out without waiting for them, and instances that never
+// started are reported as skipped.
+func (e *execution) run(ctx context.Context) ([]TaskResult, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, e.timeout, errWorkflowTimeout)
+		defer cancel()
+	}
+
+	pending := make([]int, len(e.graph.dependencies))
+	copy(pending, e.graph.dependencies)
+
+	progress := make([]taskProgress, len(e.workflow.Tasks))
+	running := make(map[[2]int]bool)
+	var ready []taskInstance
+	var firstErr error
+
+	fail := func(i int, err error) {
+		if e.workflow.Tasks[i].ContinueOnError {
+			return
+		}
+		progress[i].failed = true
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// complete records a finished task and releases the tasks waiting on it
+	var release func(i int)
+	complete := func(i int) {
+		results := make([]TaskResult, 0, len(progress[i].results))
+		for _, r := range progress[i].results {
+			results = append(results, *r)
+		}
+		e.recordTask(e.workflow.Tasks[i], results)
+
+		if progress[i].failed {
+			return
+		}
+		for _, j := range e.graph.dependents[i] {
+			pending[j]--
+			if pending[j] == 0 {
+				release(j)
+			}
+		}
+	}
+
+	// release expands a task whose dependencies are done and queues its instances
+	release = func(i int) {
+		taskDef := e.workflow.Tasks[i]
+		p := &progress[i]
+		p.expanded = true
+
+		items, err := e.expand(taskDef)
+		if err != nil {
+			label := fmt.Sprintf("task %d (%s)", i, taskDef.Name)
+			p.results = []*TaskResult{{
+				Name:   taskDef.Name,
+				Type:   taskDef.Type,
+				Status: StatusFailed,
+				Error:  err.Error(),
+			}}
+			e.notifyTaskEnd(p.results[0])
+			fail(i, fmt.Errorf("%s: %w", label, err))
+			complete(i)
+			return
+		}
+
+		p.results = make([]*TaskResult, len(items))
+		p.remaining = len(items)
+		for _, item := range items {
+			ready = append(ready, taskInstance{task: i, item: item})
+		}
+		sort.SliceStable(ready, func(a, b int) bool {
+			return ready[a].task < ready[b].task
+		})
+
+		if len(items) == 0 {
+			complete(i)
+		}
+	}
+
+	for _, i := range e.graph.roots() {
+		release(i)
+	}
+
+	// Buffered so that instances abandoned on timeout can still report and exit
+	done := make(chan taskOutcome, e.maxParallel)
+
+	for !e.timedOut {
+		for firstErr == nil && ctx.Err() == nil && len(running) < e.maxParallel && len(ready) > 0 {
+			inst := ready[0]
+			ready = ready[1:]
+			running[inst.key()] = true
+			e.notifyTaskStart(e.workflow.Tasks[inst.task], inst.item)
+			go func(inst taskInstance) {
+				taskDef := e.workflow.Tasks[inst.task]
+				outcome := e.runTask(ctx, instanceLabel("task", inst.task, taskDef, inst.item), taskDef, e.factories[inst.task], inst.item)
+				outcome.instance = inst
+				done <- outcome
+			}(inst)
+		}
+
+		if len(running) == 0 {
+			break
+		}
+
+		var outcome taskOutcome
+		select {
+		case outcome = <-done:
+		case <-ctx.Done():
+			if context.Cause(ctx) != errWorkflowTimeout {
+				// Cancelled by the caller: wait for the tasks to observe it
+				outcome = <-done
+				break
+			}
+			e.timedOut = true
+			continue
+		}
+
+		inst := outcome.instance
+		delete(running, inst.key())
+		if outcome.err != nil && context.Cause(ctx) == errWorkflowTimeout {
+			outcome.result.Status = StatusTimedOut
+		}
+
+		e.notifyTaskEnd(&outcome.result)
+
+		p := &progress[inst.task]
+		p.results[instancePosition(inst)] = &outcome.result
+		p.remaining--
+		if outcome.err != nil {
+			fail(inst.task, outcome.err)
+		}
+		if p.remaining == 0 {
+			complete(inst.task)
+		}
+	}
+
+	// The deadline or a cancellation may also land between tasks, leaving
+	// the rest unstarted
+	unfinished := false
+	for _, p := range progress {
+		if !p.expanded || p.remaining > 0 {
+			unfinished = true
+			break
+		}
+	}
+	if unfinished && context.Cause(ctx) == errWorkflowTimeout {
+		e.timedOut = true
+	}
+	if e.timedOut {
+		firstErr = fmt.Errorf("workflow timed out after %s", e.timeout)
+	} else if unfinished && firstErr == nil && ctx.Err() != nil {
+		firstErr = context.Cause(ctx)
+	}
+
+	// Report results in declaration order rather than completion order,
+	// including the instances that were interrupted or never started
+	var taskResults []TaskResult
+	for i, p := range progress {
+		taskDef := e.workflow.Tasks[i]
+		if !p.expanded {
+			r := TaskResult{
+				Name:   taskDef.Name,
+				Type:   taskDef.Type,
+				Status: StatusSkipped,
+			}
+			e.notifyTaskEnd(&r)
+			taskResults = append(taskResults, r)
+			continue
+		}
+
+		for k, r := range p.results {
+			if r == nil {
+				inst := taskInstance{task: i}
+				r = &TaskResult{
+					Name:   taskDef.Name,
+					Type:   taskDef.Type,
+					Status: StatusSkipped,
+				}
+				if taskDef.looped() {
+					inst.item = &loopItem{index: k}
+					r.Index = intPtr(k)
+				}
+				if running[inst.key()] {
+					r.Status = StatusTimedOut
+					r.Error = errWorkflowTimeout.Error()
+				}
+				e.notifyTaskEnd(r)
+			}
+			taskResults = append(taskResults, *r)
+		}
+	}
+
+	return taskResults, firstErr
+}
+
+// notifyTaskStart tells the observers that a task instance is starting
+func (e *execution) notifyTaskStart(taskDef TaskDefinition, item *loopItem) {
+	event := TaskEvent{
+		Workflow: e.workflow.Name,
+		Task:     taskDef.Name,
+		Type:     taskDef.Type,
+		Time:     time.Now(),
+	}
+	if item != nil {
+		event.Index = intPtr(item.index)
+	}
+	e.observers.taskStart(event)
+}
+
+// notifyTaskEnd redacts secrets from the result of a task instance and tells
+// the observers about it
+func (e *execution) notifyTaskEnd(result *TaskResult) {
+	e.secrets.redactResult(result)
+	e.observers.taskEnd(TaskEvent{
+		Workflow: e.workflow.Name,
+		Task:     result.Name,
+		Type:     result.Type,
+		Index:    result.Index,
+		Time:     time.Now(),
+		Duration: result.Duration,
+		Result:   result,
+	})
+}
+
+// expand resolves the instances of a task definition. Tasks without a loop or
+// matrix have a single instance with a nil item.
+func (e *execution) expand(taskDef TaskDefinition) ([]*loopItem, error) {
+	var values []interface{}
+	switch {
+	case taskDef.Loop != nil:
+		var err error
+		values, err = taskDef.Loop.items(e.newScope(nil))
+		if err != nil {
+			return nil, err
+		}
+	case taskDef.Matrix != nil:
+		values = matrixItems(taskDef.Matrix)
+	default:
+		return []*loopItem{nil}, nil
+	}
+	if len(values) > maxInstances {
+		return nil, fmt.Errorf("loop has %d items, more than the limit of %d", len(values), maxInstances)
+	}
+
+	items := make([]*loopItem, len(values))
+	for i, v := range values {
+		items[i] = &loopItem{index: i, value: v}
+	}
+	return items, nil
+}
+
+// instancePosition returns the position of an instance among its task's instances
+func instancePosition(inst taskInstance) int {
+	if inst.item == nil {
+		return 0
+	}
+	return inst.item.index
+}
+
+// instanceLabel names a task instance in error messages
+func instanceLabel(kind string, i int, taskDef TaskDefinition, item *loopItem) string {
+	if item == nil {
+		return fmt.Sprintf("%s %d (%s)", kind, i, taskDef.Name)
+	}
+	return fmt.Sprintf("%s %d (%s[%d])", kind, i, taskDef.Name, item.index)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// runTask evaluates the when condition of a task instance, then configures and executes it
+func (e *execution) runTask(ctx context.Context, label string, taskDef TaskDefinition, factory TaskFactory, item *loopItem) (outcome taskOutcome) {
+	outcome.result = TaskResult{
+		Name: taskDef.Name,
+		Type: taskDef.Type,
+	}
+	if item != nil {
+		outcome.result.Index = intPtr(item.index)
+		outcome.result.Item = item.value
+	}
+
+	start := time.Now()
+	out := &taskOutput{
+		observers: e.observers,
+		workflow:  e.workflow.Name,
+		task:      taskDef.Name,
+		index:     outcome.result.Index,
+		ended:     &e.ended,
+		secrets:   e.secrets,
+		writers:   make(map[string]*lineWriter),
+	}
+	ctx = context.WithValue(ctx, outputKey{}, out)
+	defer func() {
+		out.flush()
+		outcome.result.Duration = time.Since(start)
+	}()
+
+	if taskDef.When != "" {
+		run, err := evalCondition(taskDef.When, e.newScope(item))
+		if err != nil {
+			outcome.result.Status = StatusFailed
+			outcome.result.Error = err.Error()
+			outcome.err = fmt.Errorf("%s: failed to evaluate when: %w", label, err)
+			return outcome
+		}
+		if !run {
+			outcome.result.Status = StatusSkipped
+			return outcome
+		}
+	}
+
+	// Resolve templates against the outputs of the tasks finished so far
+	config, err := renderConfig(taskDef.Config, e.newScope(item))
+	if err != nil {
+		outcome.result.Status = StatusFailed
+		outcome.result.Error = err.Error()
+		outcome.err = fmt.Errorf("%s: failed to render config: %w", label, err)
+		return outcome
+	}
+
+	// Create and configure task instance
+	task := factory()
+	if err := task.Configure(config); err != nil {
+		outcome.result.Status = StatusFailed
+		outcome.result.Error = err.Error()
+		outcome.err = fmt.Errorf("%s: failed to configure: %w", label, err)
+		return outcome
+	}
+
+	if scoped, ok := task.(scopedTask); ok {
+		scoped.setScope(e.newScope(item))
+	}
+
+	output, err := e.executeWithRetry(ctx, taskDef, task, item, &outcome.result)
+	outcome.result.Output = output
+	if nested, ok := task.(nestedWorkflow); ok {
+		outcome.result.Workflow = nested.nestedResult()
+	}
+	if err != nil {
+		outcome.result.Status = StatusFailed
+		outcome.result.Error = err.Error()
+		outcome.err = fmt.Errorf("%s: %w", label, err)
+		return outcome
+	}
+
+	outcome.result.Status = StatusSuccess
+	outcome.result.Success = true
+	return outcome
+}
+
+// runFinally runs the finally tasks one by one after the main tasks, whatever
+// their outcome. Every finally task runs even if an earlier one fails.
+func (e *execution) runFinally(ctx context.Context, runErr error) ([]TaskResult, error) {
+	outcome := map[string]interface{}{
+		"name":      e.workflow.Name,
+		"success":   runErr == nil,
+		"timed_out": e.timedOut,
+		"error":     toString(runErr),
+	}
+	e.mu.Lock()
+	e.outcome = outcome
+	e.mu.Unlock()
+
+	var results []TaskResult
+	var firstErr error
+	for i, taskDef := range e.workflow.Finally {
+		items, err := e.expand(taskDef)
+		if err != nil {
+			r := TaskResult{
+				Name:   taskDef.Name,
+				Type:   taskDef.Type,
+				Status: StatusFailed,
+				Error:  err.Error(),
+			}
+			e.notifyTaskEnd(&r)
+			results = append(results, r)
+			if !taskDef.ContinueOnError && firstErr == nil {
+				firstErr = fmt.Errorf("finally task %d (%s): %w", i, taskDef.Name, err)
+			}
+			continue
+		}
+
+		taskResults := make([]TaskResult, 0, len(items))
+		for _, item := range items {
+			e.notifyTaskStart(taskDef, item)
+			outcome := e.runTask(ctx, instanceLabel("finally task", i, taskDef, item), taskDef, e.finallyFactories[i], item)
+			e.notifyTaskEnd(&outcome.result)
+			taskResults = append(taskResults, outcome.result)
+
+			if outcome.err != nil && !taskDef.ContinueOnError && firstErr == nil {
+				firstErr = outcome.err
+			}
+		}
+		e.recordTask(taskDef, taskResults)
+		results = append(results, taskResults...)
+	}
+
+	return results, firstErr
+}
+
+// whenRoots are the values visible to a when condition
+var whenRoots = []string{"vars", "env", "tasks", "agent", "workflow", "item", "index"}
+
+// retryUntilRoots are the values visible to a retry_until condition
+var retryUntilRoots = []string{"vars", "env", "tasks", "agent", "item", "index", "output", "attempt"}
+
+// executeWithRetry runs a configured task according to its retry policy, recording
+// every attempt. An attempt fails when the task returns an error or when its
+// retry_until condition does not hold.
+func (e *execution) executeWithRetry(ctx context.Context, taskDef TaskDefinition, task Task, item *loopItem, result *TaskResult) (interface{}, error) {
+	// The policy was validated when the workflow was parsed
+	schedule, _ := taskDef.Retry.schedule()
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		output, err := task.Execute(ctx)
+
+		if err == nil && taskDef.RetryUntil != "" {
+			err = e.checkRetryUntil(taskDef.RetryUntil, item, output, attempt)
+		}
+
+		record := Attempt{
+			Number:   attempt,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		result.Attempts = append(result.Attempts, record)
+
+		if err == nil || attempt >= schedule.attempts {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return output, err
+		}
+
+		if sleepErr := sleep(ctx, schedule.wait(attempt)); sleepErr != nil {
+			return output, err
+		}
+	}
+}
+
+// checkRetryUntil evaluates a retry_until condition against an attempt's output
+func (e *execution) checkRetryUntil(condition string, item *loopItem, output interface{}, attempt int) error {
+	s := e.newScope(item)
+	s.values["output"] = output
+	s.values["attempt"] = attempt
+
+	ok, err := evalCondition(condition, s)
+	if err != nil {
+		return fmt.Errorf("retry_until: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("retry_until condition not met: %s", conditionSource(condition))
+	}
+	return nil
+}
@@ -0,0 +1,922 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Expressions are used inside {{ ... }} templates. The syntax is deliberately small:
+// dotted paths into the evaluation scope, literals, comparisons, boolean logic and
+// pipes into helper functions, for example:
+//
+//	tasks.login.output.body | json "token"
+//	tasks.check.output.status_code == 200 and vars.region in ["eu", "us"]
+
+// exprFunc is a helper function callable from expressions. When used in a pipe,
+// the piped value is passed as the last argument.
+type exprFunc func(args []interface{}) (interface{}, error)
+
+// builtinFuncs are the helper functions available to every expression
+var builtinFuncs = map[string]exprFunc{
+	"json":     funcJSON,
+	"tojson":   funcToJSON,
+	"default":  funcDefault,
+	"upper":    stringFunc(strings.ToUpper),
+	"lower":    stringFunc(strings.ToLower),
+	"trim":     stringFunc(strings.TrimSpace),
+	"string":   funcString,
+	"int":      funcInt,
+	"len":      funcLen,
+	"contains": funcContains,
+	"split":    funcSplit,
+	"secret":   funcSecret,
+}
+
+// scope holds the root values and extra functions visible to an expression
+type scope struct {
+	values map[string]interface{}
+	funcs  map[string]exprFunc
+}
+
+// lookupFunc returns the named function, preferring scope-specific functions
+func (s *scope) lookupFunc(name string) (exprFunc, bool) {
+	if fn, ok := s.funcs[name]; ok {
+		return fn, true
+	}
+	fn, ok := builtinFuncs[name]
+	return fn, ok
+}
+
+// envScope exposes environment variables as env.NAME
+type envScope struct{}
+
+// evalExpr parses and evaluates a single expression
+func evalExpr(src string, s *scope) (interface{}, error) {
+	n, err := parseExpr(src, s)
+	if err != nil {
+		return nil, err
+	}
+	return n.eval(s)
+}
+
+// evalCondition evaluates a condition such as `output.status_code == 200`.
+// The expression may optionally be wrapped in {{ }}.
+func evalCondition(src string, s *scope) (bool, error) {
+	v, err := evalExpr(conditionSource(src), s)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// checkCondition parses a condition without evaluating it, given the names of
+// the root values that will be available when it runs
+func checkCondition(src string, roots ...string) error {
+	s := &scope{values: make(map[string]interface{}, len(roots))}
+	for _, root := range roots {
+		s.values[root] = nil
+	}
+	_, err := parseExpr(conditionSource(src), s)
+	return err
+}
+
+func conditionSource(src string) string {
+	src = strings.TrimSpace(src)
+	if strings.HasPrefix(src, "{{") && strings.HasSuffix(src, "}}") {
+		src = src[2 : len(src)-2]
+	}
+	return src
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators are matched longest first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "|", ".", ",", "(", ")", "[", "]"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var sb strings.Builder
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' && end+1 < len(src) {
+					end++
+					switch src[end] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[end])
+					}
+				} else {
+					sb.WriteByte(src[end])
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: i})
+			i = end + 1
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1])):
+			// A number following "." is a list index, so it cannot contain a decimal point
+			afterDot := len(tokens) > 0 && tokens[len(tokens)-1].text == "."
+			end := i + 1
+			for end < len(src) && (isDigit(src[end]) || (src[end] == '.' && !afterDot && end+1 < len(src) && isDigit(src[end+1]))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[i:end], pos: i})
+			i = end
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(src) && (isIdentStart(src[end]) || isDigit(src[end]) || src[end] == '-') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+	scope  *scope
+}
+
+func parseExpr(src string, s *scope) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, scope: s}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(texts ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at position %d, got %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parsePipe() (node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("|"); !ok {
+			return left, nil
+		}
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, fmt.Errorf("expected function name after | at position %d", tok.pos)
+		}
+		call, err := p.parseCall(tok)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, left)
+		left = call
+	}
+}
+
+// parseCall parses the space-separated arguments of a function call
+func (p *parser) parseCall(name token) (*callNode, error) {
+	if _, ok := p.scope.lookupFunc(name.text); !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+	call := &callNode{name: name.text}
+	for p.startsOperand() {
+		arg, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	return call, nil
+}
+
+// startsOperand reports whether the next token can begin a function argument
+func (p *parser) startsOperand() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case tokString, tokNumber:
+		return true
+	case tokIdent:
+		return !isKeyword(tok.text)
+	case tokOp:
+		return tok.text == "(" || tok.text == "["
+	}
+	return false
+}
+
+func isKeyword(text string) bool {
+	switch text {
+	case "and", "or", "not", "in":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); ok {
+			tok := p.next()
+			if tok.kind != tokIdent && tok.kind != tokNumber {
+				return nil, fmt.Errorf("expected field name after . at position %d", tok.pos)
+			}
+			n = &indexNode{target: n, index: &literalNode{value: tok.text}}
+			continue
+		}
+		if _, ok := p.accept("["); ok {
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{target: n, index: index}
+			continue
+		}
+		return n, nil
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokNumber:
+		if strings.Contains(tok.text, ".") {
+			f, err := strconv.ParseFloat(tok.text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+			}
+			return &literalNode{value: f}, nil
+		}
+		i, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: i}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if _, isVar := p.scope.values[tok.text]; !isVar {
+			if _, isFunc := p.scope.lookupFunc(tok.text); isFunc {
+				return p.parseCall(tok)
+			}
+		}
+		return &identNode{name: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			list := &listNode{}
+			for {
+				if _, ok := p.accept("]"); ok {
+					return list, nil
+				}
+				if len(list.items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// AST
+
+type node interface {
+	eval(s *scope) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(s *scope) (interface{}, error) {
+	return n.value, nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) eval(s *scope) (interface{}, error) {
+	v, ok := s.values[n.name]
+	if !ok {
+		return nil, fmt.Errorf("undefined variable %q", n.name)
+	}
+	return v, nil
+}
+
+type indexNode struct {
+	target node
+	index  node
+}
+
+func (n *indexNode) eval(s *scope) (interface{}, error) {
+	target, err := n.target.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return lookupValue(target, index), nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(s *scope) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(s *scope) (interface{}, error) {
+	fn, _ := s.lookupFunc(n.name)
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return v, nil
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(s *scope) (interface{}, error) {
+	v, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(s *scope) (interface{}, error) {
+	left, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit boolean operators
+	switch n.op {
+	case "and":
+		if !truthy(left) {
+			return false, nil
+		}
+	case "or":
+		if truthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := n.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "and", "or":
+		return truthy(right), nil
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "in":
+		return containsValue(right, left), nil
+	}
+
+	cmp, err := compareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// Value helpers
+
+// lookupValue returns the field or element of v named by key, or nil if absent
+func lookupValue(v interface{}, key interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if _, ok := v.(envScope); ok {
+		value, _ := os.LookupEnv(toString(key))
+		return value
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		elem := rv.MapIndex(reflect.ValueOf(toString(key)).Convert(rv.Type().Key()))
+		if !elem.IsValid() {
+			return nil
+		}
+		return elem.Interface()
+	case reflect.Slice, reflect.Array:
+		i, ok := toInt(key)
+		if !ok || i < 0 || i >= rv.Len() {
+			return nil
+		}
+		return rv.Index(i).Interface()
+	case reflect.Struct:
+		name := toString(key)
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+			if tag == name || strings.EqualFold(field.Name, name) {
+				return rv.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// truthy reports whether v counts as true in a condition
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// valuesEqual compares two values, treating numbers and numeric strings alike
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	if _, ok := a.(bool); ok {
+		return a == b
+	}
+	return toString(a) == toString(b)
+}
+
+// compareValues orders two numbers or two strings
+func compareValues(a, b interface{}) (int, error) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, nil
+			case fa > fb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	sa, aok := a.(string)
+	sb, bok := b.(string)
+	if !aok || !bok {
+		return 0, fmt.Errorf("cannot compare %T and %T", a, b)
+	}
+	return strings.Compare(sa, sb), nil
+}
+
+// containsValue reports whether a list, map or string contains v
+func containsValue(container, v interface{}) bool {
+	if s, ok := container.(string); ok {
+		return strings.Contains(s, toString(v))
+	}
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if valuesEqual(rv.Index(i).Interface(), v) {
+				return true
+			}
+		}
+	case reflect.Map:
+		return lookupValue(container, v) != nil
+	}
+	return false
+}
+
+// toFloat converts numbers and numeric strings to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toInt converts whole numbers and numeric strings to int
+func toInt(v interface{}) (int, bool) {
+	f, ok := toFloat(v)
+	if !ok || f != float64(int(f)) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// toString renders a value for use inside a larger string
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(x)
+	case error:
+		return x.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// Builtin functions
+
+// funcJSON parses a JSON string and optionally extracts a dotted path from it
+func funcJSON(args []interface{}) (interface{}, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("expected a value and an optional path")
+	}
+	value := args[len(args)-1]
+	if s, ok := value.(string); ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		value = parsed
+	}
+	if len(args) == 2 {
+		for _, key := range strings.Split(toString(args[0]), ".") {
+			if key != "" {
+				value = lookupValue(value, key)
+			}
+		}
+	}
+	return value, nil
+}
+
+func funcToJSON(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument")
+	}
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// funcDefault returns the fallback when the value is nil or an empty string
+func funcDefault(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected a fallback and a value")
+	}
+	if args[1] == nil || args[1] == "" {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+func stringFunc(fn func(string) string) exprFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument")
+		}
+		return fn(toString(args[0])), nil
+	}
+}
+
+func funcString(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument")
+	}
+	return toString(args[0]), nil
+}
+
+func funcInt(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument")
+	}
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %v to int", args[0])
+	}
+	return int(f), nil
+}
+
+func funcLen(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument")
+	}
+	if args[0] == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(args[0])
+	switch rv.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len(), nil
+	}
+	return nil, fmt.Errorf("cannot take length of %T", args[0])
+}
+
+// funcContains reports whether the last argument contains the first, so that
+// `output.body | contains "ok"` reads naturally
+func funcContains(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected 2 arguments")
+	}
+	return containsValue(args[1], args[0]), nil
+}
+
+func funcSplit(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected a separator and a value")
+	}
+	parts := strings.Split(toString(args[1]), toString(args[0]))
+	list := make([]interface{}, len(parts))
+	for i, part := range parts {
+		list[i] = part
+	}
+	return list, nil
+}
@@ -0,0 +1,182 @@
+// Package facts gathers information about the host without external tools:
+// operating system and distribution, CPUs, memory, disks, network interfaces
+// and uptime. Linux and Windows report every fact; other systems report those
+// the Go runtime knows about.
+package facts
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+)
+
+// Facts describes a host
+type Facts struct {
+	Hostname      string      `json:"hostname"`
+	OS            string      `json:"os"`
+	Arch          string      `json:"arch"`
+	Kernel        string      `json:"kernel"`
+	Distro        Distro      `json:"distro"`
+	CPUs          int         `json:"cpus"`
+	Memory        Memory      `json:"memory"`
+	Disks         []Disk      `json:"disks"`
+	Interfaces    []Interface `json:"interfaces"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+
+	// IPs are the addresses of the interfaces that are up, except loopback
+	IPs []string `json:"ips"`
+}
+
+// Distro identifies the operating system distribution, such as ubuntu 24.04
+// or Windows Server 2022
+type Distro struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Memory is the physical memory of the host
+type Memory struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+// Disk is a mounted filesystem, or a drive on Windows
+type Disk struct {
+	Mount       string  `json:"mount"`
+	Device      string  `json:"device"`
+	FSType      string  `json:"fstype"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// Interface is a network interface and its addresses in CIDR notation
+type Interface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	Loopback  bool     `json:"loopback"`
+	Addresses []string `json:"addresses"`
+}
+
+// Gather collects the facts of the host. Facts that cannot be read are left
+// empty and reported in the error, so the others remain usable.
+func Gather() (*Facts, error) {
+	f := &Facts{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		CPUs: runtime.NumCPU(),
+	}
+
+	var errs []error
+	hostname, err := os.Hostname()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	f.Hostname = hostname
+
+	if err := gatherInterfaces(f); err != nil {
+		errs = append(errs, err)
+	}
+	if err := gatherPlatform(f); err != nil {
+		errs = append(errs, err)
+	}
+	return f, errors.Join(errs...)
+}
+
+// gatherInterfaces lists the network interfaces and their addresses
+func gatherInterfaces(f *Facts) error {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	f.Interfaces = []Interface{}
+	f.IPs = []string{}
+	for _, iface := range interfaces {
+		info := Interface{
+			Name:      iface.Name,
+			MAC:       iface.HardwareAddr.String(),
+			MTU:       iface.MTU,
+			Up:        iface.Flags&net.FlagUp != 0,
+			Loopback:  iface.Flags&net.FlagLoopback != 0,
+			Addresses: []string{},
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			info.Addresses = append(info.Addresses, addr.String())
+			if ipnet, ok := addr.(*net.IPNet); ok && info.Up && !info.Loopback {
+				f.IPs = append(f.IPs, ipnet.IP.String())
+			}
+		}
+		f.Interfaces = append(f.Interfaces, info)
+	}
+	return nil
+}
+
+// usedPercent is the share of a disk in use, rounded to one decimal
+func usedPercent(total, free uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	used := float64(total-free) / float64(total) * 100
+	return float64(int(used*10+0.5)) / 10
+}
+
+// Map returns the facts as generic values, as seen by workflow expressions
+func (f *Facts) Map() map[string]interface{} {
+	disks := make([]interface{}, len(f.Disks))
+	for i, d := range f.Disks {
+		disks[i] = map[string]interface{}{
+			"mount":        d.Mount,
+			"device":       d.Device,
+			"fstype":       d.FSType,
+			"total_bytes":  d.TotalBytes,
+			"free_bytes":   d.FreeBytes,
+			"used_percent": d.UsedPercent,
+		}
+	}
+
+	interfaces := make([]interface{}, len(f.Interfaces))
+	for i, iface := range f.Interfaces {
+		interfaces[i] = map[string]interface{}{
+			"name":      iface.Name,
+			"mac":       iface.MAC,
+			"mtu":       iface.MTU,
+			"up":        iface.Up,
+			"loopback":  iface.Loopback,
+			"addresses": stringList(iface.Addresses),
+		}
+	}
+
+	return map[string]interface{}{
+		"hostname": f.Hostname,
+		"os":       f.OS,
+		"arch":     f.Arch,
+		"kernel":   f.Kernel,
+		"distro": map[string]interface{}{
+			"id":      f.Distro.ID,
+			"name":    f.Distro.Name,
+			"version": f.Distro.Version,
+		},
+		"cpus": f.CPUs,
+		"memory": map[string]interface{}{
+			"total_bytes":     f.Memory.TotalBytes,
+			"available_bytes": f.Memory.AvailableBytes,
+		},
+		"disks":          disks,
+		"interfaces":     interfaces,
+		"ips":            stringList(f.IPs),
+		"uptime_seconds": f.UptimeSeconds,
+	}
+}
+
+func stringList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
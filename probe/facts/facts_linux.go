@@ -0,0 +1,185 @@
+package facts
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// pseudoFilesystems are mounts that hold no disk space worth reporting
+var pseudoFilesystems = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true, "proc": true,
+	"pstore": true, "ramfs": true, "rpc_pipefs": true, "securityfs": true, "selinuxfs": true,
+	"squashfs": true, "sysfs": true, "tmpfs": true, "tracefs": true,
+}
+
+// networkFilesystems are skipped so that statfs on a stale mount cannot hang
+var networkFilesystems = map[string]bool{
+	"cifs": true, "fuse.sshfs": true, "nfs": true, "nfs4": true, "smb3": true, "smbfs": true,
+}
+
+// gatherPlatform reads the kernel, distribution, memory, disks and uptime
+func gatherPlatform(f *Facts) error {
+	var errs []error
+
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read kernel version: %w", err))
+	} else {
+		f.Kernel = unix.ByteSliceToString(uname.Release[:])
+	}
+
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		data, err = os.ReadFile("/usr/lib/os-release")
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to read os-release: %w", err))
+	} else {
+		f.Distro = parseOSRelease(data)
+	}
+
+	data, err = os.ReadFile("/proc/meminfo")
+	if err == nil {
+		f.Memory, err = parseMeminfo(data)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to read memory: %w", err))
+	}
+
+	data, err = os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to read mounts: %w", err))
+	} else {
+		f.Disks = statDisks(parseMounts(data))
+	}
+
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read uptime: %w", err))
+	} else {
+		f.UptimeSeconds = int64(info.Uptime)
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseOSRelease reads the distribution from an os-release file
+func parseOSRelease(data []byte) Distro {
+	var d Distro
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		switch key {
+		case "ID":
+			d.ID = value
+		case "PRETTY_NAME":
+			d.Name = value
+		case "NAME":
+			if d.Name == "" {
+				d.Name = value
+			}
+		case "VERSION_ID":
+			d.Version = value
+		}
+	}
+	return d
+}
+
+// parseMeminfo reads the total and available memory from /proc/meminfo
+func parseMeminfo(data []byte) (Memory, error) {
+	var m Memory
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			m.TotalBytes = kb * 1024
+		case "MemAvailable:":
+			m.AvailableBytes = kb * 1024
+		}
+	}
+	if m.TotalBytes == 0 {
+		return m, fmt.Errorf("MemTotal not found")
+	}
+	return m, nil
+}
+
+// parseMounts lists the local filesystems holding data, once per mount point
+func parseMounts(data []byte) []Disk {
+	var disks []Disk
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || pseudoFilesystems[fields[2]] || networkFilesystems[fields[2]] {
+			continue
+		}
+		mount := unescapeMount(fields[1])
+		if seen[mount] {
+			continue
+		}
+		seen[mount] = true
+		disks = append(disks, Disk{Mount: mount, Device: unescapeMount(fields[0]), FSType: fields[2]})
+	}
+	return disks
+}
+
+// unescapeMount decodes the octal escapes of spaces and tabs in /proc/mounts
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// statDisks fills in the size of each disk, dropping those that cannot be
+// read or hold no blocks
+func statDisks(disks []Disk) []Disk {
+	result := []Disk{}
+	for _, d := range disks {
+		var st unix.Statfs_t
+		if err := unix.Statfs(d.Mount, &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+		d.TotalBytes = st.Blocks * uint64(st.Bsize)
+		d.FreeBytes = st.Bavail * uint64(st.Bsize)
+		d.UsedPercent = usedPercent(d.TotalBytes, d.FreeBytes)
+		result = append(result, d)
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package facts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	d := parseOSRelease([]byte(`NAME="Ubuntu"
+VERSION_ID="24.04"
+ID=ubuntu
+PRETTY_NAME="Ubuntu 24.04.1 LTS"
+`))
+	if want := (Distro{ID: "ubuntu", Name: "Ubuntu 24.04.1 LTS", Version: "24.04"}); d != want {
+		t.Errorf("Expected %+v, got %+v", want, d)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	m, err := parseMeminfo([]byte("MemTotal:       16303412 kB\nMemFree:         1234 kB\nMemAvailable:    8151706 kB\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TotalBytes != 16303412*1024 || m.AvailableBytes != 8151706*1024 {
+		t.Errorf("Unexpected memory %+v", m)
+	}
+	if _, err := parseMeminfo([]byte("MemFree: 1 kB\n")); err == nil {
+		t.Errorf("Expected error without MemTotal")
+	}
+}
+
+func TestParseMounts(t *testing.T) {
+	disks := parseMounts([]byte(`proc /proc proc rw 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw 0 0
+/dev/sdb1 /mnt/my\040data xfs rw 0 0
+nas:/export /mnt/nas nfs4 rw 0 0
+//fs/share /mnt/share cifs rw 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+overlay /var/lib/docker/overlay2/x/merged overlay rw 0 0
+`))
+	want := []Disk{
+		{Mount: "/", Device: "/dev/sda1", FSType: "ext4"},
+		{Mount: "/mnt/my data", Device: "/dev/sdb1", FSType: "xfs"},
+		{Mount: "/var/lib/docker/overlay2/x/merged", Device: "overlay", FSType: "overlay"},
+	}
+	if !reflect.DeepEqual(disks, want) {
+		t.Errorf("Expected %+v, got %+v", want, disks)
+	}
+}
+
+func TestGather(t *testing.T) {
+	f, err := Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	if f.Hostname == "" || f.OS != "linux" || f.Kernel == "" || f.CPUs < 1 || f.Memory.TotalBytes == 0 || f.UptimeSeconds <= 0 {
+		t.Errorf("Expected host facts, got %+v", f)
+	}
+	if len(f.Disks) == 0 || f.Disks[0].TotalBytes == 0 {
+		t.Errorf("Expected disk sizes, got %+v", f.Disks)
+	}
+	m := f.Map()
+	if m["memory"].(map[string]interface{})["total_bytes"] != f.Memory.TotalBytes {
+		t.Errorf("Expected memory in map, got %v", m["memory"])
+	}
+}
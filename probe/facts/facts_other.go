@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package facts
+
+// gatherPlatform leaves the kernel, distribution, memory, disks and uptime
+// empty, as they are only read on Linux and Windows
+func gatherPlatform(f *Facts) error {
+	return nil
+}
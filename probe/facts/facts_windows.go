@@ -0,0 +1,102 @@
+package facts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+	procGetTickCount64       = kernel32.NewProc("GetTickCount64")
+)
+
+// memoryStatusEx is the MEMORYSTATUSEX structure
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+// gatherPlatform reads the kernel, edition, memory, drives and uptime
+func gatherPlatform(f *Facts) error {
+	var errs []error
+
+	version := windows.RtlGetVersion()
+	f.Kernel = fmt.Sprintf("%d.%d.%d", version.MajorVersion, version.MinorVersion, version.BuildNumber)
+	f.Distro = Distro{ID: "windows", Version: f.Kernel}
+	if key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE); err == nil {
+		f.Distro.Name, _, _ = key.GetStringValue("ProductName")
+		key.Close()
+	}
+
+	status := memoryStatusEx{Length: uint32(unsafe.Sizeof(memoryStatusEx{}))}
+	if ok, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		errs = append(errs, fmt.Errorf("failed to read memory: %w", err))
+	} else {
+		f.Memory = Memory{TotalBytes: status.TotalPhys, AvailableBytes: status.AvailPhys}
+	}
+
+	disks, err := drives()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list drives: %w", err))
+	}
+	f.Disks = disks
+
+	if ms, _, err := procGetTickCount64.Call(); ms == 0 {
+		errs = append(errs, fmt.Errorf("failed to read uptime: %w", err))
+	} else {
+		f.UptimeSeconds = int64(ms / 1000)
+	}
+
+	return errors.Join(errs...)
+}
+
+// drives lists the fixed drives and their sizes
+func drives() ([]Disk, error) {
+	buf := make([]uint16, 512)
+	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
+	if err != nil {
+		return nil, err
+	}
+
+	disks := []Disk{}
+	for _, root := range strings.Split(string(utf16.Decode(buf[:n])), "\x00") {
+		if root == "" {
+			continue
+		}
+		rootPtr, _ := windows.UTF16PtrFromString(root)
+		if windows.GetDriveType(rootPtr) != windows.DRIVE_FIXED {
+			continue
+		}
+
+		var available, total, free uint64
+		if err := windows.GetDiskFreeSpaceEx(rootPtr, &available, &total, &free); err != nil {
+			continue
+		}
+		fsName := make([]uint16, windows.MAX_PATH+1)
+		windows.GetVolumeInformation(rootPtr, nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName)))
+
+		disks = append(disks, Disk{
+			Mount:       root,
+			Device:      strings.TrimSuffix(root, `\`),
+			FSType:      windows.UTF16ToString(fsName),
+			TotalBytes:  total,
+			FreeBytes:   available,
+			UsedPercent: usedPercent(total, available),
+		})
+	}
+	return disks, nil
+}
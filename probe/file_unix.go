@@ -0,0 +1,61 @@
+//go:build !windows
+
+package probe
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ensureOwner sets the owner and group of path, each a name or numeric id,
+// and reports whether they changed. Like the content and mode, the owner of a
+// symlink's target is set rather than that of the link.
+func ensureOwner(path, owner, group string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("cannot read the owner of %s", path)
+	}
+	uid, gid := int(stat.Uid), int(stat.Gid)
+
+	if owner != "" {
+		u, err := lookupUser(owner)
+		if err != nil {
+			return false, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return false, fmt.Errorf("user %s has no numeric id", owner)
+		}
+	}
+	if group != "" {
+		g, err := lookupGroup(group)
+		if err != nil {
+			return false, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return false, fmt.Errorf("group %s has no numeric id", group)
+		}
+	}
+
+	if uid == int(stat.Uid) && gid == int(stat.Gid) {
+		return false, nil
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return false, fmt.Errorf("failed to set owner of %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// copyOwner gives path the owner and group of a file it replaces
+func copyOwner(from os.FileInfo, path string) error {
+	stat, ok := from.Sys().(*syscall.Stat_t)
+	if !ok || (int(stat.Uid) == os.Getuid() && int(stat.Gid) == os.Getgid()) {
+		return nil
+	}
+	return os.Lchown(path, int(stat.Uid), int(stat.Gid))
+}
@@ -0,0 +1,64 @@
+//go:build !windows
+
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFileTaskBackupOwner(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Changing the owner of a file needs root")
+	}
+	path := filepath.Join(t.TempDir(), "app.conf")
+	os.WriteFile(path, []byte("port = 80\n"), 0640)
+	if err := os.Chown(path, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runFileTask(t, map[string]interface{}{"path": path, "content": "port = 8080\n", "backup": true})
+	for _, p := range []string{path, output["backup"].(string)} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 1 || stat.Gid != 1 {
+			t.Errorf("%s: expected owner 1:1, got %d:%d", p, stat.Uid, stat.Gid)
+		}
+	}
+}
+
+func TestFileTaskOwnerSymlink(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Changing the owner of a file needs root")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.conf")
+	os.WriteFile(target, []byte("port = 80\n"), 0640)
+	link := filepath.Join(dir, "current.conf")
+	if err := os.Symlink("app.conf", link); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runFileTask(t, map[string]interface{}{"path": link, "content": "port = 8080\n", "owner": "1", "group": "1"})
+	if output["changed"] != true {
+		t.Errorf("Expected a change, got %v", output)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 1 || stat.Gid != 1 {
+		t.Errorf("Expected the link target to be owned by 1:1, got %d:%d", stat.Uid, stat.Gid)
+	}
+	info, err = os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 0 {
+		t.Errorf("Expected the link to keep its owner, got %d", stat.Uid)
+	}
+}
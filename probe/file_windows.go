@@ -0,0 +1,18 @@
+//go:build windows
+
+package probe
+
+import (
+	"fmt"
+	"os"
+)
+
+// ensureOwner is not supported on Windows
+func ensureOwner(path, owner, group string) (bool, error) {
+	return false, fmt.Errorf("setting the owner of files is not supported on Windows")
+}
+
+// copyOwner is a no-op on Windows, where the owner is not kept
+func copyOwner(from os.FileInfo, path string) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package probe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// taskGraph is the dependency graph built from the depends_on lists of a workflow
+type taskGraph struct {
+	// index maps a task name to its position in the workflow
+	index map[string]int
+
+	// dependents lists, for every task, the tasks that wait on it
+	dependents [][]int
+
+	// dependencies holds the number of dependencies of every task
+	dependencies []int
+}
+
+// buildGraph validates task names and dependencies and returns the resulting graph.
+// Duplicate names, references to unknown tasks and dependency cycles are rejected.
+func buildGraph(tasks []TaskDefinition) (*taskGraph, error) {
+	g := &taskGraph{
+		index:        make(map[string]int, len(tasks)),
+		dependents:   make([][]int, len(tasks)),
+		dependencies: make([]int, len(tasks)),
+	}
+
+	for i, task := range tasks {
+		if task.Name == "" {
+			continue
+		}
+		if j, ok := g.index[task.Name]; ok {
+			return nil, fmt.Errorf("task %d: duplicate task name %q (already used by task %d)", i, task.Name, j)
+		}
+		g.index[task.Name] = i
+	}
+
+	for i, task := range tasks {
+		seen := make(map[string]bool, len(task.DependsOn))
+		for _, dep := range task.DependsOn {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+
+			j, ok := g.index[dep]
+			if !ok {
+				return nil, fmt.Errorf("task %d (%s): depends on unknown task %q", i, task.Name, dep)
+			}
+			g.dependents[j] = append(g.dependents[j], i)
+			g.dependencies[i]++
+		}
+	}
+
+	if cycle := g.findCycle(tasks); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return g, nil
+}
+
+// findCycle returns the task names forming a dependency cycle, or nil if the graph is acyclic
+func (g *taskGraph) findCycle(tasks []TaskDefinition) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(tasks))
+	stack := make([]int, 0, len(tasks))
+
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		state[i] = visiting
+		stack = append(stack, i)
+
+		for _, j := range g.dependents[i] {
+			switch state[j] {
+			case visiting:
+				// Unwind the stack back to j to report the cycle
+				var cycle []string
+				for k := len(stack) - 1; k >= 0; k-- {
+					cycle = append([]string{tasks[stack[k]].Name}, cycle...)
+					if stack[k] == j {
+						break
+					}
+				}
+				return append(cycle, tasks[j].Name)
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range tasks {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// roots returns the tasks without dependencies in declaration order
+func (g *taskGraph) roots() []int {
+	var ready []int
+	for i, n := range g.dependencies {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
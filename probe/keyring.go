@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TrustKey adds an Ed25519 public key to the keyring trusted to sign the
+// artifacts of downloadexec tasks, which refer to it by id
+func (p *Probe) TrustKey(id string, key ed25519.PublicKey) {
+	p.keys[id] = key
+}
+
+// LoadKeyring trusts every *.pub file in dir, named after its key id. A file
+// holds either a base64 encoded raw key or a PEM encoded public key.
+func (p *Probe) LoadKeyring(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read keyring: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pub" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+		key, err := parsePublicKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		p.TrustKey(strings.TrimSuffix(entry.Name(), ".pub"), key)
+	}
+	return nil
+}
+
+// trustedKey looks up a key of the keyring
+func (p *Probe) trustedKey(id string) (ed25519.PublicKey, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not in the trusted keyring", id)
+	}
+	return key, nil
+}
+
+// parsePublicKey decodes a base64 or PEM encoded Ed25519 public key
+func parsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid public key: not an Ed25519 key")
+		}
+		return edKey, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: expected %d, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,170 @@
+package probe
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Loop describes the items a task is repeated for. In YAML it is either a list,
+// a template resolving to a list (e.g. "{{ vars.hosts }}"), or an inclusive
+// numeric range such as {start: 1, end: 30}.
+type Loop struct {
+	Items []interface{}
+	Expr  string
+	Range *LoopRange
+}
+
+// LoopRange is an inclusive numeric range
+type LoopRange struct {
+	Start int `yaml:"start"`
+	End   int `yaml:"end"`
+	Step  int `yaml:"step,omitempty"`
+}
+
+// UnmarshalYAML accepts a list, a template string or a range mapping
+func (l *Loop) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return node.Decode(&l.Items)
+	case yaml.ScalarNode:
+		l.Expr = node.Value
+		return nil
+	case yaml.MappingNode:
+		l.Range = &LoopRange{}
+		return node.Decode(l.Range)
+	}
+	return fmt.Errorf("line %d: loop must be a list, a template or a range", node.Line)
+}
+
+// maxInstances limits the instances a loop or matrix expands to
+const maxInstances = 10000
+
+// loopRoots are the values visible to a loop template
+var loopRoots = []string{"vars", "env", "tasks", "agent", "workflow"}
+
+// validate checks the loop without resolving templates
+func (l *Loop) validate() error {
+	if l.Expr != "" {
+		if err := checkCondition(l.Expr, loopRoots...); err != nil {
+			return fmt.Errorf("invalid loop: %w", err)
+		}
+	}
+	if r := l.Range; r != nil {
+		if r.Step >= 0 && r.Start > r.End {
+			return fmt.Errorf("invalid loop range: end is before start")
+		}
+		if r.Step < 0 && r.Start < r.End {
+			return fmt.Errorf("invalid loop range: negative step with end after start")
+		}
+		if n := r.count(); n > maxInstances {
+			return fmt.Errorf("invalid loop range: %d items exceed the limit of %d", n, maxInstances)
+		}
+	}
+	return nil
+}
+
+// step returns the range step, 1 by default
+func (r *LoopRange) step() int {
+	if r.Step == 0 {
+		return 1
+	}
+	return r.Step
+}
+
+// count returns the number of items in a validated range, computed in
+// unsigned arithmetic so that ranges spanning all ints cannot overflow
+func (r *LoopRange) count() uint64 {
+	var steps uint64
+	if step := r.step(); step > 0 {
+		steps = (uint64(r.End) - uint64(r.Start)) / uint64(step)
+	} else {
+		steps = (uint64(r.Start) - uint64(r.End)) / (uint64(-(step + 1)) + 1)
+	}
+	if steps == math.MaxUint64 {
+		return steps
+	}
+	return steps + 1
+}
+
+// items resolves the loop items in the given scope
+func (l *Loop) items(s *scope) ([]interface{}, error) {
+	switch {
+	case l.Range != nil:
+		n := l.Range.count()
+		if n > maxInstances {
+			return nil, fmt.Errorf("loop range: %d items exceed the limit of %d", n, maxInstances)
+		}
+		items := make([]interface{}, n)
+		for k := range items {
+			items[k] = l.Range.Start + k*l.Range.step()
+		}
+		return items, nil
+	case l.Expr != "":
+		v, err := evalExpr(conditionSource(l.Expr), s)
+		if err != nil {
+			return nil, fmt.Errorf("loop: %w", err)
+		}
+		return toList(v)
+	}
+	return l.Items, nil
+}
+
+// matrixSize returns the number of combinations of a matrix, stopping once
+// it exceeds maxInstances
+func matrixSize(matrix map[string][]interface{}) int {
+	size := 1
+	for _, values := range matrix {
+		size *= len(values)
+		if size > maxInstances {
+			return size
+		}
+	}
+	return size
+}
+
+// matrixItems returns the cartesian product of the matrix dimensions. Each item
+// maps dimension names to values; dimensions are combined in name order.
+func matrixItems(matrix map[string][]interface{}) []interface{} {
+	keys := make([]string, 0, len(matrix))
+	for k := range matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{map[string]interface{}{}}
+	for _, k := range keys {
+		var next []interface{}
+		for _, item := range items {
+			for _, v := range matrix[k] {
+				combined := make(map[string]interface{}, len(keys))
+				for ck, cv := range item.(map[string]interface{}) {
+					combined[ck] = cv
+				}
+				combined[k] = v
+				next = append(next, combined)
+			}
+		}
+		items = next
+	}
+	return items
+}
+
+// toList converts a slice of any type to []interface{}
+func toList(v interface{}) ([]interface{}, error) {
+	if list, ok := v.([]interface{}); ok {
+		return list, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("loop: expected a list, got %T", v)
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, nil
+}
@@ -0,0 +1,170 @@
+package probe
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProbeLoopList(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-loop-list
+tasks:
+  - name: ping
+    type: record
+    loop: [web-1, web-2, web-3]
+    config: {id: "ping {{ item }} #{{ index }}"}
+  - name: report
+    type: record
+    depends_on: [ping]
+    config: {id: "{{ tasks.ping.output | len }} pinged"}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	want := "ping web-1 #0,ping web-2 #1,ping web-3 #2,3 pinged"
+	if got := strings.Join(rec.order, ","); got != want {
+		t.Errorf("Expected order %s, got %s", want, got)
+	}
+
+	if len(result.Tasks) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(result.Tasks))
+	}
+	for i, task := range result.Tasks[:3] {
+		if task.Name != "ping" || task.Index == nil || *task.Index != i {
+			t.Errorf("Expected ping instance %d, got %+v", i, task)
+		}
+	}
+	if result.Tasks[1].Item != "web-2" {
+		t.Errorf("Expected item web-2, got %v", result.Tasks[1].Item)
+	}
+	if result.Tasks[3].Index != nil {
+		t.Errorf("Expected no index for a task without a loop")
+	}
+}
+
+func TestProbeLoopRangeAndTemplate(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-loop-range
+vars:
+  regions: [eu, us]
+tasks:
+  - name: shard
+    type: record
+    loop: {start: 1, end: 5, step: 2}
+    config: {id: "shard-{{ item }}"}
+  - name: region
+    type: record
+    loop: "{{ vars.regions }}"
+    config: {id: "region-{{ item }}"}
+`
+
+	if _, err := p.ExecuteYAML(context.Background(), []byte(yaml)); err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	want := "shard-1,shard-3,shard-5,region-eu,region-us"
+	if got := strings.Join(rec.order, ","); got != want {
+		t.Errorf("Expected order %s, got %s", want, got)
+	}
+}
+
+func TestProbeMatrix(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-matrix
+tasks:
+  - name: deploy
+    type: record
+    matrix:
+      region: [eu, us]
+      tier: [web, api]
+    when: not (item.region == "us" and item.tier == "api")
+    config: {id: "{{ item.region }}/{{ item.tier }}"}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if got := strings.Join(rec.order, ","); got != "eu/web,eu/api,us/web" {
+		t.Errorf("Unexpected instance order: %s", got)
+	}
+	if len(result.Tasks) != 4 || result.Tasks[3].Status != StatusSkipped {
+		t.Errorf("Expected 4 results with the last one skipped, got %+v", result.Tasks)
+	}
+}
+
+func TestProbeLoopFailure(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-loop-failure
+tasks:
+  - name: check
+    type: record
+    loop: [a, b, c]
+    config: {id: "{{ item }}", fail: "{{ item == \"b\" }}"}
+  - name: after
+    type: record
+    depends_on: [check]
+    config: {id: after}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatal("Expected workflow to fail")
+	}
+
+	if got := strings.Join(rec.order, ","); got != "a,b" {
+		t.Errorf("Expected execution to stop after b, got %s", got)
+	}
+
+	statuses := make([]TaskStatus, len(result.Tasks))
+	for i, task := range result.Tasks {
+		statuses[i] = task.Status
+	}
+	want := []TaskStatus{StatusSuccess, StatusFailed, StatusSkipped, StatusSkipped}
+	for i := range want {
+		if i >= len(statuses) || statuses[i] != want[i] {
+			t.Fatalf("Expected statuses %v, got %v", want, statuses)
+		}
+	}
+}
+
+func TestProbeInvalidLoop(t *testing.T) {
+	for _, loop := range []string{
+		`loop: [a]
+    matrix: {x: [1]}`,
+		`matrix: {x: []}`,
+		`loop: "{{ vars.hosts | }}"`,
+		`loop: {start: 5, end: 1}`,
+		`loop: {start: 5, end: 1, step: 2}`,
+		`loop: {start: 1, end: 10, step: -1}`,
+		`loop: {start: 1, end: 9223372036854775807}`,
+		`loop: {start: 9223372036854775807, end: -9223372036854775808, step: -1}`,
+		`loop: {start: 0, end: 10000}`,
+		`matrix: {a: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10], b: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10], c: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10], d: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10], e: [1, 2]}`,
+	} {
+		yaml := `
+name: test-invalid-loop
+tasks:
+  - name: t
+    type: record
+    ` + loop + `
+    config: {id: t}
+`
+		if _, err := ParseWorkflow([]byte(yaml)); err == nil {
+			t.Errorf("Expected error for %s", loop)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives events while a workflow runs, e.g. to report live progress.
+// OnTaskOutput is called from the goroutines running the tasks, so observers
+// must be safe for concurrent use.
+type Observer interface {
+	OnWorkflowStart(event WorkflowEvent)
+	OnTaskStart(event TaskEvent)
+	OnTaskOutput(event TaskOutputEvent)
+	OnTaskEnd(event TaskEvent)
+	OnWorkflowEnd(event WorkflowEvent)
+}
+
+// WorkflowEvent reports the start or end of a workflow
+type WorkflowEvent struct {
+	Workflow string
+	Time     time.Time
+
+	// Duration and Result are set when the workflow ends
+	Duration time.Duration
+	Result   *WorkflowResult
+}
+
+// TaskEvent reports the start or end of a task. Every task result is reported
+// by exactly one OnTaskEnd, including tasks that never started.
+type TaskEvent struct {
+	Workflow string
+	Task     string
+	Type     string
+	Index    *int
+	Time     time.Time
+
+	// Duration and Result are set when the task ends
+	Duration time.Duration
+	Result   *TaskResult
+}
+
+// TaskOutputEvent carries a line of output written by a running task
+type TaskOutputEvent struct {
+	Workflow string
+	Task     string
+	Index    *int
+	Time     time.Time
+
+	// Stream is "stdout" or "stderr"
+	Stream string
+	Line   string
+}
+
+// NopObserver implements Observer with methods that do nothing. Embed it to
+// handle only some of the events.
+type NopObserver struct{}
+
+func (NopObserver) OnWorkflowStart(WorkflowEvent) {}
+func (NopObserver) OnTaskStart(TaskEvent)         {}
+func (NopObserver) OnTaskOutput(TaskOutputEvent)  {}
+func (NopObserver) OnTaskEnd(TaskEvent)           {}
+func (NopObserver) OnWorkflowEnd(WorkflowEvent)   {}
+
+// RegisterObserver adds an observer notified of every workflow run by this Probe
+func (p *Probe) RegisterObserver(o Observer) {
+	p.observers = append(p.observers, o)
+}
+
+type observersKey struct{}
+
+// WithObserver returns a context that notifies the observer of workflows run
+// with it, in addition to the observers registered on the Probe. Use it for
+// observers bound to a single run, such as one job.
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	observers, _ := ctx.Value(observersKey{}).([]Observer)
+	observers = append(observers[:len(observers):len(observers)], o)
+	return context.WithValue(ctx, observersKey{}, observers)
+}
+
+// observersFor returns the observers of a run with the given context
+func (p *Probe) observersFor(ctx context.Context) observerList {
+	observers, _ := ctx.Value(observersKey{}).([]Observer)
+	return append(append(observerList{}, p.observers...), observers...)
+}
+
+// observerList fans events out to several observers
+type observerList []Observer
+
+func (l observerList) workflowStart(e WorkflowEvent) {
+	for _, o := range l {
+		o.OnWorkflowStart(e)
+	}
+}
+
+func (l observerList) taskStart(e TaskEvent) {
+	for _, o := range l {
+		o.OnTaskStart(e)
+	}
+}
+
+func (l observerList) taskOutput(e TaskOutputEvent) {
+	for _, o := range l {
+		o.OnTaskOutput(e)
+	}
+}
+
+func (l observerList) taskEnd(e TaskEvent) {
+	for _, o := range l {
+		o.OnTaskEnd(e)
+	}
+}
+
+func (l observerList) workflowEnd(e WorkflowEvent) {
+	for _, o := range l {
+		o.OnWorkflowEnd(e)
+	}
+}
+
+// maxLineLength bounds the buffered part of an output line; longer lines are
+// reported in pieces
+const maxLineLength = 64 * 1024
+
+type outputKey struct{}
+
+// taskOutput reports the output of one running task to the observers
+type taskOutput struct {
+	observers observerList
+	workflow  string
+	task      string
+	index     *int
+
+	// ended is shared by all tasks of a run and set when the run has ended,
+	// silencing tasks abandoned after a timeout
+	ended *atomic.Bool
+
+	// secrets redacts secret values from the lines
+	secrets *secretStore
+
+	mu      sync.Mutex
+	writers map[string]*lineWriter
+}
+
+// OutputWriter returns a writer for the live output of the running task on the
+// given stream ("stdout" or "stderr"). Every complete line written is reported
+// to the workflow observers through OnTaskOutput. Outside a workflow run, or
+// without observers, the writer discards its input.
+func OutputWriter(ctx context.Context, stream string) io.Writer {
+	out, ok := ctx.Value(outputKey{}).(*taskOutput)
+	if !ok || len(out.observers) == 0 {
+		return io.Discard
+	}
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if w, ok := out.writers[stream]; ok {
+		return w
+	}
+	w := &lineWriter{out: out, stream: stream}
+	out.writers[stream] = w
+	return w
+}
+
+// flush reports the last unterminated line of every stream
+func (o *taskOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, w := range o.writers {
+		w.flush()
+	}
+}
+
+// lineWriter splits written data into lines
+type lineWriter struct {
+	out    *taskOutput
+	stream string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write reports every complete line and keeps the rest for the next write
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineLength {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// flush reports the buffered rest as a final line
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	if w.out.ended.Load() {
+		return
+	}
+	w.out.observers.taskOutput(TaskOutputEvent{
+		Workflow: w.out.workflow,
+		Task:     w.out.task,
+		Index:    w.out.index,
+		Time:     time.Now(),
+		Stream:   w.stream,
+		Line:     w.out.secrets.redact(string(bytes.TrimSuffix(line, []byte("\r")))),
+	})
+}
@@ -0,0 +1,133 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventLog is an observer recording events as strings
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf(format, args...))
+}
+
+func (l *eventLog) OnWorkflowStart(e WorkflowEvent) {
+	l.add("workflow start %s", e.Workflow)
+}
+
+func (l *eventLog) OnTaskStart(e TaskEvent) {
+	l.add("task start %s", e.Task)
+}
+
+func (l *eventLog) OnTaskOutput(e TaskOutputEvent) {
+	l.add("output %s %s: %s", e.Task, e.Stream, e.Line)
+}
+
+func (l *eventLog) OnTaskEnd(e TaskEvent) {
+	l.add("task end %s %s", e.Task, e.Result.Status)
+}
+
+func (l *eventLog) OnWorkflowEnd(e WorkflowEvent) {
+	l.add("workflow end %s %v", e.Workflow, e.Result.Success)
+}
+
+// echoTask writes its text to the stdout output writer
+type echoTask struct {
+	text string
+	fail bool
+}
+
+func (t *echoTask) Configure(config map[string]interface{}) error {
+	t.text, _ = config["text"].(string)
+	t.fail, _ = config["fail"].(bool)
+	return nil
+}
+
+func (t *echoTask) Execute(ctx context.Context) (interface{}, error) {
+	w := OutputWriter(ctx, "stdout")
+	// Split writes to check that lines are reassembled
+	for _, part := range strings.SplitAfter(t.text, " ") {
+		fmt.Fprint(w, part)
+	}
+	fmt.Fprint(OutputWriter(ctx, "stderr"), "done")
+	if t.fail {
+		return nil, fmt.Errorf("echo failed")
+	}
+	return nil, nil
+}
+
+func TestProbeObserver(t *testing.T) {
+	registered := &eventLog{}
+	p := New()
+	p.RegisterTask("echo", func() Task { return &echoTask{} })
+	p.RegisterObserver(registered)
+
+	yaml := `
+name: test-observer
+tasks:
+  - name: greet
+    type: echo
+    config: {text: "hello world\nsecond line\n"}
+  - name: broken
+    type: echo
+    config: {fail: true}
+  - name: after
+    type: echo
+    depends_on: [broken]
+`
+
+	perRun := &eventLog{}
+	ctx := WithObserver(context.Background(), perRun)
+	result, err := p.ExecuteYAML(ctx, []byte(yaml))
+	if err == nil {
+		t.Fatal("Expected workflow to fail")
+	}
+	if result.Duration <= 0 || result.Tasks[0].Duration <= 0 {
+		t.Errorf("Expected durations to be recorded")
+	}
+
+	want := []string{
+		"workflow start test-observer",
+		"task start greet",
+		"output greet stdout: hello world",
+		"output greet stdout: second line",
+		"output greet stderr: done",
+		"task end greet success",
+		"task start broken",
+		"output broken stderr: done",
+		"task end broken failed",
+		"task end after skipped",
+		"workflow end test-observer false",
+	}
+	for _, log := range []*eventLog{registered, perRun} {
+		if got := strings.Join(log.events, "\n"); got != strings.Join(want, "\n") {
+			t.Errorf("Unexpected events:\n%s", got)
+		}
+	}
+
+	// Without an observer in the context, only the registered one is notified
+	registered.events = nil
+	perRun.events = nil
+	if _, err := p.ExecuteYAML(context.Background(), []byte(yaml)); err == nil {
+		t.Fatal("Expected workflow to fail")
+	}
+	if len(registered.events) == 0 || len(perRun.events) != 0 {
+		t.Errorf("Expected only the registered observer to be notified")
+	}
+}
+
+func TestOutputWriterWithoutRun(t *testing.T) {
+	w := OutputWriter(context.Background(), "stdout")
+	if n, err := w.Write([]byte("ignored\n")); n != 8 || err != nil {
+		t.Errorf("Expected writes to be discarded, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,256 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxOutput is the default limit of captured output per stream
+const defaultMaxOutput = 1024 * 1024
+
+// cappedBuffer keeps the beginning and the end of the data written to it, up
+// to a limit, and counts the bytes dropped in between
+type cappedBuffer struct {
+	limit int
+
+	mu   sync.Mutex
+	head []byte
+
+	// tail is a ring buffer holding the latest data after head is full
+	tail    []byte
+	pos     int
+	written int64
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+// Write keeps the first half of the limit as head and the latest data, up to
+// the other half, as tail
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if room := b.limit - b.limit/2 - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	size := b.limit / 2
+	if len(p) == 0 || size == 0 {
+		b.written += int64(len(p))
+		return n, nil
+	}
+	if b.tail == nil {
+		b.tail = make([]byte, size)
+	}
+	b.written += int64(len(p))
+	if len(p) > size {
+		p = p[len(p)-size:]
+	}
+	copied := copy(b.tail[b.pos:], p)
+	copy(b.tail, p[copied:])
+	b.pos = (b.pos + len(p)) % size
+	return n, nil
+}
+
+// dropped returns the number of bytes that were not kept
+func (b *cappedBuffer) dropped() int64 {
+	if size := int64(b.limit / 2); b.written > size {
+		return b.written - size
+	}
+	return 0
+}
+
+// truncated reports whether data was dropped
+func (b *cappedBuffer) truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped() > 0
+}
+
+// String returns the kept data, with a marker where data was dropped
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := b.dropped()
+	if dropped == 0 {
+		return string(b.head) + string(b.tail[:b.written])
+	}
+	tail := string(b.tail[b.pos:]) + string(b.tail[:b.pos])
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, dropped, tail)
+}
+
+// processOutput captures the stdout and stderr of a process separately and
+// interleaved, each capped to a limit, while streaming every line to the
+// workflow observers
+type processOutput struct {
+	stdout   *cappedBuffer
+	stderr   *cappedBuffer
+	combined *cappedBuffer
+
+	// Stdout and Stderr are the writers to attach to the process
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// prefixed are the streaming writers of prefixed output
+	prefixed []*prefixWriter
+}
+
+func newProcessOutput(ctx context.Context, limit int) *processOutput {
+	return newPrefixedOutput(ctx, limit, "")
+}
+
+// newPrefixedOutput is like newProcessOutput, with every streamed line
+// starting with prefix to tell apart processes of the same task
+func newPrefixedOutput(ctx context.Context, limit int, prefix string) *processOutput {
+	o := &processOutput{
+		stdout:   newCappedBuffer(limit),
+		stderr:   newCappedBuffer(limit),
+		combined: newCappedBuffer(limit),
+	}
+	stdout, stderr := OutputWriter(ctx, "stdout"), OutputWriter(ctx, "stderr")
+	if prefix != "" {
+		o.prefixed = []*prefixWriter{{w: stdout, prefix: prefix}, {w: stderr, prefix: prefix}}
+		stdout, stderr = o.prefixed[0], o.prefixed[1]
+	}
+	o.Stdout = io.MultiWriter(o.stdout, o.combined, stdout)
+	o.Stderr = io.MultiWriter(o.stderr, o.combined, stderr)
+	return o
+}
+
+// result returns the task output: the interleaved output, both streams, the
+// exit code and whether any stream was truncated
+func (o *processOutput) result(exitCode int) map[string]interface{} {
+	for _, w := range o.prefixed {
+		w.flush()
+	}
+	return map[string]interface{}{
+		"output":    o.combined.String(),
+		"stdout":    o.stdout.String(),
+		"stderr":    o.stderr.String(),
+		"exit_code": exitCode,
+		"truncated": o.stdout.truncated() || o.stderr.truncated() || o.combined.truncated(),
+	}
+}
+
+// prefixWriter writes complete lines to w, each starting with prefix
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.w.Write(append([]byte(w.prefix), w.buf[:i+1]...))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush writes the last unterminated line
+func (w *prefixWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.w.Write(append(append([]byte(w.prefix), w.buf...), '\n'))
+		w.buf = nil
+	}
+}
+
+// processWaitDelay bounds the wait for the output of a killed process, in
+// case a process outside its group still holds the output open
+const processWaitDelay = 5 * time.Second
+
+// runProcess runs a command, capturing and streaming its output. The exit code
+// of a command that ran is reported in the result rather than as an error; an
+// error is returned only when the command could not be run. When ctx is done,
+// the process is killed with all its children.
+func runProcess(ctx context.Context, cmd *exec.Cmd, maxOutput int) (map[string]interface{}, int, error) {
+	out := newProcessOutput(ctx, maxOutput)
+	cmd.Stdout = out.Stdout
+	cmd.Stderr = out.Stderr
+	killProcessGroup(cmd)
+	cmd.WaitDelay = processWaitDelay
+
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		exitError, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, 0, err
+		}
+		exitCode = exitError.ExitCode()
+	}
+	return out.result(exitCode), exitCode, nil
+}
+
+// parseMaxOutput reads the max_output setting of a task, either a number of
+// bytes or a size such as "512KB" or "10MB"
+func parseMaxOutput(config map[string]interface{}) (int, error) {
+	v, ok := config["max_output"]
+	if !ok {
+		return defaultMaxOutput, nil
+	}
+	size, err := parseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_output: %w", err)
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid max_output: must be positive")
+	}
+	return size, nil
+}
+
+// parseSize parses a byte count given as a number or with a KB, MB or GB suffix
+func parseSize(v interface{}) (int, error) {
+	switch x := v.(type) {
+	case int:
+		return x, nil
+	case float64:
+		return int(x), nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(x))
+		multiplier := 1
+		for _, unit := range []struct {
+			suffix string
+			size   int
+		}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+			if strings.HasSuffix(s, unit.suffix) {
+				s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+				multiplier = unit.size
+				break
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a size", x)
+		}
+		return n * multiplier, nil
+	}
+	return 0, fmt.Errorf("%v is not a size", v)
+}
@@ -0,0 +1,101 @@
+package probe
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	b := newCappedBuffer(10)
+	b.Write([]byte("abc"))
+	if b.String() != "abc" || b.truncated() {
+		t.Errorf("Expected untruncated output, got %q", b.String())
+	}
+
+	for _, chunk := range []string{"defgh", "ijklmnop", "qrst", "uvwxyz"} {
+		b.Write([]byte(chunk))
+	}
+	want := "abcde\n... [16 bytes truncated] ...\nvwxyz"
+	if got := b.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if !b.truncated() {
+		t.Errorf("Expected output to be truncated")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want int
+	}{
+		{4096, 4096},
+		{"512", 512},
+		{"512KB", 512 * 1024},
+		{"10 mb", 10 * 1024 * 1024},
+		{"1GB", 1024 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%v) = %d, %v; expected %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []interface{}{"lots", "1TB", true} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%v): expected error", in)
+		}
+	}
+}
+
+func TestCommandTaskStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh")
+	}
+
+	p := New()
+	events := &eventLog{}
+	p.RegisterObserver(events)
+
+	yaml := `
+name: test-streams
+tasks:
+  - name: script
+    type: command
+    config:
+      command: "echo first; echo oops >&2; seq 1 1000; exit 3"
+      shell: true
+      max_output: 100
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatal("Expected the non-zero exit code to fail the task")
+	}
+
+	output := result.Tasks[0].Output.(map[string]interface{})
+	if output["exit_code"] != 3 || output["truncated"] != true {
+		t.Errorf("Unexpected result: %+v", output)
+	}
+	if output["stderr"] != "oops\n" {
+		t.Errorf("Expected stderr to be captured separately, got %q", output["stderr"])
+	}
+	stdout := output["stdout"].(string)
+	if !strings.HasPrefix(stdout, "first\n1\n") || !strings.HasSuffix(stdout, "999\n1000\n") || !strings.Contains(stdout, "bytes truncated") {
+		t.Errorf("Expected head and tail of stdout with a truncation marker, got %q", stdout)
+	}
+
+	// Every line is streamed to observers even when the captured output is capped
+	lines := 0
+	for _, e := range events.events {
+		if strings.HasPrefix(e, "output script stdout: ") {
+			lines++
+		}
+	}
+	if lines != 1001 {
+		t.Errorf("Expected 1001 streamed stdout lines, got %d", lines)
+	}
+}
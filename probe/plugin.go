@@ -0,0 +1,280 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Plugins are executables providing task types out of process. Every call is
+// a separate run of the executable, which reads one JSON request line from
+// stdin and writes JSON message lines to stdout:
+//
+//	request: {"protocol": 1, "method": "describe"}
+//	         {"protocol": 1, "method": "configure", "task": "TYPE", "config": {...}}
+//	         {"protocol": 1, "method": "execute", "task": "TYPE", "config": {...}}
+//	output:  {"type": "output", "stream": "stdout", "line": "..."}
+//	result:  {"type": "result", "output": ..., "error": "..."}
+//
+// The describe result lists the task types of the plugin:
+// {"tasks": [{"type": "TYPE", "description": "...", "fields": [...]}]}, with
+// fields as in FieldSpec. Anything written to stderr is reported as task output.
+
+// pluginProtocol is the version of the plugin protocol sent with every request
+const pluginProtocol = 1
+
+const (
+	// pluginDescribeTimeout bounds the describe call made at discovery
+	pluginDescribeTimeout = 10 * time.Second
+
+	// pluginConfigureTimeout bounds the configure call of a task
+	pluginConfigureTimeout = 30 * time.Second
+
+	// maxPluginMessage bounds a single message line written by a plugin
+	maxPluginMessage = 16 * 1024 * 1024
+)
+
+// pluginRequest is the request sent to a plugin on stdin
+type pluginRequest struct {
+	Protocol int                    `json:"protocol"`
+	Method   string                 `json:"method"`
+	Task     string                 `json:"task,omitempty"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+}
+
+// pluginMessage is a message written by a plugin on stdout
+type pluginMessage struct {
+	Type   string          `json:"type"`
+	Stream string          `json:"stream"`
+	Line   string          `json:"line"`
+	Output json.RawMessage `json:"output"`
+	Error  string          `json:"error"`
+}
+
+// pluginDescription is the result of the describe call
+type pluginDescription struct {
+	Tasks []struct {
+		Type        string      `json:"type"`
+		Description string      `json:"description"`
+		Fields      []FieldSpec `json:"fields"`
+		Platforms   []string    `json:"platforms"`
+	} `json:"tasks"`
+}
+
+// LoadPlugins registers the task types provided by every executable in a
+// directory. A plugin may not replace a task type that is already registered.
+// Plugins that fail to describe themselves are skipped and reported in the
+// returned error, after the others were registered.
+func (p *Probe) LoadPlugins(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !isExecutable(path) {
+			continue
+		}
+		if err := p.loadPlugin(ctx, path); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", entry.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadPlugin describes a plugin and registers its task types
+func (p *Probe) loadPlugin(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, pluginDescribeTimeout)
+	defer cancel()
+
+	raw, err := callPlugin(ctx, path, pluginRequest{Method: "describe"})
+	if err != nil {
+		return err
+	}
+	var desc pluginDescription
+	if err := json.Unmarshal(raw, &desc); err != nil {
+		return fmt.Errorf("invalid description: %w", err)
+	}
+	if len(desc.Tasks) == 0 {
+		return fmt.Errorf("provides no task types")
+	}
+
+	for _, t := range desc.Tasks {
+		if t.Type == "" {
+			return fmt.Errorf("task type without a name")
+		}
+		if _, ok := p.tasks[t.Type]; ok {
+			return fmt.Errorf("task type %s is already registered", t.Type)
+		}
+	}
+	for _, t := range desc.Tasks {
+		spec := TaskSpec{
+			Description: t.Description,
+			Fields:      t.Fields,
+			Platforms:   t.Platforms,
+		}
+		taskType := t.Type
+		p.RegisterTask(taskType, func() Task {
+			return &PluginTask{Path: path, Type: taskType, spec: spec}
+		})
+	}
+	return nil
+}
+
+// isExecutable reports whether a directory entry is a program
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// PluginTask runs a task type provided by a plugin executable
+type PluginTask struct {
+	Path   string
+	Type   string
+	Config map[string]interface{}
+
+	spec TaskSpec
+}
+
+// Describe returns the configuration described by the plugin
+func (t *PluginTask) Describe() TaskSpec {
+	return t.spec
+}
+
+// Configure lets the plugin check the configuration
+func (t *PluginTask) Configure(config map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginConfigureTimeout)
+	defer cancel()
+
+	_, err := callPlugin(ctx, t.Path, pluginRequest{
+		Method: "configure",
+		Task:   t.Type,
+		Config: config,
+	})
+	if err != nil {
+		return err
+	}
+	t.Config = config
+	return nil
+}
+
+// Execute runs the plugin, streaming its output lines to the observers
+func (t *PluginTask) Execute(ctx context.Context) (interface{}, error) {
+	raw, err := callPlugin(ctx, t.Path, pluginRequest{
+		Method: "execute",
+		Task:   t.Type,
+		Config: t.Config,
+	})
+
+	// A failing plugin may still report output
+	var output interface{}
+	if len(raw) > 0 {
+		if jsonErr := json.Unmarshal(raw, &output); jsonErr != nil && err == nil {
+			return nil, fmt.Errorf("invalid plugin output: %w", jsonErr)
+		}
+	}
+	return output, err
+}
+
+// callPlugin runs a plugin for one request and returns the output of its
+// result message. Output messages and stderr are written to the task's
+// output writers.
+func callPlugin(ctx context.Context, path string, req pluginRequest) (json.RawMessage, error) {
+	req.Protocol = pluginProtocol
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stderr = OutputWriter(ctx, "stderr")
+	cmd.WaitDelay = 5 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+
+	result, readErr := readPluginMessages(ctx, stdout)
+	if readErr != nil {
+		// Stop a plugin writing garbage instead of waiting for it
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case readErr != nil:
+		return nil, readErr
+	case result == nil && waitErr != nil:
+		return nil, fmt.Errorf("plugin exited without a result: %w", waitErr)
+	case result == nil:
+		return nil, fmt.Errorf("plugin exited without a result")
+	case result.Error != "":
+		return result.Output, errors.New(result.Error)
+	}
+	return result.Output, nil
+}
+
+// readPluginMessages reads the messages of a plugin until its result
+func readPluginMessages(ctx context.Context, r io.Reader) (*pluginMessage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxPluginMessage)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg pluginMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("invalid plugin message: %w", err)
+		}
+
+		switch msg.Type {
+		case "output":
+			stream := msg.Stream
+			if stream != "stderr" {
+				stream = "stdout"
+			}
+			fmt.Fprintln(OutputWriter(ctx, stream), msg.Line)
+		case "result":
+			// Drain the rest so the plugin is not blocked writing
+			io.Copy(io.Discard, r)
+			return &msg, nil
+		default:
+			return nil, fmt.Errorf("unknown plugin message type %q", msg.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read plugin output: %w", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const greetPlugin = `#!/bin/sh
+read -r request
+case "$request" in
+*'"method":"describe"'*)
+  echo '{"type":"result","output":{"tasks":[{"type":"greet","description":"Greets","fields":[{"name":"who","type":"string","required":true}]}]}}' ;;
+*'"method":"configure"'*)
+  case "$request" in
+  *'"who":"nobody"'*) echo '{"type":"result","error":"nobody to greet"}' ;;
+  *) echo '{"type":"result"}' ;;
+  esac ;;
+*'"method":"execute"'*'"who":"moon"'*)
+  echo '{"type":"result","output":{"reached":false},"error":"moon is out of reach"}' ;;
+*'"method":"execute"'*)
+  echo '{"type":"output","stream":"stdout","line":"hello"}'
+  echo "warning" >&2
+  echo "{\"type\":\"result\",\"output\":{\"request\":$request}}" ;;
+esac
+`
+
+// writePlugins creates a plugin directory with the greet plugin, a broken
+// plugin and a file that is not executable
+func writePlugins(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"greet":     {greetPlugin, 0755},
+		"broken":    {"#!/bin/sh\necho not json\n", 0755},
+		"README.md": {"not a plugin", 0644},
+	}
+	for name, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh plugins")
+	}
+
+	p := New()
+	err := p.LoadPlugins(context.Background(), writePlugins(t))
+	if err == nil || !strings.Contains(err.Error(), "plugin broken: invalid plugin message") {
+		t.Errorf("Expected the broken plugin to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "README") {
+		t.Errorf("Expected files that are not executable to be ignored, got %v", err)
+	}
+
+	events := &eventLog{}
+	p.RegisterObserver(events)
+
+	yaml := `
+name: test-plugin
+tasks:
+  - name: greet
+    type: greet
+    config: {who: world}
+`
+	if err := p.ValidateYAML([]byte(yaml)); err != nil {
+		t.Fatalf("Expected workflow to validate, got %v", err)
+	}
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	request := result.Tasks[0].Output.(map[string]interface{})["request"].(map[string]interface{})
+	if request["task"] != "greet" || request["config"].(map[string]interface{})["who"] != "world" {
+		t.Errorf("Unexpected request: %v", request)
+	}
+	for _, want := range []string{"output greet stdout: hello", "output greet stderr: warning"} {
+		if !containsString(events.events, want) {
+			t.Errorf("Expected %q in events %v", want, events.events)
+		}
+	}
+
+	// The plugin's description and configure call are used for validation
+	err = p.ValidateYAML([]byte(`
+name: test-plugin
+tasks:
+  - name: greet
+    type: greet
+    config: {whom: world}
+  - name: nobody
+    type: greet
+    config: {who: nobody}
+`))
+	if err == nil || !strings.Contains(err.Error(), "config.whom: unknown key") || !strings.Contains(err.Error(), "nobody to greet") {
+		t.Errorf("Expected plugin validation errors, got %v", err)
+	}
+
+	// Output reported with an error is kept
+	task := &PluginTask{Path: filepath.Join(writePlugins(t), "greet"), Type: "greet", Config: map[string]interface{}{"who": "moon"}}
+	output, err := task.Execute(context.Background())
+	if err == nil || err.Error() != "moon is out of reach" {
+		t.Errorf("Expected plugin error, got %v", err)
+	}
+	if result, ok := output.(map[string]interface{}); !ok || result["reached"] != false {
+		t.Errorf("Expected output with the error, got %v", output)
+	}
+
+	// Plugins cannot replace registered task types
+	if err := p.LoadPlugins(context.Background(), writePlugins(t)); err == nil || !strings.Contains(err.Error(), "task type greet is already registered") {
+		t.Errorf("Expected duplicate task type error, got %v", err)
+	}
+}
@@ -2,14 +2,30 @@ package probe
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Probe is the main executor for workflows
 type Probe struct {
-	tasks map[string]TaskFactory
+	tasks     map[string]TaskFactory
+	observers []Observer
+	secrets   []SecretResolver
+
+	// workflows is the library of workflows runnable by name from workflow tasks
+	workflows map[string]*Workflow
+
+	// keys are trusted to sign downloadexec artifacts, which are cached in
+	// artifactCache up to artifactCacheSize bytes
+	keys              map[string]ed25519.PublicKey
+	artifactCache     string
+	artifactCacheSize int64
+
+	// facts are set by SetFacts and seen by expressions as agent.facts
+	facts map[string]interface{}
 }
 
 // TaskFactory creates a new task instance
@@ -18,7 +34,9 @@ type TaskFactory func() Task
 // New creates a new Probe instance with all built-in tasks registered
 func New() *Probe {
 	p := &Probe{
-		tasks: make(map[string]TaskFactory),
+		tasks:     make(map[string]TaskFactory),
+		workflows: make(map[string]*Workflow),
+		keys:      make(map[string]ed25519.PublicKey),
 	}
 	
 	// Register built-in tasks
@@ -27,7 +45,16 @@ func New() *Probe {
 	p.RegisterTask("ssh", func() Task { return &SSHTask{} })
 	p.RegisterTask("command", func() Task { return &CommandTask{} })
 	p.RegisterTask("powershell", func() Task { return &PowerShellTask{} })
-	p.RegisterTask("downloadexec", func() Task { return &DownloadExecTask{} })
+	p.RegisterTask("downloadexec", func() Task { return &DownloadExecTask{probe: p} })
+	p.RegisterTask("workflow", func() Task { return &WorkflowTask{probe: p} })
+	p.RegisterTask("script", func() Task { return &ScriptTask{} })
+	p.RegisterTask("tcp", func() Task { return &TCPTask{} })
+	p.RegisterTask("dns", func() Task { return &DNSTask{} })
+	p.RegisterTask("tls_cert", func() Task { return &TLSCertTask{} })
+	p.RegisterTask("file", func() Task { return &FileTask{} })
+	p.RegisterTask("service", func() Task { return &ServiceTask{} })
+	p.RegisterTask("wait_for", func() Task { return &WaitForTask{probe: p} })
+	p.RegisterTask("facts", func() Task { return &FactsTask{} })
 	
 	return p
 }
@@ -37,60 +64,165 @@ func (p *Probe) RegisterTask(taskType string, factory TaskFactory) {
 	p.tasks[taskType] = factory
 }
 
-// ExecuteYAML parses and executes a YAML workflow
-func (p *Probe) ExecuteYAML(ctx context.Context, yamlData []byte) (*WorkflowResult, error) {
+// ParseWorkflow parses a YAML workflow and validates its task dependencies
+func ParseWorkflow(yamlData []byte) (*Workflow, error) {
 	var workflow Workflow
 	if err := yaml.Unmarshal(yamlData, &workflow); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
+
+	// Reject cycles and malformed task settings before anything runs
+	if _, err := workflow.validate(); err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+
+	return &workflow, nil
+}
+
+// ExecuteYAML parses and executes a YAML workflow
+func (p *Probe) ExecuteYAML(ctx context.Context, yamlData []byte) (*WorkflowResult, error) {
+	workflow, err := ParseWorkflow(yamlData)
+	if err != nil {
+		return nil, err
+	}
 	
-	return p.Execute(ctx, &workflow)
+	return p.Execute(ctx, workflow)
 }
 
-// Execute executes a workflow
+// Execute executes a workflow. Tasks run once all of their dependencies have
+// succeeded; independent tasks run concurrently up to the workflow's max_parallel.
+// Templates in task configs are resolved just before each task is configured.
+// Registered observers are notified as the workflow progresses.
 func (p *Probe) Execute(ctx context.Context, workflow *Workflow) (*WorkflowResult, error) {
+	return p.run(ctx, workflow, nil)
+}
+
+// run executes a workflow for Execute and workflow tasks. Inputs override the
+// workflow variables of the same name.
+func (p *Probe) run(ctx context.Context, workflow *Workflow, inputs map[string]interface{}) (*WorkflowResult, error) {
+	observers := p.observersFor(ctx)
+	start := time.Now()
+	observers.workflowStart(WorkflowEvent{
+		Workflow: workflow.Name,
+		Time:     start,
+	})
+
+	result, err := p.execute(ctx, workflow, inputs, observers)
+
+	result.Duration = time.Since(start)
+	observers.workflowEnd(WorkflowEvent{
+		Workflow: workflow.Name,
+		Time:     time.Now(),
+		Duration: result.Duration,
+		Result:   result,
+	})
+	return result, err
+}
+
+// execute runs a workflow for run
+func (p *Probe) execute(ctx context.Context, workflow *Workflow, inputs map[string]interface{}, observers observerList) (*WorkflowResult, error) {
 	result := &WorkflowResult{
 		Name:    workflow.Name,
 		Tasks:   make([]TaskResult, 0, len(workflow.Tasks)),
 		Success: true,
 	}
-	
-	for i, taskDef := range workflow.Tasks {
-		// Get task factory
-		factory, ok := p.tasks[taskDef.Type]
-		if !ok {
-			return result, fmt.Errorf("unknown task type: %s", taskDef.Type)
-		}
-		
-		// Create task instance
-		task := factory()
-		
-		// Configure task
-		if err := task.Configure(taskDef.Config); err != nil {
-			return result, fmt.Errorf("task %d (%s): failed to configure: %w", i, taskDef.Name, err)
-		}
-		
-		// Execute task
-		taskResult := TaskResult{
-			Name: taskDef.Name,
-			Type: taskDef.Type,
+
+	graph, err := workflow.validate()
+	if err != nil {
+		result.Success = false
+		return result, fmt.Errorf("invalid workflow: %w", err)
+	}
+
+	// Resolve all task factories up front so an unknown type fails before anything runs
+	factories, err := p.factories("task", workflow.Tasks)
+	if err != nil {
+		result.Success = false
+		return result, err
+	}
+	finallyFactories, err := p.factories("finally task", workflow.Finally)
+	if err != nil {
+		result.Success = false
+		return result, err
+	}
+
+	maxParallel := workflow.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = 1
+	}
+
+	timeout, _ := workflow.timeout()
+
+	// Sub-workflows share the secrets of their parent, so that secrets passed
+	// as inputs are redacted too
+	secrets, ok := ctx.Value(secretsKey{}).(*secretStore)
+	if !ok {
+		secrets = newSecretStore(ctx, p.secrets)
+		ctx = context.WithValue(ctx, secretsKey{}, secrets)
+	}
+
+	vars, err := resolveVars(workflow.Vars, inputs, secrets)
+	if err != nil {
+		result.Success = false
+		return result, secrets.redactError(fmt.Errorf("invalid vars: %w", err))
+	}
+
+	exec := &execution{
+		workflow:         workflow,
+		graph:            graph,
+		factories:        factories,
+		finallyFactories: finallyFactories,
+		maxParallel:      maxParallel,
+		timeout:          timeout,
+		vars:             vars,
+		agent:            agentInfo(p.facts),
+		observers:        observers,
+		secrets:          secrets,
+		tasks:            make(map[string]interface{}),
+	}
+	defer exec.ended.Store(true)
+
+	result.Tasks, err = exec.run(ctx)
+	if err != nil {
+		result.Success = false
+		result.TimedOut = exec.timedOut
+	}
+
+	// Finally tasks always run, even after a failure or timeout
+	if len(workflow.Finally) > 0 {
+		var finallyErr error
+		result.Finally, finallyErr = exec.runFinally(ctx, err)
+		if finallyErr != nil {
+			result.Success = false
+			if err == nil {
+				err = finallyErr
+			}
 		}
-		
-		output, err := task.Execute(ctx)
-		if err != nil {
-			taskResult.Error = err.Error()
-			taskResult.Success = false
+	}
+
+	// Outputs are only meaningful when every task they may refer to succeeded
+	if err == nil && workflow.Outputs != nil {
+		outputs, outputsErr := renderConfig(workflow.Outputs, exec.newScope(nil))
+		if outputsErr != nil {
 			result.Success = false
-			result.Tasks = append(result.Tasks, taskResult)
-			return result, fmt.Errorf("task %d (%s): %w", i, taskDef.Name, err)
+			err = fmt.Errorf("invalid outputs: %w", outputsErr)
 		}
-		
-		taskResult.Output = output
-		taskResult.Success = true
-		result.Tasks = append(result.Tasks, taskResult)
+		result.Outputs = secrets.redactValue(outputs).(map[string]interface{})
 	}
-	
-	return result, nil
+
+	return result, secrets.redactError(err)
+}
+
+// factories looks up the factory of every task definition
+func (p *Probe) factories(kind string, defs []TaskDefinition) ([]TaskFactory, error) {
+	factories := make([]TaskFactory, len(defs))
+	for i, taskDef := range defs {
+		factory, ok := p.tasks[taskDef.Type]
+		if !ok {
+			return nil, fmt.Errorf("%s %d (%s): unknown task type: %s", kind, i, taskDef.Name, taskDef.Type)
+		}
+		factories[i] = factory
+	}
+	return factories, nil
 }
 
 // Workflow represents a YAML workflow definition
@@ -98,6 +230,24 @@ type Workflow struct {
 	Name    string           `yaml:"name"`
 	Timeout string           `yaml:"timeout,omitempty"`
 	Tasks   []TaskDefinition `yaml:"tasks"`
+
+	// Vars are workflow-level values available to task templates as vars.NAME
+	Vars map[string]interface{} `yaml:"vars,omitempty"`
+
+	// MaxParallel limits how many independent tasks run at the same time.
+	// Defaults to 1, which runs tasks one by one in declaration order.
+	MaxParallel int `yaml:"max_parallel,omitempty"`
+
+	// Finally lists tasks that run one by one after the main tasks, whatever
+	// their outcome (cleanup, notifications)
+	Finally []TaskDefinition `yaml:"finally,omitempty"`
+
+	// Outputs are templates resolved once the workflow succeeded, returned in
+	// WorkflowResult.Outputs and as the output of a workflow task running it
+	Outputs map[string]interface{} `yaml:"outputs,omitempty"`
+
+	// node is the parsed YAML, used to report line numbers
+	node *yaml.Node
 }
 
 // TaskDefinition defines a task in the workflow
@@ -105,20 +255,184 @@ type TaskDefinition struct {
 	Name   string                 `yaml:"name"`
 	Type   string                 `yaml:"type"`
 	Config map[string]interface{} `yaml:"config"`
+
+	// DependsOn lists the names of tasks that must succeed before this one starts
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// Retry controls how often the task is attempted when it fails
+	Retry *RetryPolicy `yaml:"retry,omitempty"`
+
+	// RetryUntil is a condition over the task output (e.g. `output.status_code == 200`)
+	// that must hold for an attempt to count as successful
+	RetryUntil string `yaml:"retry_until,omitempty"`
+
+	// When is a condition (e.g. `agent.os == "windows"`) that must hold for the
+	// task to run; otherwise the task is skipped
+	When string `yaml:"when,omitempty"`
+
+	// ContinueOnError records a failure of this task without failing the workflow
+	ContinueOnError bool `yaml:"continue_on_error,omitempty"`
+
+	// Loop repeats the task for every item of a list or range, available to
+	// templates as item and index
+	Loop *Loop `yaml:"loop,omitempty"`
+
+	// Matrix repeats the task for every combination of the listed values, with
+	// item holding one value per dimension (e.g. item.region)
+	Matrix map[string][]interface{} `yaml:"matrix,omitempty"`
+
+	// node is the parsed YAML, used to report line numbers
+	node *yaml.Node
+}
+
+// looped reports whether the task expands into several instances
+func (t *TaskDefinition) looped() bool {
+	return t.Loop != nil || t.Matrix != nil
 }
 
 // WorkflowResult contains the results of workflow execution
 type WorkflowResult struct {
-	Name    string
-	Tasks   []TaskResult
-	Success bool
+	Name     string
+	Tasks    []TaskResult
+	Finally  []TaskResult
+	Success  bool
+	TimedOut bool
+	Duration time.Duration
+
+	// Outputs holds the resolved workflow outputs of a successful run
+	Outputs map[string]interface{}
 }
 
 // TaskResult contains the result of a single task
 type TaskResult struct {
-	Name    string
-	Type    string
-	Output  interface{}
-	Success bool
-	Error   string
+	Name     string
+	Type     string
+	Status   TaskStatus
+	Output   interface{}
+	Success  bool
+	Error    string
+	Attempts []Attempt
+	Duration time.Duration
+
+	// Index and Item identify the instance of a looped task; Index is nil
+	// for tasks without a loop or matrix
+	Index *int
+	Item  interface{}
+
+	// Workflow holds the nested result of a workflow task
+	Workflow *WorkflowResult
+}
+
+// TaskStatus describes how a task ended
+type TaskStatus string
+
+const (
+	// StatusSuccess means the task ran and succeeded
+	StatusSuccess TaskStatus = "success"
+
+	// StatusFailed means the task ran and returned an error
+	StatusFailed TaskStatus = "failed"
+
+	// StatusTimedOut means the task was interrupted by the workflow timeout
+	StatusTimedOut TaskStatus = "timed_out"
+
+	// StatusSkipped means the task never started, because its when condition
+	// was false, a dependency failed or the workflow timed out
+	StatusSkipped TaskStatus = "skipped"
+)
+
+// validate checks the workflow structure and task settings and returns the
+// dependency graph. All problems found are returned as ValidationErrors.
+func (w *Workflow) validate() (*taskGraph, error) {
+	v := &validator{}
+	graph := w.check(v)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// check records every structural problem of the workflow and returns the
+// dependency graph, or nil if it could not be built
+func (w *Workflow) check(v *validator) *taskGraph {
+	graph, err := buildGraph(w.Tasks)
+	if err != nil {
+		v.add(keyNode(w.node, "tasks"), "", "", err)
+	}
+
+	if _, err := w.timeout(); err != nil {
+		v.add(keyNode(w.node, "timeout"), "", "timeout", err)
+	}
+
+	for i, taskDef := range w.Tasks {
+		if err := taskDef.validate(); err != nil {
+			v.add(taskDef.node, fmt.Sprintf("task %d (%s)", i, taskDef.Name), "", err)
+		}
+	}
+
+	names := make(map[string]bool, len(w.Tasks))
+	for _, taskDef := range w.Tasks {
+		names[taskDef.Name] = true
+	}
+	for i, taskDef := range w.Finally {
+		label := fmt.Sprintf("finally task %d (%s)", i, taskDef.Name)
+		if len(taskDef.DependsOn) > 0 {
+			v.add(keyNode(taskDef.node, "depends_on"), label, "depends_on", fmt.Errorf("depends_on is not supported in finally tasks"))
+		}
+		if taskDef.Name != "" && names[taskDef.Name] {
+			v.add(keyNode(taskDef.node, "name"), label, "name", fmt.Errorf("duplicate task name %q", taskDef.Name))
+		}
+		names[taskDef.Name] = true
+		if err := taskDef.validate(); err != nil {
+			v.add(taskDef.node, label, "", err)
+		}
+	}
+
+	return graph
+}
+
+// validate checks the retry policy, conditions and loop of a task definition
+func (t *TaskDefinition) validate() error {
+	if t.Loop != nil && t.Matrix != nil {
+		return fmt.Errorf("loop and matrix cannot be combined")
+	}
+	if t.Loop != nil {
+		if err := t.Loop.validate(); err != nil {
+			return err
+		}
+	}
+	for dim, values := range t.Matrix {
+		if len(values) == 0 {
+			return fmt.Errorf("matrix dimension %q has no values", dim)
+		}
+	}
+	if matrixSize(t.Matrix) > maxInstances {
+		return fmt.Errorf("matrix has more than %d combinations", maxInstances)
+	}
+	if _, err := t.Retry.schedule(); err != nil {
+		return err
+	}
+	if t.RetryUntil != "" {
+		if err := checkCondition(t.RetryUntil, retryUntilRoots...); err != nil {
+			return fmt.Errorf("invalid retry_until: %w", err)
+		}
+	}
+	if t.When != "" {
+		if err := checkCondition(t.When, whenRoots...); err != nil {
+			return fmt.Errorf("invalid when: %w", err)
+		}
+	}
+	return nil
+}
+
+// timeout parses the workflow-level timeout; zero means no deadline
+func (w *Workflow) timeout() (time.Duration, error) {
+	if w.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(w.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	return d, nil
 }
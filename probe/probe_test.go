@@ -2,10 +2,14 @@ package probe
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestProbeHTTPTask(t *testing.T) {
@@ -93,3 +97,424 @@ func TestProbeInvalidYAML(t *testing.T) {
 		t.Errorf("Expected error for invalid YAML")
 	}
 }
+
+// recorder tracks task start order and peak concurrency for engine tests
+type recorder struct {
+	mu      sync.Mutex
+	order   []string
+	active  int
+	maxSeen int
+}
+
+// recordTask is a test task that sleeps and records when it runs
+type recordTask struct {
+	rec   *recorder
+	name  string
+	sleep time.Duration
+	fail  bool
+}
+
+func (t *recordTask) Configure(config map[string]interface{}) error {
+	t.name, _ = config["id"].(string)
+	if sleep, ok := config["sleep"].(string); ok {
+		d, err := time.ParseDuration(sleep)
+		if err != nil {
+			return err
+		}
+		t.sleep = d
+	}
+	t.fail, _ = config["fail"].(bool)
+	return nil
+}
+
+func (t *recordTask) Execute(ctx context.Context) (interface{}, error) {
+	t.rec.mu.Lock()
+	t.rec.order = append(t.rec.order, t.name)
+	t.rec.active++
+	if t.rec.active > t.rec.maxSeen {
+		t.rec.maxSeen = t.rec.active
+	}
+	t.rec.mu.Unlock()
+
+	time.Sleep(t.sleep)
+
+	t.rec.mu.Lock()
+	t.rec.active--
+	t.rec.mu.Unlock()
+
+	if t.fail {
+		return nil, fmt.Errorf("%s failed", t.name)
+	}
+	return t.name, nil
+}
+
+func newRecordingProbe() (*Probe, *recorder) {
+	rec := &recorder{}
+	p := New()
+	p.RegisterTask("record", func() Task { return &recordTask{rec: rec} })
+	return p, rec
+}
+
+func TestProbeDependsOnOrder(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-depends-on
+tasks:
+  - name: deploy
+    type: record
+    depends_on: [build, migrate]
+    config: {id: deploy}
+  - name: build
+    type: record
+    config: {id: build}
+  - name: migrate
+    type: record
+    depends_on: [build]
+    config: {id: migrate}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if got := strings.Join(rec.order, ","); got != "build,migrate,deploy" {
+		t.Errorf("Expected order build,migrate,deploy, got %s", got)
+	}
+
+	// Results are reported in declaration order
+	if len(result.Tasks) != 3 || result.Tasks[0].Name != "deploy" {
+		t.Errorf("Expected results in declaration order, got %+v", result.Tasks)
+	}
+}
+
+func TestProbeMaxParallel(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-max-parallel
+max_parallel: 2
+tasks:
+  - {name: a, type: record, config: {id: a, sleep: 50ms}}
+  - {name: b, type: record, config: {id: b, sleep: 50ms}}
+  - {name: c, type: record, config: {id: c, sleep: 50ms}}
+  - {name: d, type: record, config: {id: d, sleep: 50ms}}
+  - {name: e, type: record, depends_on: [a, b, c, d], config: {id: e}}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if !result.Success || len(result.Tasks) != 5 {
+		t.Fatalf("Expected 5 successful tasks, got %+v", result.Tasks)
+	}
+
+	if rec.maxSeen != 2 {
+		t.Errorf("Expected 2 concurrent tasks, saw %d", rec.maxSeen)
+	}
+
+	if rec.order[len(rec.order)-1] != "e" {
+		t.Errorf("Expected e to run last, got %v", rec.order)
+	}
+}
+
+func TestProbeDependencyFailure(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-failure
+tasks:
+  - {name: a, type: record, config: {id: a, fail: true}}
+  - {name: b, type: record, depends_on: [a], config: {id: b}}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatalf("Expected error when a dependency fails")
+	}
+
+	if result.Success {
+		t.Errorf("Expected failure")
+	}
+
+	if len(rec.order) != 1 {
+		t.Errorf("Expected dependent task not to run, ran %v", rec.order)
+	}
+
+	if len(result.Tasks) != 2 || result.Tasks[1].Status != StatusSkipped {
+		t.Errorf("Expected dependent task to be reported as skipped, got %+v", result.Tasks)
+	}
+}
+
+func TestProbeDependencyCycle(t *testing.T) {
+	p := New()
+
+	yaml := `
+name: test-cycle
+tasks:
+  - {name: a, type: command, depends_on: [c], config: {command: echo}}
+  - {name: b, type: command, depends_on: [a], config: {command: echo}}
+  - {name: c, type: command, depends_on: [b], config: {command: echo}}
+`
+
+	_, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Expected cycle error, got %v", err)
+	}
+}
+
+func TestProbeUnknownDependency(t *testing.T) {
+	_, err := ParseWorkflow([]byte(`
+name: test-unknown-dependency
+tasks:
+  - {name: a, type: command, depends_on: [missing], config: {command: echo}}
+`))
+	if err == nil {
+		t.Errorf("Expected error for unknown dependency")
+	}
+}
+
+func TestProbeWorkflowTimeout(t *testing.T) {
+	p, _ := newRecordingProbe()
+
+	yaml := `
+name: test-timeout
+timeout: 100ms
+tasks:
+  - {name: quick, type: record, config: {id: quick}}
+  - {name: slow, type: record, depends_on: [quick], config: {id: slow, sleep: 2s}}
+  - {name: after, type: record, depends_on: [slow], config: {id: after}}
+`
+
+	start := time.Now()
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatalf("Expected timeout error")
+	}
+
+	// The slow task ignores cancellation, so the engine must not wait for it
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected workflow to stop at the deadline, took %s", elapsed)
+	}
+
+	if !result.TimedOut || result.Success {
+		t.Errorf("Expected timed out failure, got %+v", result)
+	}
+
+	want := []TaskStatus{StatusSuccess, StatusTimedOut, StatusSkipped}
+	if len(result.Tasks) != len(want) {
+		t.Fatalf("Expected %d task results, got %d", len(want), len(result.Tasks))
+	}
+	for i, status := range want {
+		if result.Tasks[i].Status != status {
+			t.Errorf("Task %s: expected status %s, got %s", result.Tasks[i].Name, status, result.Tasks[i].Status)
+		}
+	}
+}
+
+func TestProbeInvalidWorkflowTimeout(t *testing.T) {
+	_, err := ParseWorkflow([]byte(`
+name: test-invalid-timeout
+timeout: soon
+tasks:
+  - {name: a, type: command, config: {command: echo}}
+`))
+	if err == nil {
+		t.Errorf("Expected error for invalid timeout")
+	}
+}
+
+func TestProbeWhenCondition(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-when
+vars:
+  environment: staging
+tasks:
+  - name: other-os
+    type: record
+    when: agent.os == "plan9"
+    config: {id: other-os}
+  - name: staging-only
+    type: record
+    when: vars.environment == "staging"
+    config: {id: staging-only}
+  - name: after-skipped
+    type: record
+    depends_on: [other-os]
+    config: {id: after-skipped}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if got := strings.Join(rec.order, ","); got != "staging-only,after-skipped" {
+		t.Errorf("Expected staging-only,after-skipped to run, got %s", got)
+	}
+
+	if result.Tasks[0].Status != StatusSkipped || !result.Success {
+		t.Errorf("Expected skipped task in successful workflow, got %+v", result)
+	}
+}
+
+func TestProbeContinueOnError(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-continue-on-error
+tasks:
+  - name: optional
+    type: record
+    continue_on_error: true
+    config: {id: optional, fail: true}
+  - name: next
+    type: record
+    depends_on: [optional]
+    when: not tasks.optional.success
+    config: {id: next}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if len(rec.order) != 2 {
+		t.Errorf("Expected both tasks to run, got %v", rec.order)
+	}
+
+	if !result.Success || result.Tasks[0].Status != StatusFailed {
+		t.Errorf("Expected tolerated failure, got %+v", result)
+	}
+}
+
+func TestProbeFinally(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-finally
+tasks:
+  - {name: deploy, type: record, config: {id: deploy, fail: true}}
+  - {name: verify, type: record, config: {id: verify}}
+finally:
+  - name: notify-failure
+    type: record
+    when: not workflow.success
+    config: {id: notify-failure}
+  - name: notify-success
+    type: record
+    when: workflow.success
+    config: {id: notify-success}
+  - name: cleanup
+    type: record
+    config: {id: cleanup}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || !strings.Contains(err.Error(), "deploy") {
+		t.Fatalf("Expected the main task error, got %v", err)
+	}
+
+	if got := strings.Join(rec.order, ","); got != "deploy,notify-failure,cleanup" {
+		t.Errorf("Expected deploy,notify-failure,cleanup, got %s", got)
+	}
+
+	if len(result.Finally) != 3 || result.Finally[1].Status != StatusSkipped {
+		t.Errorf("Unexpected finally results: %+v", result.Finally)
+	}
+}
+
+func TestProbeFinallyAfterTimeout(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	// The slow task ignores cancellation and evaluates retry_until while the
+	// finally task runs, which must not race with the workflow outcome
+	yaml := `
+name: test-finally-timeout
+timeout: 50ms
+tasks:
+  - name: slow
+    type: record
+    retry_until: output == "slow"
+    config: {id: slow, sleep: 200ms}
+finally:
+  - name: cleanup
+    type: record
+    when: workflow.timed_out
+    config: {id: cleanup, sleep: 400ms}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || !result.TimedOut {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if len(result.Finally) != 1 || result.Finally[0].Status != StatusSuccess {
+		t.Errorf("Expected cleanup to run, got %+v", result.Finally)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if got := strings.Join(rec.order, ","); got != "slow,cleanup" {
+		t.Errorf("Expected slow,cleanup, got %s", got)
+	}
+}
+
+// cancelOnTaskEnd cancels the workflow once the given task has ended
+type cancelOnTaskEnd struct {
+	eventLog
+	task   string
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnTaskEnd) OnTaskEnd(e TaskEvent) {
+	if e.Task == c.task {
+		c.cancel()
+	}
+}
+
+func TestProbeCancelBetweenTasks(t *testing.T) {
+	p, rec := newRecordingProbe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.RegisterObserver(&cancelOnTaskEnd{task: "first", cancel: cancel})
+
+	yaml := `
+name: test-cancel
+tasks:
+  - {name: first, type: record, config: {id: first}}
+  - {name: second, type: record, depends_on: [first], config: {id: second}}
+`
+
+	result, err := p.ExecuteYAML(ctx, []byte(yaml))
+	if !errors.Is(err, context.Canceled) || result.Success {
+		t.Fatalf("Expected the cancellation to fail the workflow, got %v", err)
+	}
+	if got := strings.Join(rec.order, ","); got != "first" {
+		t.Errorf("Expected only first to run, got %s", got)
+	}
+	if len(result.Tasks) != 2 || result.Tasks[1].Status != StatusSkipped {
+		t.Errorf("Expected second to be skipped, got %+v", result.Tasks)
+	}
+}
+
+func TestProbeFinallyFailure(t *testing.T) {
+	p, _ := newRecordingProbe()
+
+	yaml := `
+name: test-finally-failure
+tasks:
+  - {name: deploy, type: record, config: {id: deploy}}
+finally:
+  - {name: cleanup, type: record, config: {id: cleanup, fail: true}}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || result.Success {
+		t.Errorf("Expected a failing finally task to fail the workflow")
+	}
+}
@@ -0,0 +1,150 @@
+//go:build !windows
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in a process group of its own, and makes
+// cancelling cmd kill the whole group so that no grandchildren are left behind
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// setCredential makes cmd run as the given user and group, each a name or a
+// numeric id. Without a group the user's primary group is used, so numeric
+// ids without a passwd entry need one. Supplementary groups can only be set
+// when running as root.
+func setCredential(cmd *exec.Cmd, username, group string) error {
+	cred := &syscall.Credential{
+		Uid:         uint32(os.Getuid()),
+		Gid:         uint32(os.Getgid()),
+		NoSetGroups: os.Getuid() != 0,
+	}
+
+	if username != "" {
+		u, err := lookupUser(username)
+		if err != nil {
+			return err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("user %s has no numeric id", username)
+		}
+		if u.Gid == "" && group == "" {
+			return fmt.Errorf("user %s has no passwd entry, so a group is required", username)
+		}
+		cred.Uid = uint32(uid)
+		if u.Gid != "" {
+			gid, err := strconv.ParseUint(u.Gid, 10, 32)
+			if err != nil {
+				return fmt.Errorf("user %s has no numeric group id", username)
+			}
+			cred.Gid = uint32(gid)
+		}
+
+		if !cred.NoSetGroups {
+			groupIDs, _ := u.GroupIds()
+			for _, id := range groupIDs {
+				if gid, err := strconv.ParseUint(id, 10, 32); err == nil {
+					cred.Groups = append(cred.Groups, uint32(gid))
+				}
+			}
+		}
+	}
+
+	if group != "" {
+		g, err := lookupGroup(group)
+		if err != nil {
+			return err
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("group %s has no numeric id", group)
+		}
+		cred.Gid = uint32(gid)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = cred
+	return nil
+}
+
+// lookupUser finds a user by name or numeric id
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		// Ids without a passwd entry are used as they are, with no group
+		return &user.User{Uid: name}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %s: %w", name, err)
+	}
+	return u, nil
+}
+
+// lookupGroup finds a group by name or numeric id
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return &user.Group{Gid: name}, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown group %s: %w", name, err)
+	}
+	return g, nil
+}
+
+// processRunning reports whether a process with the given executable name is
+// running, reading /proc where it exists and asking pgrep otherwise
+func processRunning(ctx context.Context, name string) (bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		err := exec.CommandContext(ctx, "pgrep", "-x", name).Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to list processes: %w", err)
+		}
+		return true, nil
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		// comm is truncated to 15 characters, so the command line is checked too
+		comm, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if string(bytes.TrimSpace(comm)) == name {
+			return true, nil
+		}
+		cmdline, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if argv0, _, _ := bytes.Cut(cmdline, []byte{0}); len(argv0) > 0 && filepath.Base(string(argv0)) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
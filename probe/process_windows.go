@@ -0,0 +1,49 @@
+//go:build windows
+
+package probe
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// killProcessGroup makes cancelling cmd kill its whole process tree so that
+// no grandchildren are left behind
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
+
+// setCredential is not supported on Windows
+func setCredential(cmd *exec.Cmd, username, group string) error {
+	return fmt.Errorf("running as another user is not supported on Windows")
+}
+
+// processRunning reports whether a process with the given image name, with
+// or without the .exe extension, is running
+func processRunning(ctx context.Context, name string) (bool, error) {
+	out, err := exec.CommandContext(ctx, "tasklist", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list processes: %w", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the process list: %w", err)
+	}
+	for _, record := range records {
+		image := record[0]
+		if strings.EqualFold(image, name) || strings.EqualFold(strings.TrimSuffix(strings.ToLower(image), ".exe"), name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
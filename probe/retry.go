@@ -0,0 +1,136 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how a failing task is attempted again
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first (default: 3)
+	Attempts int `yaml:"attempts,omitempty"`
+
+	// Delay is the wait before the first retry (default: 1s)
+	Delay string `yaml:"delay,omitempty"`
+
+	// Backoff grows the delay between retries: constant (default), linear or exponential
+	Backoff string `yaml:"backoff,omitempty"`
+
+	// MaxDelay caps the delay between retries
+	MaxDelay string `yaml:"max_delay,omitempty"`
+
+	// Jitter randomly varies each delay by up to this fraction (0-1)
+	Jitter float64 `yaml:"jitter,omitempty"`
+}
+
+// Attempt records a single execution of a task
+type Attempt struct {
+	Number   int
+	Duration time.Duration
+	Error    string
+}
+
+// retrySchedule is a parsed and validated RetryPolicy
+type retrySchedule struct {
+	attempts int
+	delay    time.Duration
+	backoff  string
+	maxDelay time.Duration
+	jitter   float64
+}
+
+// schedule validates the policy and fills in defaults. A nil policy means a
+// single attempt.
+func (r *RetryPolicy) schedule() (*retrySchedule, error) {
+	if r == nil {
+		return &retrySchedule{attempts: 1}, nil
+	}
+
+	s := &retrySchedule{
+		attempts: r.Attempts,
+		delay:    time.Second,
+		backoff:  r.Backoff,
+		jitter:   r.Jitter,
+	}
+
+	if s.attempts == 0 {
+		s.attempts = 3
+	}
+	if s.attempts < 0 {
+		return nil, fmt.Errorf("retry attempts must be positive")
+	}
+
+	if r.Delay != "" {
+		d, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry delay: %w", err)
+		}
+		s.delay = d
+	}
+
+	if r.MaxDelay != "" {
+		d, err := time.ParseDuration(r.MaxDelay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry max_delay: %w", err)
+		}
+		s.maxDelay = d
+	}
+
+	switch s.backoff {
+	case "":
+		s.backoff = "constant"
+	case "constant", "linear", "exponential":
+	default:
+		return nil, fmt.Errorf("invalid retry backoff %q (expected constant, linear or exponential)", s.backoff)
+	}
+
+	if s.jitter < 0 || s.jitter > 1 {
+		return nil, fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+
+	return s, nil
+}
+
+// wait returns the delay before the given retry, where retry 1 follows the first attempt
+func (s *retrySchedule) wait(retry int) time.Duration {
+	d := s.delay
+	switch s.backoff {
+	case "linear":
+		d = s.delay * time.Duration(retry)
+	case "exponential":
+		// Stop doubling before the delay overflows
+		for i := 1; i < retry && (s.maxDelay == 0 || d < s.maxDelay) && d <= math.MaxInt64/2; i++ {
+			d *= 2
+		}
+	}
+
+	if s.maxDelay > 0 && d > s.maxDelay {
+		d = s.maxDelay
+	}
+
+	if s.jitter > 0 {
+		jittered := float64(d) * (1 + s.jitter*(2*rand.Float64()-1))
+		if jittered >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+		d = time.Duration(jittered)
+	}
+
+	return d
+}
+
+// sleep waits for the given duration or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,159 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryScheduleWait(t *testing.T) {
+	tests := []struct {
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		{RetryPolicy{Delay: "1s"}, []time.Duration{time.Second, time.Second, time.Second}},
+		{RetryPolicy{Delay: "1s", Backoff: "linear"}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+		{RetryPolicy{Delay: "1s", Backoff: "exponential"}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+		{RetryPolicy{Delay: "1s", Backoff: "exponential", MaxDelay: "3s"}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := tt.policy.schedule()
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", tt.policy, err)
+		}
+		for i, want := range tt.want {
+			if got := schedule.wait(i + 1); got != want {
+				t.Errorf("%+v: retry %d: expected %s, got %s", tt.policy, i+1, want, got)
+			}
+		}
+	}
+}
+
+func TestRetryScheduleJitter(t *testing.T) {
+	schedule, err := (&RetryPolicy{Delay: "1s", Jitter: 0.5}).schedule()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if d := schedule.wait(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Errorf("jittered delay out of range: %s", d)
+		}
+	}
+}
+
+func TestRetryScheduleNoOverflow(t *testing.T) {
+	for _, policy := range []RetryPolicy{
+		{Attempts: 100, Delay: "1s", Backoff: "exponential"},
+		{Attempts: 100, Delay: "1s", Backoff: "exponential", Jitter: 0.5},
+	} {
+		schedule, err := policy.schedule()
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", policy, err)
+		}
+		previous := time.Duration(0)
+		for retry := 1; retry < schedule.attempts; retry++ {
+			d := schedule.wait(retry)
+			if d <= 0 || (policy.Jitter == 0 && d < previous) {
+				t.Fatalf("%+v: retry %d: delay overflowed to %s", policy, retry, d)
+			}
+			previous = d
+		}
+	}
+}
+
+func TestRetryScheduleInvalid(t *testing.T) {
+	for _, policy := range []RetryPolicy{
+		{Delay: "soon"},
+		{MaxDelay: "later"},
+		{Backoff: "fibonacci"},
+		{Jitter: 2},
+		{Attempts: -1},
+	} {
+		if _, err := policy.schedule(); err == nil {
+			t.Errorf("%+v: expected error", policy)
+		}
+	}
+}
+
+func TestProbeRetryUntil(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := New()
+
+	yaml := fmt.Sprintf(`
+name: test-retry-until
+tasks:
+  - name: wait-for-service
+    type: http
+    retry:
+      attempts: 5
+      delay: 10ms
+      backoff: exponential
+    retry_until: output.status_code == 200
+    config:
+      url: %s
+      expected_status: [200, 503]
+`, server.URL)
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	attempts := result.Tasks[0].Attempts
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+	if attempts[0].Error == "" || attempts[2].Error != "" || attempts[2].Number != 3 {
+		t.Errorf("Unexpected attempt records: %+v", attempts)
+	}
+}
+
+func TestProbeRetryExhausted(t *testing.T) {
+	p, rec := newRecordingProbe()
+
+	yaml := `
+name: test-retry-exhausted
+tasks:
+  - name: flaky
+    type: record
+    retry: {attempts: 3, delay: 1ms}
+    config: {id: flaky, fail: true}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatalf("Expected error after exhausting retries")
+	}
+
+	if len(rec.order) != 3 || len(result.Tasks[0].Attempts) != 3 {
+		t.Errorf("Expected 3 attempts, got %d runs and %+v", len(rec.order), result.Tasks[0].Attempts)
+	}
+}
+
+func TestProbeInvalidRetryUntil(t *testing.T) {
+	_, err := ParseWorkflow([]byte(`
+name: test-invalid-retry-until
+tasks:
+  - name: a
+    type: command
+    retry_until: output.exit_code ==
+    config: {command: echo}
+`))
+	if err == nil {
+		t.Errorf("Expected error for invalid retry_until")
+	}
+}
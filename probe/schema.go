@@ -0,0 +1,418 @@
+package probe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of a task config value
+type FieldType string
+
+const (
+	FieldString   FieldType = "string"
+	FieldInt      FieldType = "integer"
+	FieldNumber   FieldType = "number"
+	FieldBool     FieldType = "boolean"
+	FieldDuration FieldType = "duration"
+	FieldList     FieldType = "array"
+	FieldMap      FieldType = "object"
+	FieldAny      FieldType = "any"
+)
+
+// TaskSpec describes the configuration accepted by a task type
+type TaskSpec struct {
+	Description string
+	Fields      []FieldSpec
+
+	// Platforms lists the operating systems (GOOS values) the task can be
+	// configured on; empty means all of them
+	Platforms []string
+}
+
+// FieldSpec describes a single config key
+type FieldSpec struct {
+	Name        string
+	Type        FieldType
+	Required    bool
+	Description string
+
+	// Items is the type of list elements or map values
+	Items FieldType
+
+	// Fields describes the keys of a map; when empty any key is accepted
+	Fields []FieldSpec
+
+	// Enum restricts a string to the listed values
+	Enum []string
+
+	// Default is documented in the schema only; Configure applies defaults
+	Default interface{}
+}
+
+// supports reports whether the task can be configured on the given OS
+func (s TaskSpec) supports(goos string) bool {
+	if len(s.Platforms) == 0 {
+		return true
+	}
+	for _, p := range s.Platforms {
+		if p == goos {
+			return true
+		}
+	}
+	return false
+}
+
+// configError is a problem with a single config key
+type configError struct {
+	path    []string
+	message string
+}
+
+// checkConfig checks config values against the field specs and returns every
+// unknown key, missing required key and mistyped value. Values containing
+// templates are only known at run time and are not type checked.
+func checkConfig(fields []FieldSpec, config map[string]interface{}, path []string) []configError {
+	var errs []configError
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+		fieldPath := append(append([]string{}, path...), f.Name)
+		v, ok := config[f.Name]
+		if !ok || v == nil {
+			if f.Required {
+				errs = append(errs, configError{fieldPath, "is required"})
+			}
+			continue
+		}
+		errs = append(errs, checkField(f, v, fieldPath)...)
+	}
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		errs = append(errs, configError{append(append([]string{}, path...), k), "unknown key"})
+	}
+	return errs
+}
+
+// checkField checks a single value against its spec
+func checkField(f FieldSpec, v interface{}, path []string) []configError {
+	if s, ok := v.(string); ok && strings.Contains(s, "{{") {
+		return nil
+	}
+	if err := checkType(f.Type, v); err != nil {
+		return []configError{{path, err.Error()}}
+	}
+
+	var errs []configError
+	switch x := v.(type) {
+	case string:
+		if len(f.Enum) > 0 && !containsString(f.Enum, x) {
+			errs = append(errs, configError{path, fmt.Sprintf("must be one of %s", strings.Join(f.Enum, ", "))})
+		}
+	case []interface{}:
+		if f.Items != "" {
+			for i, item := range x {
+				if s, ok := item.(string); ok && strings.Contains(s, "{{") {
+					continue
+				}
+				if err := checkType(f.Items, item); err != nil {
+					errs = append(errs, configError{path, fmt.Sprintf("item %d %s", i, err)})
+				}
+			}
+		}
+	case map[string]interface{}:
+		if len(f.Fields) > 0 {
+			return checkConfig(f.Fields, x, path)
+		}
+		if f.Items != "" {
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if s, ok := x[k].(string); ok && strings.Contains(s, "{{") {
+					continue
+				}
+				if err := checkType(f.Items, x[k]); err != nil {
+					errs = append(errs, configError{append(append([]string{}, path...), k), err.Error()})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// checkType reports whether a value decoded from YAML or JSON has the given type
+func checkType(t FieldType, v interface{}) error {
+	ok := true
+	switch t {
+	case FieldString:
+		_, ok = v.(string)
+	case FieldInt:
+		switch n := v.(type) {
+		case int, int64, uint64:
+		case float64:
+			ok = n == float64(int64(n))
+		default:
+			ok = false
+		}
+	case FieldNumber:
+		switch v.(type) {
+		case int, int64, uint64, float64:
+		default:
+			ok = false
+		}
+	case FieldBool:
+		_, ok = v.(bool)
+	case FieldDuration:
+		s, isString := v.(string)
+		if !isString {
+			ok = false
+			break
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("must be a duration such as 30s or 5m")
+		}
+	case FieldList:
+		_, ok = v.([]interface{})
+	case FieldMap:
+		_, ok = v.(map[string]interface{})
+	}
+	if !ok {
+		return fmt.Errorf("must be %s, got %s", typeName(t), valueTypeName(v))
+	}
+	return nil
+}
+
+// typeName describes a field type in error messages
+func typeName(t FieldType) string {
+	switch t {
+	case FieldInt:
+		return "an integer"
+	case FieldList:
+		return "a list"
+	case FieldMap:
+		return "a map"
+	case FieldDuration:
+		return "a duration"
+	}
+	return "a " + string(t)
+}
+
+// valueTypeName describes the type of a decoded value in error messages
+func valueTypeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// durationPattern matches Go duration strings in the JSON Schema
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema returns a JSON Schema (draft 2020-12) describing workflows for the
+// registered task types. Config keys are described for tasks implementing
+// Describer; the config of other tasks accepts any keys.
+func (p *Probe) Schema() map[string]interface{} {
+	types := make([]string, 0, len(p.tasks))
+	for t := range p.tasks {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var conditions []interface{}
+	for _, t := range types {
+		describer, ok := p.tasks[t]().(Describer)
+		if !ok {
+			continue
+		}
+		spec := describer.Describe()
+		config := objectSchema(spec.Fields)
+		if spec.Description != "" {
+			config["description"] = spec.Description
+		}
+		conditions = append(conditions, map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{"type": map[string]interface{}{"const": t}},
+			},
+			"then": map[string]interface{}{
+				"properties": map[string]interface{}{"config": config},
+			},
+		})
+	}
+
+	stringSchema := map[string]interface{}{"type": "string"}
+	durationSchema := map[string]interface{}{"type": "string", "pattern": durationPattern}
+
+	task := map[string]interface{}{
+		"type":                 "object",
+		"required":             []string{"type"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":              stringSchema,
+			"type":              map[string]interface{}{"enum": types},
+			"config":            map[string]interface{}{"type": "object"},
+			"depends_on":        map[string]interface{}{"type": "array", "items": stringSchema},
+			"retry_until":       stringSchema,
+			"when":              stringSchema,
+			"continue_on_error": map[string]interface{}{"type": "boolean"},
+			"retry": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"attempts":  map[string]interface{}{"type": "integer", "minimum": 1},
+					"delay":     durationSchema,
+					"backoff":   map[string]interface{}{"enum": []string{"constant", "linear", "exponential"}},
+					"max_delay": durationSchema,
+					"jitter":    map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+				},
+			},
+			"loop": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "array"},
+					stringSchema,
+					map[string]interface{}{
+						"type":                 "object",
+						"required":             []string{"start", "end"},
+						"additionalProperties": false,
+						"properties": map[string]interface{}{
+							"start": map[string]interface{}{"type": "integer"},
+							"end":   map[string]interface{}{"type": "integer"},
+							"step":  map[string]interface{}{"type": "integer"},
+						},
+					},
+				},
+			},
+			"matrix": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "array", "minItems": 1},
+			},
+		},
+	}
+	if len(conditions) > 0 {
+		task["allOf"] = conditions
+	}
+
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Probe workflow",
+		"type":                 "object",
+		"required":             []string{"name", "tasks"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":         stringSchema,
+			"timeout":      durationSchema,
+			"vars":         map[string]interface{}{"type": "object"},
+			"outputs":      map[string]interface{}{"type": "object"},
+			"max_parallel": map[string]interface{}{"type": "integer", "minimum": 1},
+			"tasks":        map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/task"}},
+			"finally":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/task"}},
+		},
+		"$defs": map[string]interface{}{
+			"task": task,
+		},
+	}
+}
+
+// objectSchema returns the JSON Schema of a map with the given fields
+func objectSchema(fields []FieldSpec) map[string]interface{} {
+	properties := make(map[string]interface{}, len(fields))
+	var required []string
+	for _, f := range fields {
+		properties[f.Name] = fieldSchema(f)
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fieldSchema returns the JSON Schema of a single config key. Since any value
+// may be given as a template, non-string fields also accept template strings.
+func fieldSchema(f FieldSpec) map[string]interface{} {
+	var schema map[string]interface{}
+	switch {
+	case f.Type == FieldMap && len(f.Fields) > 0:
+		schema = objectSchema(f.Fields)
+	default:
+		schema = typeSchema(f.Type)
+		if f.Items != "" {
+			switch f.Type {
+			case FieldList:
+				schema["items"] = typeSchema(f.Items)
+			case FieldMap:
+				schema["additionalProperties"] = typeSchema(f.Items)
+			}
+		}
+	}
+	if len(f.Enum) > 0 {
+		schema["enum"] = f.Enum
+	}
+
+	if (f.Type != FieldString || len(f.Enum) > 0) && f.Type != FieldAny {
+		schema = map[string]interface{}{
+			"anyOf": []interface{}{
+				schema,
+				map[string]interface{}{"type": "string", "pattern": `\{\{`},
+			},
+		}
+	}
+	if f.Description != "" {
+		schema["description"] = f.Description
+	}
+	if f.Default != nil {
+		schema["default"] = f.Default
+	}
+	return schema
+}
+
+// typeSchema returns the JSON Schema of a field type
+func typeSchema(t FieldType) map[string]interface{} {
+	switch t {
+	case FieldDuration:
+		return map[string]interface{}{"type": "string", "pattern": durationPattern}
+	case FieldAny, "":
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"type": string(t)}
+}
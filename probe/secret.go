@@ -0,0 +1,281 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretResolver looks up secret values by name. Workflows reference secrets
+// with the secret template function, e.g. {{ secret "db_password" }}.
+type SecretResolver interface {
+	// Resolve returns the value of the named secret, or an error wrapping
+	// ErrSecretNotFound when the resolver does not know it
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// ErrSecretNotFound is returned by resolvers that do not know a secret
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretResolverFunc adapts a function to the SecretResolver interface
+type SecretResolverFunc func(ctx context.Context, name string) (string, error)
+
+// Resolve calls f(ctx, name)
+func (f SecretResolverFunc) Resolve(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// EnvSecrets resolves secrets from environment variables named Prefix
+// followed by the upper-cased secret name, e.g. PROBE_SECRET_DB_PASSWORD.
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Resolve reads the environment variable of the secret
+func (s EnvSecrets) Resolve(ctx context.Context, name string) (string, error) {
+	key := s.Prefix + strings.ToUpper(name)
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, key)
+	}
+	return value, nil
+}
+
+// FileSecrets resolves secrets from files named after the secret in Dir, as
+// mounted by Docker and Kubernetes secrets. A trailing newline is removed.
+type FileSecrets struct {
+	Dir string
+}
+
+// Resolve reads the file of the secret
+func (s FileSecrets) Resolve(ctx context.Context, name string) (string, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: no file %s in %s", ErrSecretNotFound, name, s.Dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// RegisterSecretResolver adds a resolver for the secret template function.
+// Resolvers are tried in registration order until one knows the secret.
+func (p *Probe) RegisterSecretResolver(r SecretResolver) {
+	p.secrets = append(p.secrets, r)
+}
+
+// resolveSecret asks the resolvers in order for a secret
+func resolveSecret(ctx context.Context, resolvers []SecretResolver, name string) (string, error) {
+	if len(resolvers) == 0 {
+		return "", fmt.Errorf("no secret resolver is registered")
+	}
+	for _, r := range resolvers {
+		value, err := r.Resolve(ctx, name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("secret %q: %w", name, err)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("secret %q: %w", name, ErrSecretNotFound)
+}
+
+// funcSecret stands in for the secret function outside a workflow run, so
+// that expressions using it parse during validation
+func funcSecret(args []interface{}) (interface{}, error) {
+	return nil, fmt.Errorf("secrets are only available while a workflow runs")
+}
+
+// redactedText replaces secret values in results, errors and output lines
+const redactedText = "***"
+
+// minSecretLength is the length below which values are not redacted, since
+// masking every occurrence of very short strings would garble the output
+const minSecretLength = 3
+
+type secretsKey struct{}
+
+// secretStore resolves the secrets of one workflow run, caching their values,
+// and redacts the values resolved so far
+type secretStore struct {
+	ctx       context.Context
+	resolvers []SecretResolver
+
+	mu     sync.RWMutex
+	values map[string]string
+
+	// masked lists the strings to redact, longest first so that a secret
+	// containing another one is masked whole
+	masked []string
+}
+
+func newSecretStore(ctx context.Context, resolvers []SecretResolver) *secretStore {
+	return &secretStore{
+		ctx:       ctx,
+		resolvers: resolvers,
+		values:    make(map[string]string),
+	}
+}
+
+// secret implements the secret template function
+func (s *secretStore) secret(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected a secret name")
+	}
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("secret name must be a non-empty string")
+	}
+
+	s.mu.RLock()
+	value, ok := s.values[name]
+	s.mu.RUnlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := resolveSecret(s.ctx, s.resolvers, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[name] = value
+	s.mask(value)
+	return value, nil
+}
+
+// mask adds a value to the redacted strings. Each line of a multi-line value
+// is masked as well, since output is reported line by line.
+func (s *secretStore) mask(value string) {
+	candidates := []string{value}
+	if strings.Contains(value, "\n") {
+		candidates = append(candidates, strings.Split(value, "\n")...)
+	}
+	for _, c := range candidates {
+		c = strings.TrimSuffix(c, "\r")
+		if len(strings.TrimSpace(c)) < minSecretLength || containsString(s.masked, c) {
+			continue
+		}
+		s.masked = append(s.masked, c)
+	}
+	sort.SliceStable(s.masked, func(i, j int) bool {
+		return len(s.masked[i]) > len(s.masked[j])
+	})
+}
+
+// redact replaces the secret values in a string
+func (s *secretStore) redact(str string) string {
+	if s == nil {
+		return str
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.masked {
+		str = strings.ReplaceAll(str, m, redactedText)
+	}
+	return str
+}
+
+// redactValue returns a copy of a task output with the secret values redacted
+// in every string it contains
+func (s *secretStore) redactValue(v interface{}) interface{} {
+	if s == nil {
+		return v
+	}
+	switch x := v.(type) {
+	case string:
+		return s.redact(x)
+	case []byte:
+		return s.redact(string(x))
+	case map[string]interface{}:
+		if x == nil {
+			return x
+		}
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			out[k] = s.redactValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = s.redactValue(item)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(x))
+		for i, item := range x {
+			out[i] = s.redactValue(item).(map[string]interface{})
+		}
+		return out
+	case []string:
+		out := make([]string, len(x))
+		for i, item := range x {
+			out[i] = s.redact(item)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(x))
+		for k, item := range x {
+			out[k] = s.redact(item)
+		}
+		return out
+	case map[string][]string:
+		out := make(map[string][]string, len(x))
+		for k, item := range x {
+			out[k] = s.redactValue(item).([]string)
+		}
+		return out
+	case http.Header:
+		return http.Header(s.redactValue(map[string][]string(x)).(map[string][]string))
+	}
+	return v
+}
+
+// redactResult redacts the secret values in a task result
+func (s *secretStore) redactResult(result *TaskResult) {
+	if s == nil {
+		return
+	}
+	result.Output = s.redactValue(result.Output)
+	result.Item = s.redactValue(result.Item)
+	result.Error = s.redact(result.Error)
+	for i := range result.Attempts {
+		result.Attempts[i].Error = s.redact(result.Attempts[i].Error)
+	}
+}
+
+// redactError returns an error whose message has the secret values redacted.
+// The original error stays available to errors.Is and errors.As.
+func (s *secretStore) redactError(err error) error {
+	if s == nil || err == nil {
+		return err
+	}
+	msg := s.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,153 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// leakTask echoes its text to stdout, into its output and into its error
+type leakTask struct {
+	text string
+}
+
+func (t *leakTask) Configure(config map[string]interface{}) error {
+	t.text, _ = config["text"].(string)
+	return nil
+}
+
+func (t *leakTask) Execute(ctx context.Context) (interface{}, error) {
+	fmt.Fprintln(OutputWriter(ctx, "stdout"), t.text)
+	output := map[string]interface{}{"text": t.text, "lines": []interface{}{t.text}}
+	return output, fmt.Errorf("rejected %s", t.text)
+}
+
+// mapSecrets resolves secrets from a map
+func mapSecrets(secrets map[string]string) SecretResolver {
+	return SecretResolverFunc(func(ctx context.Context, name string) (string, error) {
+		value, ok := secrets[name]
+		if !ok {
+			return "", ErrSecretNotFound
+		}
+		return value, nil
+	})
+}
+
+func TestSecretRedaction(t *testing.T) {
+	p := New()
+	p.RegisterTask("leak", func() Task { return &leakTask{} })
+	p.RegisterSecretResolver(mapSecrets(map[string]string{"token": "s3cr3t-token"}))
+	p.RegisterSecretResolver(mapSecrets(map[string]string{"password": "hunter22", "token": "shadowed"}))
+	events := &eventLog{}
+	p.RegisterObserver(events)
+
+	yaml := `
+name: test-secrets
+vars:
+  password: '{{ secret "password" }}'
+tasks:
+  - name: leak
+    type: leak
+    config:
+      text: 'token={{ secret "token" }} password={{ vars.password }}'
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil {
+		t.Fatal("Expected workflow to fail")
+	}
+
+	want := "token=*** password=***"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected redacted error, got %q", err)
+	}
+	r := result.Tasks[0]
+	output := r.Output.(map[string]interface{})
+	if output["text"] != want || output["lines"].([]interface{})[0] != want {
+		t.Errorf("Expected redacted output, got %v", output)
+	}
+	if r.Error != "rejected "+want || r.Attempts[0].Error != "rejected "+want {
+		t.Errorf("Expected redacted task error, got %q", r.Error)
+	}
+	for _, e := range events.events {
+		if strings.Contains(e, "s3cr3t") || strings.Contains(e, "hunter22") {
+			t.Errorf("Secret leaked into event %q", e)
+		}
+	}
+	if !containsString(events.events, "output leak stdout: "+want) {
+		t.Errorf("Expected redacted output line, got %v", events.events)
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	yaml := `
+name: test-missing-secret
+tasks:
+  - name: leak
+    type: leak
+    config:
+      text: '{{ secret "missing" }}'
+`
+
+	p := New()
+	p.RegisterTask("leak", func() Task { return &leakTask{} })
+	_, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || !strings.Contains(err.Error(), "no secret resolver is registered") {
+		t.Errorf("Expected error without resolvers, got %v", err)
+	}
+
+	p.RegisterSecretResolver(mapSecrets(nil))
+	_, err = p.ExecuteYAML(context.Background(), []byte(yaml))
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+
+	// Expressions using secrets validate without resolving them
+	if err := p.ValidateYAML([]byte(yaml)); err != nil {
+		t.Errorf("Expected workflow to validate, got %v", err)
+	}
+}
+
+func TestSecretResolvers(t *testing.T) {
+	t.Setenv("TEST_SECRET_API_KEY", "from-env")
+	value, err := EnvSecrets{Prefix: "TEST_SECRET_"}.Resolve(context.Background(), "api_key")
+	if err != nil || value != "from-env" {
+		t.Errorf("Expected env secret, got %q, %v", value, err)
+	}
+	if _, err := (EnvSecrets{Prefix: "TEST_SECRET_"}).Resolve(context.Background(), "unset"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files := FileSecrets{Dir: dir}
+	value, err = files.Resolve(context.Background(), "db_password")
+	if err != nil || value != "from-file" {
+		t.Errorf("Expected file secret, got %q, %v", value, err)
+	}
+	if _, err := files.Resolve(context.Background(), "other"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if _, err := files.Resolve(context.Background(), "../db_password"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected path traversal to be rejected, got %v", err)
+	}
+}
+
+func TestRedactMultilineSecret(t *testing.T) {
+	s := newSecretStore(context.Background(), nil)
+	s.mask("-----BEGIN KEY-----\nabcdef\n-----END KEY-----")
+	s.mask("ab")
+
+	if got := s.redact("key: abcdef"); got != "key: ***" {
+		t.Errorf("Expected each line to be redacted, got %q", got)
+	}
+	if got := s.redact("ab"); got != "ab" {
+		t.Errorf("Expected short values to be left alone, got %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package probe
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// systemctlPath is the systemctl executable controlling systemd
+var systemctlPath = "systemctl"
+
+// systemdStates maps the ActiveState of a unit to a service state
+var systemdStates = map[string]string{
+	"active":       "running",
+	"reloading":    "running",
+	"inactive":     "stopped",
+	"activating":   "starting",
+	"deactivating": "stopping",
+	"failed":       "failed",
+}
+
+// systemdManager controls systemd units through systemctl
+type systemdManager struct{}
+
+func newServiceManager() (serviceManager, error) {
+	return systemdManager{}, nil
+}
+
+func (systemdManager) status(ctx context.Context, name string) (serviceStatus, error) {
+	out, err := systemctl(ctx, "show", "--property=LoadState,ActiveState,UnitFileState,InvocationID", name)
+	if err != nil {
+		return serviceStatus{}, err
+	}
+	props := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[key] = value
+		}
+	}
+	if props["LoadState"] == "not-found" {
+		return serviceStatus{}, fmt.Errorf("service %s not found", name)
+	}
+
+	state, ok := systemdStates[props["ActiveState"]]
+	if !ok {
+		state = props["ActiveState"]
+	}
+	return serviceStatus{
+		State:   state,
+		Enabled: strings.HasPrefix(props["UnitFileState"], "enabled"),
+		ID:      props["InvocationID"],
+	}, nil
+}
+
+// start and restart wait for the systemd job to finish, so that the state
+// read afterwards is the result of the job rather than the state before it
+func (systemdManager) start(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "start", name)
+	return err
+}
+
+func (systemdManager) stop(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "stop", "--no-block", name)
+	return err
+}
+
+func (systemdManager) restart(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "restart", name)
+	return err
+}
+
+func (systemdManager) setEnabled(ctx context.Context, name string, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	_, err := systemctl(ctx, action, name)
+	return err
+}
+
+// systemctl runs a systemctl command and returns its output
+func systemctl(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, systemctlPath, args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("systemctl %s: %s", strings.Join(args, " "), msg)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,51 @@
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSystemdManager(t *testing.T) {
+	// A fake systemctl keeping the unit state in a file
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	os.WriteFile(state, []byte("inactive"), 0644)
+	script := `#!/bin/sh
+state=` + state + `
+case "$1" in
+show)
+	[ "$3" = missing ] && { echo LoadState=not-found; exit 0; }
+	echo LoadState=loaded
+	echo ActiveState=$(cat $state)
+	echo UnitFileState=enabled
+	echo InvocationID=$(cat $state.id 2>/dev/null)
+	;;
+start) echo active > $state; echo 2f5c > $state.id ;;
+*) echo "unexpected $*" >&2; exit 1 ;;
+esac
+`
+	systemctl := filepath.Join(dir, "systemctl")
+	os.WriteFile(systemctl, []byte(script), 0755)
+	systemctlPath = systemctl
+	defer func() { systemctlPath = "systemctl" }()
+
+	m := systemdManager{}
+	ctx := context.Background()
+	if status, err := m.status(ctx, "nginx"); err != nil || status != (serviceStatus{State: "stopped", Enabled: true}) {
+		t.Errorf("Expected stopped and enabled, got %v %v", status, err)
+	}
+	if err := m.start(ctx, "nginx"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if status, _ := m.status(ctx, "nginx"); status.State != "running" || status.ID != "2f5c" {
+		t.Errorf("Expected running with an invocation id, got %v", status)
+	}
+	if _, err := m.status(ctx, "missing"); err == nil || err.Error() != "service missing not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := m.setEnabled(ctx, "nginx", false); err == nil || err.Error() != "systemctl disable nginx: unexpected disable nginx" {
+		t.Errorf("Expected systemctl error, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package probe
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newServiceManager() (serviceManager, error) {
+	return nil, fmt.Errorf("service task is not supported on %s", runtime.GOOS)
+}
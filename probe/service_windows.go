@@ -0,0 +1,141 @@
+//go:build windows
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// scmStates maps the state of a Windows service to a service state
+var scmStates = map[svc.State]string{
+	svc.Running:         "running",
+	svc.Stopped:         "stopped",
+	svc.StartPending:    "starting",
+	svc.StopPending:     "stopping",
+	svc.ContinuePending: "starting",
+	svc.PausePending:    "stopping",
+	svc.Paused:          "paused",
+}
+
+// scmManager controls services through the Windows service control manager
+type scmManager struct{}
+
+func newServiceManager() (serviceManager, error) {
+	return scmManager{}, nil
+}
+
+// open connects to the service control manager and opens a service
+func (scmManager) open(name string) (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	s, err := m.OpenService(name)
+	if err != nil {
+		m.Disconnect()
+		return nil, nil, fmt.Errorf("service %s not found: %w", name, err)
+	}
+	return m, s, nil
+}
+
+func (sm scmManager) status(ctx context.Context, name string) (serviceStatus, error) {
+	m, s, err := sm.open(name)
+	if err != nil {
+		return serviceStatus{}, err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	st, err := s.Query()
+	if err != nil {
+		return serviceStatus{}, fmt.Errorf("failed to query service %s: %w", name, err)
+	}
+	config, err := s.Config()
+	if err != nil {
+		return serviceStatus{}, fmt.Errorf("failed to read config of service %s: %w", name, err)
+	}
+	state, ok := scmStates[st.State]
+	if !ok {
+		state = "unknown"
+	}
+	status := serviceStatus{
+		State:   state,
+		Enabled: config.StartType == mgr.StartAutomatic,
+	}
+	if st.ProcessId != 0 {
+		status.ID = strconv.FormatUint(uint64(st.ProcessId), 10)
+	}
+	return status, nil
+}
+
+func (sm scmManager) start(ctx context.Context, name string) error {
+	m, s, err := sm.open(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+	return s.Start()
+}
+
+func (sm scmManager) stop(ctx context.Context, name string) error {
+	m, s, err := sm.open(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+	_, err = s.Control(svc.Stop)
+	return err
+}
+
+// restart stops the service, waits for it to stop and starts it again
+func (sm scmManager) restart(ctx context.Context, name string) error {
+	status, err := sm.status(ctx, name)
+	if err != nil {
+		return err
+	}
+	if status.State != "stopped" {
+		if err := sm.stop(ctx, name); err != nil {
+			return err
+		}
+	}
+	for status.State != "stopped" {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s did not stop: %w", name, ctx.Err())
+		case <-time.After(servicePollInterval):
+		}
+		if status, err = sm.status(ctx, name); err != nil {
+			return err
+		}
+	}
+	return sm.start(ctx, name)
+}
+
+func (sm scmManager) setEnabled(ctx context.Context, name string, enabled bool) error {
+	m, s, err := sm.open(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	config, err := s.Config()
+	if err != nil {
+		return err
+	}
+	// Like systemd disable, a disabled service no longer starts at boot but
+	// can still be started
+	config.StartType = mgr.StartManual
+	if enabled {
+		config.StartType = mgr.StartAutomatic
+	}
+	return s.UpdateConfig(config)
+}
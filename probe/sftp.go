@@ -0,0 +1,429 @@
+package probe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHTransfer is a file or directory copied over SFTP. Directories are copied
+// recursively, with their contents placed in the destination directory.
+type SSHTransfer struct {
+	Local  string
+	Remote string
+
+	// Mode sets the permissions of copied files; zero keeps those of the source
+	Mode os.FileMode
+
+	// Owner and Group set the numeric owner of copied files and directories;
+	// -1 leaves it unchanged
+	Owner int
+	Group int
+
+	// Atomic writes every file under a temporary name and renames it into place
+	Atomic bool
+
+	// Verify reads every file back after the copy and compares its SHA-256
+	// checksum with the source before it is put in place
+	Verify bool
+}
+
+// parseSSHTransfer reads the upload or download config of an SSH task
+func parseSSHTransfer(v interface{}) (*SSHTransfer, error) {
+	config, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a map")
+	}
+	t := &SSHTransfer{Owner: -1, Group: -1, Atomic: true, Verify: true}
+
+	t.Local, _ = config["local"].(string)
+	t.Remote, _ = config["remote"].(string)
+	if t.Local == "" || t.Remote == "" {
+		return nil, fmt.Errorf("local and remote are required")
+	}
+
+	if mode, ok := config["mode"]; ok {
+		m, err := parseFileMode(mode)
+		if err != nil {
+			return nil, err
+		}
+		t.Mode = m
+	}
+	for key, id := range map[string]*int{"owner": &t.Owner, "group": &t.Group} {
+		if v, ok := config[key]; ok {
+			n, ok := toInt(v)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%s must be a numeric id", key)
+			}
+			*id = n
+		}
+	}
+	if atomic, ok := config["atomic"].(bool); ok {
+		t.Atomic = atomic
+	}
+	if verify, ok := config["verify"].(bool); ok {
+		t.Verify = verify
+	}
+	return t, nil
+}
+
+// transferFields describes the keys of an upload or download
+func transferFields() []FieldSpec {
+	return []FieldSpec{
+		{Name: "local", Type: FieldString, Required: true, Description: "Local file or directory"},
+		{Name: "remote", Type: FieldString, Required: true, Description: "Remote file or directory"},
+		{Name: "mode", Type: FieldString, Description: "Octal permissions of copied files, e.g. \"0640\"; the source's by default"},
+		{Name: "owner", Type: FieldInt, Description: "Numeric user id owning copied files"},
+		{Name: "group", Type: FieldInt, Description: "Numeric group id owning copied files"},
+		{Name: "atomic", Type: FieldBool, Default: true, Description: "Write each file under a temporary name and rename it into place"},
+		{Name: "verify", Type: FieldBool, Default: true, Description: "Compare SHA-256 checksums of each file after the copy"},
+	}
+}
+
+// parseFileMode reads permissions given as an octal string such as "0644".
+// Setuid, setgid and sticky bits are refused, as they would be dropped when
+// the mode is applied.
+func parseFileMode(v interface{}) (os.FileMode, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("mode must be an octal string such as \"0644\", got %v", v)
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0o7777 {
+		return 0, fmt.Errorf("mode must be an octal string such as \"0644\", got %q", s)
+	}
+	if mode > 0o777 {
+		return 0, fmt.Errorf("mode %q has setuid, setgid or sticky bits, which are not supported", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// transferLog records the files copied by a transfer
+type transferLog struct {
+	files []map[string]interface{}
+	bytes int64
+}
+
+func (l *transferLog) add(path string, size int64, sum []byte) {
+	l.files = append(l.files, map[string]interface{}{
+		"path":   path,
+		"size":   size,
+		"sha256": hex.EncodeToString(sum),
+	})
+	l.bytes += size
+}
+
+// result lists the copied files with their destination, size and checksum
+func (l *transferLog) result() map[string]interface{} {
+	files := l.files
+	if files == nil {
+		files = []map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"files": files,
+		"bytes": l.bytes,
+	}
+}
+
+// tempSuffix is added to the hidden name a file is written under before it is
+// renamed into place
+const tempSuffix = ".probe-tmp"
+
+// sftpUpload copies a local file or directory to the remote host
+func sftpUpload(client *ssh.Client, spec *SSHTransfer) (map[string]interface{}, error) {
+	c, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SFTP: %w", err)
+	}
+	defer c.Close()
+
+	info, err := os.Stat(spec.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &transferLog{}
+	if !info.IsDir() {
+		remote := spec.Remote
+		if st, err := c.Stat(remote); err == nil && st.IsDir() {
+			remote = path.Join(remote, filepath.Base(spec.Local))
+		}
+		err := uploadFile(c, spec, spec.Local, remote, info.Mode(), log)
+		return log.result(), err
+	}
+
+	err = filepath.WalkDir(spec.Local, func(local string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(spec.Local, local)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(spec.Remote, filepath.ToSlash(rel))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := c.MkdirAll(remote); err != nil {
+				return fmt.Errorf("failed to create %s: %w", remote, err)
+			}
+			if err := c.Chmod(remote, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to set mode of %s: %w", remote, err)
+			}
+			return chownRemote(c, spec, remote)
+		case info.Mode().IsRegular():
+			return uploadFile(c, spec, local, remote, info.Mode(), log)
+		}
+		// Symlinks and special files are not copied
+		return nil
+	})
+	return log.result(), err
+}
+
+// uploadFile copies a local file to the remote path
+func uploadFile(c *sftp.Client, spec *SSHTransfer, local, remote string, mode os.FileMode, log *transferLog) (err error) {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst := remote
+	if spec.Atomic {
+		dst = path.Join(path.Dir(remote), "."+path.Base(remote)+tempSuffix)
+	}
+	f, err := c.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer func() {
+		if err != nil && spec.Atomic {
+			c.Remove(dst)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(f, io.TeeReader(src, hash))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", local, err)
+	}
+	sum := hash.Sum(nil)
+
+	if spec.Mode != 0 {
+		mode = spec.Mode
+	}
+	if err := c.Chmod(dst, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", remote, err)
+	}
+	if err := chownRemote(c, spec, dst); err != nil {
+		return err
+	}
+
+	if spec.Verify {
+		r, err := c.Open(dst)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", remote, err)
+		}
+		err = verifyChecksum(r, sum, remote)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if spec.Atomic {
+		if err := c.PosixRename(dst, remote); err != nil {
+			// Servers without the posix-rename extension cannot replace a file
+			c.Remove(remote)
+			if err := c.Rename(dst, remote); err != nil {
+				return fmt.Errorf("failed to rename %s: %w", dst, err)
+			}
+		}
+	}
+
+	log.add(remote, size, sum)
+	return nil
+}
+
+// chownRemote sets the configured owner of a remote file
+func chownRemote(c *sftp.Client, spec *SSHTransfer, remote string) error {
+	if spec.Owner < 0 && spec.Group < 0 {
+		return nil
+	}
+	uid, gid := spec.Owner, spec.Group
+	if uid < 0 || gid < 0 {
+		info, err := c.Stat(remote)
+		if err != nil {
+			return err
+		}
+		stat, ok := info.Sys().(*sftp.FileStat)
+		if !ok {
+			return fmt.Errorf("failed to get owner of %s", remote)
+		}
+		if uid < 0 {
+			uid = int(stat.UID)
+		}
+		if gid < 0 {
+			gid = int(stat.GID)
+		}
+	}
+	if err := c.Chown(remote, uid, gid); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", remote, err)
+	}
+	return nil
+}
+
+// sftpDownload copies a remote file or directory to the local path
+func sftpDownload(client *ssh.Client, spec *SSHTransfer, local string) (map[string]interface{}, error) {
+	c, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SFTP: %w", err)
+	}
+	defer c.Close()
+
+	info, err := c.Stat(spec.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec.Remote, err)
+	}
+
+	log := &transferLog{}
+	if !info.IsDir() {
+		if st, err := os.Stat(local); err == nil && st.IsDir() {
+			local = filepath.Join(local, path.Base(spec.Remote))
+		}
+		err := downloadFile(c, spec, spec.Remote, local, info.Mode(), log)
+		return log.result(), err
+	}
+
+	walker := c.Walk(spec.Remote)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return log.result(), err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), spec.Remote), "/")
+		dst := filepath.Join(local, filepath.FromSlash(rel))
+		info := walker.Stat()
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return log.result(), err
+			}
+			if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+				return log.result(), err
+			}
+			if err := chownLocal(spec, dst); err != nil {
+				return log.result(), err
+			}
+		case info.Mode().IsRegular():
+			if err := downloadFile(c, spec, walker.Path(), dst, info.Mode(), log); err != nil {
+				return log.result(), err
+			}
+		}
+	}
+	return log.result(), nil
+}
+
+// downloadFile copies a remote file to the local path
+func downloadFile(c *sftp.Client, spec *SSHTransfer, remote, local string, mode os.FileMode, log *transferLog) (err error) {
+	src, err := c.Open(remote)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remote, err)
+	}
+	defer src.Close()
+
+	dst := local
+	if spec.Atomic {
+		dst = filepath.Join(filepath.Dir(local), "."+filepath.Base(local)+tempSuffix)
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && spec.Atomic {
+			os.Remove(dst)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", remote, err)
+	}
+	sum := hash.Sum(nil)
+
+	if spec.Mode != 0 {
+		mode = spec.Mode
+	}
+	if err := os.Chmod(dst, mode.Perm()); err != nil {
+		return err
+	}
+	if err := chownLocal(spec, dst); err != nil {
+		return err
+	}
+
+	if spec.Verify {
+		r, err := os.Open(dst)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", local, err)
+		}
+		err = verifyChecksum(r, sum, local)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if spec.Atomic {
+		if err := os.Rename(dst, local); err != nil {
+			return err
+		}
+	}
+
+	log.add(local, size, sum)
+	return nil
+}
+
+// chownLocal sets the configured owner of a downloaded file
+func chownLocal(spec *SSHTransfer, local string) error {
+	if spec.Owner < 0 && spec.Group < 0 {
+		return nil
+	}
+	if err := os.Chown(local, spec.Owner, spec.Group); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", local, err)
+	}
+	return nil
+}
+
+// verifyChecksum reads a copied file back and compares its checksum
+func verifyChecksum(r io.Reader, want []byte, name string) error {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return fmt.Errorf("failed to verify %s: %w", name, err)
+	}
+	if got := hash.Sum(nil); string(got) != string(want) {
+		return fmt.Errorf("checksum mismatch for %s: copied %x, source %x", name, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package probe
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSSHTaskTransfer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Checks Unix permissions")
+	}
+	addr, _ := startSSHServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	// A config bundle with nested directories and a symlink, which is skipped
+	bundle := t.TempDir()
+	files := map[string]os.FileMode{"app.conf": 0640, "conf.d/extra.conf": 0600}
+	for name, mode := range files {
+		path := filepath.Join(bundle, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("contents of "+name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("app.conf", filepath.Join(bundle, "link.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(t.TempDir(), "etc", "app")
+	result, err := runSSHTask(t, map[string]interface{}{
+		"host":                     host,
+		"port":                     portNum,
+		"insecure_ignore_host_key": true,
+		"upload":                   map[string]interface{}{"local": bundle, "remote": remote, "owner": os.Getuid(), "group": os.Getgid()},
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	upload := result.(map[string]interface{})["upload"].(map[string]interface{})
+	if n := len(upload["files"].([]map[string]interface{})); n != 2 {
+		t.Errorf("Expected 2 files uploaded, got %d: %v", n, upload)
+	}
+	for name, mode := range files {
+		path := filepath.Join(remote, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Expected %s to be uploaded: %v", name, err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("Expected %s to keep mode %o, got %o", name, mode, info.Mode().Perm())
+		}
+	}
+	if info, err := os.Stat(filepath.Join(remote, "conf.d")); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("Expected directory mode to be kept, got %v %v", info, err)
+	}
+	if _, err := os.Lstat(filepath.Join(remote, "link.conf")); err == nil {
+		t.Errorf("Expected symlinks to be skipped")
+	}
+
+	// A single file into an existing directory with an explicit mode, then
+	// downloaded per host
+	downloads := t.TempDir()
+	result, err = runSSHTask(t, map[string]interface{}{
+		"hosts":                    []interface{}{addr},
+		"insecure_ignore_host_key": true,
+		"upload":                   map[string]interface{}{"local": filepath.Join(bundle, "app.conf"), "remote": filepath.Join(remote, "conf.d"), "mode": "0604"},
+		"command":                  "reload",
+		"download":                 map[string]interface{}{"remote": remote, "local": downloads},
+	})
+	if err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(remote, "conf.d", "app.conf")); err != nil || info.Mode().Perm() != 0604 {
+		t.Errorf("Expected uploaded file with mode 0604, got %v %v", info, err)
+	}
+	hostResult := result.(map[string]interface{})["hosts"].([]map[string]interface{})[0]
+	download := hostResult["download"].(map[string]interface{})
+	if n := len(download["files"].([]map[string]interface{})); n != 3 {
+		t.Errorf("Expected 3 files downloaded, got %d: %v", n, download)
+	}
+	data, err := os.ReadFile(filepath.Join(downloads, hostDirName(addr), "conf.d", "app.conf"))
+	if err != nil || string(data) != "contents of app.conf" {
+		t.Errorf("Expected download in the host's directory, got %q %v", data, err)
+	}
+
+	// No temporary files are left behind
+	filepath.WalkDir(remote, func(path string, d os.DirEntry, err error) error {
+		if strings.HasSuffix(path, tempSuffix) {
+			t.Errorf("Temporary file left: %s", path)
+		}
+		return nil
+	})
+}
+
+func TestSSHTransferConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"local": "a"},
+		{"local": "a", "remote": "b", "mode": 644},
+		{"local": "a", "remote": "b", "mode": "rw-r--r--"},
+		{"local": "a", "remote": "b", "mode": "4755"},
+		{"local": "a", "remote": "b", "owner": "root"},
+	} {
+		if _, err := parseSSHTransfer(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+}
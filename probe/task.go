@@ -10,3 +10,10 @@ type Task interface {
 	// Execute runs the task and returns the result
 	Execute(ctx context.Context) (interface{}, error)
 }
+
+// Describer is implemented by tasks that document their configuration. The
+// description is used to flag unknown or mistyped config keys when validating
+// a workflow and to generate its JSON Schema.
+type Describer interface {
+	Describe() TaskSpec
+}
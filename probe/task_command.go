@@ -3,17 +3,58 @@ package probe
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 )
 
 // CommandTask executes local shell commands
 type CommandTask struct {
-	Command string
-	Args    []string
-	Timeout time.Duration
-	Shell   bool
+	Command   string
+	Args      []string
+	Timeout   time.Duration
+	Shell     bool
+	MaxOutput int
+
+	// Env is added to the environment of the agent, or replaces it when
+	// EnvInherit is false
+	Env        map[string]string
+	EnvInherit bool
+
+	// Cwd is the working directory; Stdin is written to the command's input
+	Cwd   string
+	Stdin string
+
+	// User and Group run the command as another user (Unix only)
+	User  string
+	Group string
+
+	// SuccessExitCodes are the exit codes counted as success
+	SuccessExitCodes []int
+}
+
+// Describe documents the command task configuration
+func (t *CommandTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs a local command",
+		Fields: []FieldSpec{
+			{Name: "command", Type: FieldString, Required: true, Description: "Command to run"},
+			{Name: "args", Type: FieldList, Items: FieldString, Description: "Command arguments"},
+			{Name: "shell", Type: FieldBool, Default: false, Description: "Run the command through the system shell"},
+			{Name: "timeout", Type: FieldDuration, Default: "30s", Description: "Command timeout"},
+			{Name: "max_output", Type: FieldAny, Default: "1MB", Description: "Output kept per stream, in bytes or with a KB/MB/GB suffix; the middle of longer output is dropped"},
+			{Name: "env", Type: FieldMap, Description: "Environment variables of the command"},
+			{Name: "env_inherit", Type: FieldBool, Default: true, Description: "Start from the environment of the agent"},
+			{Name: "cwd", Type: FieldString, Description: "Working directory"},
+			{Name: "stdin", Type: FieldString, Description: "Input written to the command"},
+			{Name: "user", Type: FieldString, Description: "User name or id to run as (Unix only)"},
+			{Name: "group", Type: FieldString, Description: "Group name or id to run as (Unix only)"},
+			{Name: "success_exit_codes", Type: FieldList, Items: FieldInt, Default: []int{0}, Description: "Exit codes counted as success"},
+		},
+	}
 }
 
 // Configure sets up the command task
@@ -51,6 +92,60 @@ func (t *CommandTask) Configure(config map[string]interface{}) error {
 		t.Timeout = 30 * time.Second
 	}
 	
+	// Output limit per stream (default: 1MB)
+	maxOutput, err := parseMaxOutput(config)
+	if err != nil {
+		return err
+	}
+	t.MaxOutput = maxOutput
+	
+	// Environment (optional)
+	if env, ok := config["env"]; ok {
+		vars, ok := env.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("env must be a map")
+		}
+		t.Env = make(map[string]string, len(vars))
+		for k, v := range vars {
+			if k == "" || strings.Contains(k, "=") {
+				return fmt.Errorf("invalid env name %q", k)
+			}
+			t.Env[k] = toString(v)
+		}
+	}
+	t.EnvInherit = true
+	if inherit, ok := config["env_inherit"].(bool); ok {
+		t.EnvInherit = inherit
+	}
+	
+	// Working directory and input (optional)
+	t.Cwd, _ = config["cwd"].(string)
+	t.Stdin, _ = config["stdin"].(string)
+	
+	// User and group (optional); numeric ids may be given as numbers
+	for key, field := range map[string]*string{"user": &t.User, "group": &t.Group} {
+		if v, ok := config[key]; ok {
+			*field = toString(v)
+		}
+	}
+	
+	// Exit codes counted as success (default: 0)
+	t.SuccessExitCodes = []int{0}
+	if codes, ok := config["success_exit_codes"]; ok {
+		list, ok := codes.([]interface{})
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("success_exit_codes must be a list of exit codes")
+		}
+		t.SuccessExitCodes = make([]int, len(list))
+		for i, code := range list {
+			n, ok := toInt(code)
+			if !ok {
+				return fmt.Errorf("invalid success_exit_codes: %v is not an exit code", code)
+			}
+			t.SuccessExitCodes[i] = n
+		}
+	}
+	
 	return nil
 }
 
@@ -74,25 +169,52 @@ func (t *CommandTask) Execute(ctx context.Context) (interface{}, error) {
 		cmd = exec.CommandContext(ctx, t.Command, t.Args...)
 	}
 	
-	output, err := cmd.CombinedOutput()
-	exitCode := 0
-	
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		} else {
-			return nil, fmt.Errorf("command execution failed: %w", err)
+	cmd.Dir = t.Cwd
+	cmd.Env = t.environ()
+	if t.Stdin != "" {
+		cmd.Stdin = strings.NewReader(t.Stdin)
+	}
+	if t.User != "" || t.Group != "" {
+		if err := setCredential(cmd, t.User, t.Group); err != nil {
+			return nil, err
 		}
 	}
 	
-	result := map[string]interface{}{
-		"output":    string(output),
-		"exit_code": exitCode,
+	// Stream stdout and stderr while capturing them separately
+	result, exitCode, err := runProcess(ctx, cmd, t.MaxOutput)
+	if err != nil {
+		return nil, fmt.Errorf("command execution failed: %w", err)
 	}
 	
-	if exitCode != 0 {
-		return result, fmt.Errorf("command exited with code %d", exitCode)
+	for _, code := range t.SuccessExitCodes {
+		if exitCode == code {
+			return result, nil
+		}
 	}
 	
-	return result, nil
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("command interrupted: %w", ctx.Err())
+	}
+	return result, fmt.Errorf("command exited with code %d", exitCode)
+}
+
+// environ returns the environment of the command, or nil to inherit the
+// agent's unchanged
+func (t *CommandTask) environ() []string {
+	if t.EnvInherit && len(t.Env) == 0 {
+		return nil
+	}
+	env := []string{}
+	if t.EnvInherit {
+		env = os.Environ()
+	}
+	keys := make([]string, 0, len(t.Env))
+	for k := range t.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+t.Env[k])
+	}
+	return env
 }
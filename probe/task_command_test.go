@@ -0,0 +1,131 @@
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCommandTask configures and runs a command task
+func runCommandTask(t *testing.T, config map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	task := &CommandTask{}
+	if err := task.Configure(config); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	result, err := task.Execute(context.Background())
+	output, _ := result.(map[string]interface{})
+	return output, err
+}
+
+func TestCommandTaskEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh")
+	}
+	dir := t.TempDir()
+	t.Setenv("PROBE_TEST_INHERITED", "inherited")
+
+	output, err := runCommandTask(t, map[string]interface{}{
+		"command": `echo "$GREETING $PORT ${PROBE_TEST_INHERITED:-none}"; pwd; cat`,
+		"shell":   true,
+		"env":     map[string]interface{}{"GREETING": "hello", "PORT": 8080},
+		"cwd":     dir,
+		"stdin":   "from stdin",
+	})
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	if want := "hello 8080 inherited\n" + realDir + "\nfrom stdin"; output["stdout"] != want {
+		t.Errorf("Expected %q, got %q", want, output["stdout"])
+	}
+
+	output, err = runCommandTask(t, map[string]interface{}{
+		"command":     `echo "${PROBE_TEST_INHERITED:-none} $GREETING"`,
+		"shell":       true,
+		"env":         map[string]interface{}{"GREETING": "only"},
+		"env_inherit": false,
+	})
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if output["stdout"] != "none only\n" {
+		t.Errorf("Expected the agent environment to be dropped, got %q", output["stdout"])
+	}
+}
+
+func TestCommandTaskExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh")
+	}
+	config := map[string]interface{}{
+		"command":            "exit 3",
+		"shell":              true,
+		"success_exit_codes": []interface{}{0, 3},
+	}
+	if output, err := runCommandTask(t, config); err != nil || output["exit_code"] != 3 {
+		t.Errorf("Expected exit code 3 to succeed, got %v %v", output, err)
+	}
+
+	config["success_exit_codes"] = []interface{}{1}
+	if _, err := runCommandTask(t, config); err == nil || err.Error() != "command exited with code 3" {
+		t.Errorf("Expected exit code error, got %v", err)
+	}
+}
+
+func TestCommandTaskUser(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Runs as a Unix user")
+	}
+	output, err := runCommandTask(t, map[string]interface{}{
+		"command": "id",
+		"args":    []interface{}{"-u"},
+		"user":    os.Getuid(),
+		"group":   strconv.Itoa(os.Getgid()),
+	})
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if got := strings.TrimSpace(output["stdout"].(string)); got != strconv.Itoa(os.Getuid()) {
+		t.Errorf("Expected uid %d, got %s", os.Getuid(), got)
+	}
+
+	task := &CommandTask{}
+	task.Configure(map[string]interface{}{"command": "id", "user": "no-such-user-for-probe"})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown user") {
+		t.Errorf("Expected unknown user error, got %v", err)
+	}
+
+	// A uid without a passwd entry has no primary group to fall back on
+	task = &CommandTask{}
+	task.Configure(map[string]interface{}{"command": "id", "user": 54321})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "a group is required") {
+		t.Errorf("Expected group required error, got %v", err)
+	}
+}
+
+func TestCommandTaskTimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh")
+	}
+
+	// The background sleep keeps stdout open: unless the whole process group
+	// is killed, the task waits for it
+	start := time.Now()
+	_, err := runCommandTask(t, map[string]interface{}{
+		"command": "sleep 30 & sleep 30",
+		"shell":   true,
+		"timeout": "200ms",
+	})
+	if err == nil || !strings.Contains(err.Error(), "command interrupted") {
+		t.Errorf("Expected interruption error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the process group to be killed at the timeout, took %s", elapsed)
+	}
+}
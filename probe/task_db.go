@@ -4,17 +4,69 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
+// dbDrivers are the driver names accepted by the database task
+var dbDrivers = []string{"mysql", "postgres", "postgresql", "pgx", "sqlite", "sqlite3"}
+
+// dbDriverAliases maps common driver names to the registered database/sql drivers
+var dbDriverAliases = map[string]string{
+	"postgres":   "pgx",
+	"postgresql": "pgx",
+	"sqlite3":    "sqlite",
+}
+
 // DBTask performs database operations
 type DBTask struct {
 	Driver  string
 	DSN     string
 	Query   string
 	Timeout time.Duration
+
+	// Params are the values of the query placeholders
+	Params []interface{}
+
+	// Exec runs the query as a statement and returns the rows affected
+	Exec bool
+
+	// Transaction lists statements run in a single transaction instead of Query
+	Transaction []DBStatement
+
+	// ExpectCount is the number of rows returned, or affected by a statement
+	ExpectCount *int
+
+	// ExpectRows are the rows the query must return, compared on their listed columns
+	ExpectRows []map[string]interface{}
+}
+
+// DBStatement is a statement of a transaction
+type DBStatement struct {
+	Query  string
+	Params []interface{}
+}
+
+// Describe documents the database task configuration
+func (t *DBTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs SQL queries or statements and checks the result",
+		Fields: []FieldSpec{
+			{Name: "driver", Type: FieldString, Required: true, Enum: dbDrivers, Description: "Database driver: mysql, postgres or sqlite"},
+			{Name: "dsn", Type: FieldString, Required: true, Description: "Data source name"},
+			{Name: "query", Type: FieldString, Description: "SQL query or statement; required unless transaction is given"},
+			{Name: "params", Type: FieldList, Description: "Values of the query placeholders"},
+			{Name: "exec", Type: FieldBool, Default: false, Description: "Run the query as a statement returning the rows affected"},
+			{Name: "transaction", Type: FieldList, Description: "Statements run in one transaction, as strings or maps with query and params"},
+			{Name: "expect_count", Type: FieldInt, Description: "Number of rows returned, or affected by statements"},
+			{Name: "expect_rows", Type: FieldList, Items: FieldMap, Description: "Rows the query must return, compared on the columns given"},
+			{Name: "timeout", Type: FieldDuration, Default: "30s", Description: "Query timeout"},
+		},
+	}
 }
 
 // Configure sets up the database task
@@ -24,6 +76,12 @@ func (t *DBTask) Configure(config map[string]interface{}) error {
 	if !ok || driver == "" {
 		return fmt.Errorf("driver is required")
 	}
+	if !containsString(dbDrivers, driver) {
+		return fmt.Errorf("unsupported driver %q", driver)
+	}
+	if name, ok := dbDriverAliases[driver]; ok {
+		driver = name
+	}
 	t.Driver = driver
 	
 	// DSN is required
@@ -33,12 +91,60 @@ func (t *DBTask) Configure(config map[string]interface{}) error {
 	}
 	t.DSN = dsn
 	
-	// Query is required
-	query, ok := config["query"].(string)
-	if !ok || query == "" {
-		return fmt.Errorf("query is required")
+	// Either a query or a transaction is required
+	if transaction, ok := config["transaction"]; ok {
+		if _, ok := config["query"]; ok {
+			return fmt.Errorf("query and transaction are mutually exclusive")
+		}
+		statements, err := parseDBTransaction(transaction)
+		if err != nil {
+			return fmt.Errorf("invalid transaction: %w", err)
+		}
+		t.Transaction = statements
+	} else {
+		query, ok := config["query"].(string)
+		if !ok || query == "" {
+			return fmt.Errorf("query is required")
+		}
+		t.Query = query
+		
+		params, err := parseDBParams(config["params"])
+		if err != nil {
+			return err
+		}
+		t.Params = params
+	}
+	
+	// Exec mode (default: false)
+	if exec, ok := config["exec"].(bool); ok {
+		t.Exec = exec
+	}
+	
+	// Assertions (optional)
+	if v, ok := config["expect_count"]; ok {
+		count, ok := toInt(v)
+		if !ok || count < 0 {
+			return fmt.Errorf("expect_count must be a non-negative integer")
+		}
+		t.ExpectCount = &count
+	}
+	if v, ok := config["expect_rows"]; ok {
+		if t.Exec || t.Transaction != nil {
+			return fmt.Errorf("expect_rows requires a query returning rows")
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expect_rows must be a list of rows")
+		}
+		t.ExpectRows = make([]map[string]interface{}, len(list))
+		for i, item := range list {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("expect_rows[%d] must be a map of columns to values", i)
+			}
+			t.ExpectRows[i] = row
+		}
 	}
-	t.Query = query
 	
 	// Timeout (default: 30s)
 	if timeoutStr, ok := config["timeout"].(string); ok {
@@ -72,8 +178,29 @@ func (t *DBTask) Execute(ctx context.Context) (interface{}, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 	
-	// Execute query
-	rows, err := db.QueryContext(ctx, t.Query)
+	var result map[string]interface{}
+	switch {
+	case t.Transaction != nil:
+		result, err = t.runTransaction(ctx, db)
+	case t.Exec:
+		result, err = t.exec(ctx, db)
+	default:
+		result, err = t.query(ctx, db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if failures := t.check(result); len(failures) > 0 {
+		return result, fmt.Errorf("assertions failed: %s", strings.Join(failures, "; "))
+	}
+	
+	return result, nil
+}
+
+// query runs the query and returns its rows
+func (t *DBTask) query(ctx context.Context, db *sql.DB) (map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, t.Query, t.Params...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -119,3 +246,131 @@ func (t *DBTask) Execute(ctx context.Context) (interface{}, error) {
 		"count": len(results),
 	}, nil
 }
+
+// exec runs the query as a statement
+func (t *DBTask) exec(ctx context.Context, db *sql.DB) (map[string]interface{}, error) {
+	res, err := db.ExecContext(ctx, t.Query, t.Params...)
+	if err != nil {
+		return nil, fmt.Errorf("statement failed: %w", err)
+	}
+	return execResult(res)
+}
+
+// runTransaction runs the statements in a transaction, rolling it back when
+// one of them fails
+func (t *DBTask) runTransaction(ctx context.Context, db *sql.DB) (map[string]interface{}, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := make([]map[string]interface{}, len(t.Transaction))
+	total := int64(0)
+	for i, stmt := range t.Transaction {
+		res, err := tx.ExecContext(ctx, stmt.Query, stmt.Params...)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d failed: %w", i, err)
+		}
+		result, err := execResult(res)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		statements[i] = result
+		total += result["rows_affected"].(int64)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return map[string]interface{}{
+		"statements":    statements,
+		"rows_affected": total,
+	}, nil
+}
+
+// execResult describes the result of a statement. The last insert ID is only
+// included for drivers reporting it.
+func execResult(res sql.Result) (map[string]interface{}, error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	result := map[string]interface{}{
+		"rows_affected": affected,
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		result["last_insert_id"] = id
+	}
+	return result, nil
+}
+
+// check returns a description of every assertion the result fails
+func (t *DBTask) check(result map[string]interface{}) []string {
+	var failures []string
+
+	if t.ExpectCount != nil {
+		count, ok := result["count"]
+		if !ok {
+			count = result["rows_affected"]
+		}
+		if !valuesEqual(count, *t.ExpectCount) {
+			failures = append(failures, fmt.Sprintf("count is %v, expected %d", count, *t.ExpectCount))
+		}
+	}
+
+	if t.ExpectRows != nil {
+		rows, _ := result["rows"].([]map[string]interface{})
+		if len(rows) != len(t.ExpectRows) {
+			return append(failures, fmt.Sprintf("got %d rows, expected %d", len(rows), len(t.ExpectRows)))
+		}
+		for i, want := range t.ExpectRows {
+			for col, value := range want {
+				if got := rows[i][col]; !valuesEqual(got, value) {
+					failures = append(failures, fmt.Sprintf("row %d: %s is %s, expected %s", i, col, toString(got), toString(value)))
+				}
+			}
+		}
+	}
+
+	return failures
+}
+
+// parseDBParams reads the placeholder values of a query
+func parseDBParams(v interface{}) ([]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	params, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("params must be a list")
+	}
+	return params, nil
+}
+
+// parseDBTransaction reads the statements of a transaction, given as query
+// strings or maps with query and params
+func parseDBTransaction(v interface{}) ([]DBStatement, error) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("must be a non-empty list of statements")
+	}
+	statements := make([]DBStatement, len(list))
+	for i, item := range list {
+		switch x := item.(type) {
+		case string:
+			statements[i].Query = x
+		case map[string]interface{}:
+			statements[i].Query, _ = x["query"].(string)
+			params, err := parseDBParams(x["params"])
+			if err != nil {
+				return nil, fmt.Errorf("statement %d: %w", i, err)
+			}
+			statements[i].Params = params
+		}
+		if statements[i].Query == "" {
+			return nil, fmt.Errorf("statement %d: query is required", i)
+		}
+	}
+	return statements, nil
+}
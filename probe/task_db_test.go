@@ -0,0 +1,117 @@
+package probe
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runDBTask configures and runs a db task against a SQLite database
+func runDBTask(t *testing.T, dsn string, config map[string]interface{}) (interface{}, error) {
+	t.Helper()
+	config["driver"] = "sqlite3"
+	config["dsn"] = dsn
+	task := &DBTask{}
+	if err := task.Configure(config); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	return task.Execute(context.Background())
+}
+
+func TestDBTask(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	result, err := runDBTask(t, dsn, map[string]interface{}{
+		"transaction": []interface{}{
+			"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, active INTEGER)",
+			map[string]interface{}{"query": "INSERT INTO users (name, active) VALUES (?, ?), (?, ?)", "params": []interface{}{"ada", 1, "bob", 0}},
+		},
+		"expect_count": 2,
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if statements := result.(map[string]interface{})["statements"].([]map[string]interface{}); len(statements) != 2 {
+		t.Errorf("Expected a result per statement, got %v", statements)
+	}
+
+	result, err = runDBTask(t, dsn, map[string]interface{}{
+		"query":        "INSERT INTO users (name, active) VALUES (?, ?)",
+		"params":       []interface{}{"carol", 1},
+		"exec":         true,
+		"expect_count": 1,
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if id := result.(map[string]interface{})["last_insert_id"]; id != int64(3) {
+		t.Errorf("Expected last_insert_id 3, got %v", id)
+	}
+
+	_, err = runDBTask(t, dsn, map[string]interface{}{
+		"query":        "SELECT id, name FROM users WHERE active = ? ORDER BY id",
+		"params":       []interface{}{1},
+		"expect_count": 2,
+		"expect_rows": []interface{}{
+			map[string]interface{}{"id": 1, "name": "ada"},
+			map[string]interface{}{"name": "carol"},
+		},
+	})
+	if err != nil {
+		t.Errorf("Query failed: %v", err)
+	}
+
+	// A failing statement rolls back the whole transaction
+	_, err = runDBTask(t, dsn, map[string]interface{}{
+		"transaction": []interface{}{
+			"DELETE FROM users",
+			"INSERT INTO missing VALUES (1)",
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "statement 1 failed") {
+		t.Errorf("Expected statement failure, got %v", err)
+	}
+
+	result, err = runDBTask(t, dsn, map[string]interface{}{
+		"query":        "SELECT name FROM users ORDER BY id",
+		"expect_count": 4,
+		"expect_rows": []interface{}{
+			map[string]interface{}{"name": "ada"},
+			map[string]interface{}{"name": "carol"},
+			map[string]interface{}{"name": "carol"},
+		},
+	})
+	for _, want := range []string{"count is 3, expected 4", "row 1: name is bob, expected carol"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got %v", want, err)
+		}
+	}
+	if result == nil {
+		t.Errorf("Expected rows to be returned with failed assertions")
+	}
+}
+
+func TestDBTaskConfigure(t *testing.T) {
+	base := map[string]interface{}{"driver": "sqlite", "dsn": "x.db"}
+	for _, extra := range []map[string]interface{}{
+		{},
+		{"query": "SELECT 1", "transaction": []interface{}{"SELECT 1"}},
+		{"transaction": []interface{}{}},
+		{"query": "SELECT 1", "params": "a"},
+		{"query": "DELETE FROM t", "exec": true, "expect_rows": []interface{}{}},
+		{"query": "SELECT 1", "expect_count": -1},
+		{"query": "SELECT 1", "driver": "postgress"},
+	} {
+		config := map[string]interface{}{}
+		for k, v := range base {
+			config[k] = v
+		}
+		for k, v := range extra {
+			config[k] = v
+		}
+		if err := (&DBTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", extra)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dnsRecordTypes are the record types the DNS task resolves
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SRV", "TXT"}
+
+// DNSTask resolves the records of a name, with the system resolver or a given
+// DNS server, and checks them against expected values
+type DNSTask struct {
+	Name     string
+	Type     string
+	Resolver string
+	Timeout  time.Duration
+
+	// Expect lists values the records must contain; with Exact they must
+	// be the only ones
+	Expect []string
+	Exact  bool
+}
+
+// Describe documents the DNS task configuration
+func (t *DNSTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Resolves DNS records and checks their values",
+		Fields: []FieldSpec{
+			{Name: "name", Type: FieldString, Required: true, Description: "Name to resolve, or an address for PTR records"},
+			{Name: "type", Type: FieldString, Enum: dnsRecordTypes, Default: "A", Description: "Record type"},
+			{Name: "resolver", Type: FieldString, Description: "DNS server as host or host:port; the system resolver by default"},
+			{Name: "timeout", Type: FieldDuration, Default: "5s", Description: "Lookup timeout"},
+			{Name: "expect", Type: FieldList, Items: FieldString, Description: "Values the records must contain"},
+			{Name: "exact", Type: FieldBool, Default: false, Description: "Fail on records missing from expect"},
+		},
+	}
+}
+
+// Configure sets up the DNS task
+func (t *DNSTask) Configure(config map[string]interface{}) error {
+	name, ok := config["name"].(string)
+	if !ok || name == "" {
+		return fmt.Errorf("name is required")
+	}
+	t.Name = name
+
+	// Record type (default: A)
+	t.Type = "A"
+	if recordType, ok := config["type"].(string); ok {
+		t.Type = strings.ToUpper(recordType)
+		if !containsString(dnsRecordTypes, t.Type) {
+			return fmt.Errorf("invalid type %q: must be one of %s", recordType, strings.Join(dnsRecordTypes, ", "))
+		}
+	}
+
+	// Resolver (optional); port 53 unless given
+	if resolver, ok := config["resolver"].(string); ok && resolver != "" {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			resolver = net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+		}
+		t.Resolver = resolver
+	}
+
+	// Timeout (default: 5s)
+	t.Timeout = 5 * time.Second
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	if expect, ok := config["expect"]; ok {
+		values, err := parseStringList(expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %w", err)
+		}
+		t.Expect = values
+	}
+	t.Exact, _ = config["exact"].(bool)
+	if t.Exact && len(t.Expect) == 0 {
+		return fmt.Errorf("exact requires expect")
+	}
+
+	return nil
+}
+
+// Execute resolves the records and checks the expected values
+func (t *DNSTask) Execute(ctx context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	records, err := t.lookup(ctx, t.resolver())
+	if err != nil {
+		return nil, fmt.Errorf("%s lookup of %s failed: %w", t.Type, t.Name, err)
+	}
+	sort.Strings(records)
+
+	output := map[string]interface{}{
+		"name":        t.Name,
+		"type":        t.Type,
+		"records":     records,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if t.Resolver != "" {
+		output["resolver"] = t.Resolver
+	}
+
+	if failures := t.check(records); len(failures) > 0 {
+		return output, fmt.Errorf("assertions failed: %s", strings.Join(failures, "; "))
+	}
+	return output, nil
+}
+
+// resolver returns the resolver querying the configured DNS server
+func (t *DNSTask) resolver() *net.Resolver {
+	if t.Resolver == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, t.Resolver)
+		},
+	}
+}
+
+// lookup returns the records as strings: addresses, host names without the
+// trailing dot, the text of TXT records and target:port for SRV records
+func (t *DNSTask) lookup(ctx context.Context, r *net.Resolver) ([]string, error) {
+	var records []string
+	switch t.Type {
+	case "A", "AAAA":
+		network := "ip4"
+		if t.Type == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, cname)
+	case "MX":
+		mxs, err := r.LookupMX(ctx, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			records = append(records, mx.Host)
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+	case "PTR":
+		names, err := r.LookupAddr(ctx, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, names...)
+	case "SRV":
+		_, srvs, err := r.LookupSRV(ctx, "", "", t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range srvs {
+			records = append(records, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, txts...)
+	}
+	if t.Type != "TXT" {
+		for i, record := range records {
+			records[i] = strings.TrimSuffix(record, ".")
+		}
+	}
+	return records, nil
+}
+
+// check compares the records with the expected values; names match
+// regardless of case and of a trailing dot
+func (t *DNSTask) check(records []string) []string {
+	normalize := func(s string) string {
+		if t.Type == "TXT" {
+			return s
+		}
+		return strings.ToLower(strings.TrimSuffix(s, "."))
+	}
+	found := map[string]bool{}
+	for _, record := range records {
+		found[normalize(record)] = true
+	}
+
+	var failures []string
+	expected := map[string]bool{}
+	for _, value := range t.Expect {
+		expected[normalize(value)] = true
+		if !found[normalize(value)] {
+			failures = append(failures, fmt.Sprintf("%s is missing", value))
+		}
+	}
+	if t.Exact {
+		for _, record := range records {
+			if !expected[normalize(record)] {
+				failures = append(failures, fmt.Sprintf("%s is unexpected", record))
+			}
+		}
+	}
+	return failures
+}
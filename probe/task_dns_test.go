@@ -0,0 +1,128 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// startDNSServer answers A and TXT queries for app.test over UDP, and
+// NXDOMAIN for anything else
+func startDNSServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	records := map[uint16][][]byte{
+		1:  {{10, 0, 0, 2}, {10, 0, 0, 1}},
+		16: {append([]byte{7}, "version"...), append([]byte{5}, "ends."...)},
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+
+			// The question is the name's labels followed by type and class
+			end := 12
+			var labels []string
+			for end < n && query[end] != 0 {
+				labels = append(labels, string(query[end+1:end+1+int(query[end])]))
+				end += 1 + int(query[end])
+			}
+			end += 5
+			qtype := binary.BigEndian.Uint16(query[end-4:])
+
+			answers := records[qtype]
+			flags := uint16(0x8180)
+			if strings.Join(labels, ".") != "app.test" {
+				answers, flags = nil, 0x8183
+			}
+			resp := append([]byte{}, query[:12]...)
+			binary.BigEndian.PutUint16(resp[2:], flags)
+			binary.BigEndian.PutUint16(resp[4:], 1)
+			binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+			binary.BigEndian.PutUint16(resp[8:], 0)
+			binary.BigEndian.PutUint16(resp[10:], 0)
+			resp = append(resp, query[12:end]...)
+			for _, data := range answers {
+				resp = append(resp, 0xc0, 12)
+				resp = binary.BigEndian.AppendUint16(resp, qtype)
+				resp = binary.BigEndian.AppendUint16(resp, 1)
+				resp = binary.BigEndian.AppendUint32(resp, 60)
+				resp = binary.BigEndian.AppendUint16(resp, uint16(len(data)))
+				resp = append(resp, data...)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSTask(t *testing.T) {
+	resolver := startDNSServer(t)
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		records []string
+		wantErr string
+	}{
+		{"a", map[string]interface{}{"name": "app.test", "expect": []interface{}{"10.0.0.1"}}, []string{"10.0.0.1", "10.0.0.2"}, ""},
+		{"exact", map[string]interface{}{"name": "app.test.", "expect": []interface{}{"10.0.0.1"}, "exact": true}, nil, "10.0.0.2 is unexpected"},
+		{"missing", map[string]interface{}{"name": "app.test", "expect": "10.0.0.9"}, nil, "10.0.0.9 is missing"},
+		{"txt", map[string]interface{}{"name": "app.test", "type": "txt", "expect": []interface{}{"version", "ends."}}, []string{"ends.", "version"}, ""},
+		{"nxdomain", map[string]interface{}{"name": "other.test"}, nil, "A lookup of other.test failed"},
+	}
+
+	for _, tt := range tests {
+		tt.config["resolver"] = resolver
+		task := &DNSTask{}
+		if err := task.Configure(tt.config); err != nil {
+			t.Fatalf("%s: Configure failed: %v", tt.name, err)
+		}
+		result, err := task.Execute(context.Background())
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if records := result.(map[string]interface{})["records"]; !reflect.DeepEqual(records, tt.records) {
+			t.Errorf("%s: expected records %v, got %v", tt.name, tt.records, records)
+		}
+	}
+}
+
+func TestDNSTaskConfigure(t *testing.T) {
+	task := &DNSTask{}
+	if err := task.Configure(map[string]interface{}{"name": "example.com", "resolver": "1.1.1.1"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if task.Resolver != "1.1.1.1:53" || task.Type != "A" {
+		t.Errorf("Expected the default port and type, got %s %s", task.Resolver, task.Type)
+	}
+
+	for _, config := range []map[string]interface{}{
+		{"name": "example.com", "type": "SOA"},
+		{"name": "example.com", "exact": true},
+		{"type": "A"},
+	} {
+		if err := (&DNSTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+}
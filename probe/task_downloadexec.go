@@ -2,8 +2,10 @@ package probe
 
 import (
 	"context"
+	"crypto"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -11,18 +13,86 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
+// defaultMaxDownload is the default max_size of a downloadexec artifact
+const defaultMaxDownload = 256 << 20
+
+// sha256Pattern matches a hex encoded SHA-256 checksum
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// defaultArtifactCacheSize bounds the artifact cache, in bytes
+const defaultArtifactCacheSize = 1 << 30
+
+// SetArtifactCache sets the directory caching downloadexec artifacts by their
+// SHA-256 checksum. It defaults to probe/artifacts in the user cache directory.
+func (p *Probe) SetArtifactCache(dir string) {
+	p.artifactCache = dir
+}
+
+// SetArtifactCacheSize bounds the artifact cache, in bytes. Once a new
+// artifact takes it over the bound, the least recently used ones are removed.
+// It defaults to 1GB.
+func (p *Probe) SetArtifactCacheSize(size int64) {
+	p.artifactCacheSize = size
+}
+
 // DownloadExecTask downloads and executes files with verification
 type DownloadExecTask struct {
 	URL       string
 	SHA256    string
 	Signature string
-	PublicKey string
 	Args      []string
 	Timeout   time.Duration
 	Cleanup   bool
+	MaxOutput int
+
+	// KeyID names the key of the probe's trusted keyring that made Signature
+	KeyID string
+
+	// MaxSize limits the download, and the extracted size of an archive
+	MaxSize int64
+
+	// Cache keeps verified downloads in the artifact cache
+	Cache bool
+
+	// Archive is the format of an archive whose Entrypoint is run
+	Archive    string
+	Entrypoint string
+
+	probe *Probe
+}
+
+// artifactDigest holds the checksums of a downloaded file
+type artifactDigest struct {
+	sha256 []byte
+	sha512 []byte
+}
+
+// Describe documents the DownloadExec task configuration
+func (t *DownloadExecTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Downloads a binary, verifies its checksum and signature, and runs it",
+		Fields: []FieldSpec{
+			{Name: "url", Type: FieldString, Required: true, Description: "Download URL"},
+			{Name: "sha256", Type: FieldString, Required: true, Description: "Expected SHA256 checksum (hex)"},
+			{Name: "signature", Type: FieldString, Description: "Ed25519ph signature (base64)"},
+			{Name: "key_id", Type: FieldString, Description: "Trusted keyring key that made the signature, required with signature"},
+			{Name: "args", Type: FieldList, Items: FieldString, Description: "Command arguments"},
+			{Name: "timeout", Type: FieldDuration, Default: "60s", Description: "Execution timeout"},
+			{Name: "cleanup", Type: FieldBool, Default: true, Description: "Remove the downloaded file afterwards; when set to true, the cache is off unless enabled"},
+			{Name: "max_size", Type: FieldAny, Default: "256MB", Description: "Largest download, and extracted archive, in bytes or with a KB/MB/GB suffix"},
+			{Name: "cache", Type: FieldBool, Default: true, Description: "Keep the verified download in the artifact cache"},
+			{Name: "archive", Type: FieldString, Enum: []string{"tar.gz", "zip"}, Description: "Archive format; detected from the URL when entrypoint is set"},
+			{Name: "entrypoint", Type: FieldString, Description: "Path of the executable within the archive"},
+			{Name: "max_output", Type: FieldAny, Default: "1MB", Description: "Output kept per stream, in bytes or with a KB/MB/GB suffix; the middle of longer output is dropped"},
+		},
+	}
 }
 
 // Configure sets up the DownloadExec task
@@ -39,19 +109,25 @@ func (t *DownloadExecTask) Configure(config map[string]interface{}) error {
 	if !ok || sha256 == "" {
 		return fmt.Errorf("sha256 is required")
 	}
-	t.SHA256 = sha256
+	if !sha256Pattern.MatchString(sha256) {
+		return fmt.Errorf("sha256 must be 64 hex digits")
+	}
+	t.SHA256 = strings.ToLower(sha256)
 	
 	// Signature is optional (but recommended)
 	if signature, ok := config["signature"].(string); ok {
 		t.Signature = signature
 	}
 	
-	// Public key is required if signature is provided
+	// Signatures are checked with a key of the agent's trusted keyring
+	if _, ok := config["public_key"]; ok {
+		return fmt.Errorf("public_key is not supported: add the key to the trusted keyring of the agent and set key_id")
+	}
 	if t.Signature != "" {
-		if publicKey, ok := config["public_key"].(string); ok {
-			t.PublicKey = publicKey
+		if keyID, ok := config["key_id"].(string); ok && keyID != "" {
+			t.KeyID = keyID
 		} else {
-			return fmt.Errorf("public_key is required when signature is provided")
+			return fmt.Errorf("key_id is required when signature is provided")
 		}
 	}
 	
@@ -83,48 +159,225 @@ func (t *DownloadExecTask) Configure(config map[string]interface{}) error {
 		t.Cleanup = true
 	}
 	
+	// Output limit per stream (default: 1MB)
+	maxOutput, err := parseMaxOutput(config)
+	if err != nil {
+		return err
+	}
+	t.MaxOutput = maxOutput
+	
+	// Download size limit (default: 256MB)
+	t.MaxSize = defaultMaxDownload
+	if v, ok := config["max_size"]; ok {
+		size, err := parseSize(v)
+		if err != nil {
+			return fmt.Errorf("invalid max_size: %w", err)
+		}
+		if size <= 0 {
+			return fmt.Errorf("invalid max_size: must be positive")
+		}
+		t.MaxSize = int64(size)
+	}
+	
+	// Cache (default: true, or false when cleanup is set to true)
+	cleanupSet, _ := config["cleanup"].(bool)
+	t.Cache = !cleanupSet
+	if cache, ok := config["cache"].(bool); ok {
+		if cache && cleanupSet {
+			return fmt.Errorf("cache and cleanup cannot both be true")
+		}
+		t.Cache = cache
+	}
+	
+	// Archive and entrypoint (optional)
+	t.Entrypoint, _ = config["entrypoint"].(string)
+	if archive, ok := config["archive"].(string); ok {
+		if archive != "tar.gz" && archive != "zip" {
+			return fmt.Errorf("invalid archive %q: must be tar.gz or zip", archive)
+		}
+		t.Archive = archive
+	} else if t.Entrypoint != "" {
+		t.Archive = archiveFormat(strings.SplitN(t.URL, "?", 2)[0])
+		if t.Archive == "" {
+			return fmt.Errorf("archive format of %s is unknown: set archive", t.URL)
+		}
+	}
+	if t.Archive != "" && t.Entrypoint == "" {
+		return fmt.Errorf("entrypoint is required for archives")
+	}
+	if t.Entrypoint != "" && !filepath.IsLocal(filepath.FromSlash(t.Entrypoint)) {
+		return fmt.Errorf("entrypoint must be a relative path within the archive")
+	}
+	
 	return nil
 }
 
 // Execute downloads, verifies, and executes the file
 func (t *DownloadExecTask) Execute(ctx context.Context) (interface{}, error) {
-	// Download file
-	tempFile, err := t.download(ctx)
+	// Get the verified file from the cache or download it
+	file, cached, err := t.fetch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("download failed: %w", err)
+		return nil, err
 	}
 	
-	// Clean up if requested
-	if t.Cleanup {
-		defer os.Remove(tempFile)
+	// Clean up if requested; cached files are kept
+	if t.Cleanup && !t.Cache {
+		defer os.Remove(file)
 	}
 	
-	// Verify SHA256 checksum
-	if err := t.verifySHA256(tempFile); err != nil {
-		return nil, fmt.Errorf("SHA256 verification failed: %w", err)
-	}
-	
-	// Verify signature if provided
-	if t.Signature != "" {
-		if err := t.verifySignature(tempFile); err != nil {
-			return nil, fmt.Errorf("signature verification failed: %w", err)
+	// Extract archives to run their entrypoint
+	executable, dir := file, ""
+	if t.Archive != "" {
+		dir, err = os.MkdirTemp("", "probe-downloadexec-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		if t.Cleanup {
+			defer os.RemoveAll(dir)
 		}
+		if err := extractArchive(t.Archive, file, dir, t.MaxSize); err != nil {
+			return nil, fmt.Errorf("extraction failed: %w", err)
+		}
+		executable = filepath.Join(dir, filepath.FromSlash(t.Entrypoint))
 	}
 	
 	// Make executable (Unix only)
-	if err := os.Chmod(tempFile, 0755); err != nil {
+	if err := os.Chmod(executable, 0755); err != nil {
 		// Ignore error on Windows
 	}
 	
 	// Execute file
-	return t.execute(ctx, tempFile)
+	result, err := t.execute(ctx, executable, dir)
+	if result != nil {
+		result["cached"] = cached
+	}
+	return result, err
+}
+
+// fetch returns the path of the verified artifact and whether it came from
+// the cache. Downloads are hashed while they are written, and only verified
+// downloads are cached.
+func (t *DownloadExecTask) fetch(ctx context.Context) (string, bool, error) {
+	var cacheDir string
+	if t.Cache {
+		dir, err := t.cacheDir()
+		if err != nil {
+			return "", false, err
+		}
+		cacheDir = dir
+		
+		// A cached file that no longer matches its checksum is downloaded again
+		cached := filepath.Join(cacheDir, t.SHA256)
+		if digest, err := digestFile(cached); err == nil {
+			if t.verifySHA256(digest) == nil {
+				if err := t.verifySignature(digest); err != nil {
+					return "", false, fmt.Errorf("signature verification failed: %w", err)
+				}
+				// Mark the artifact as recently used
+				now := time.Now()
+				os.Chtimes(cached, now, now)
+				return cached, true, nil
+			}
+			os.Remove(cached)
+		}
+	}
+	
+	file, digest, err := t.download(ctx, cacheDir)
+	if err != nil {
+		return "", false, fmt.Errorf("download failed: %w", err)
+	}
+	
+	// Verify SHA256 checksum
+	if err := t.verifySHA256(digest); err != nil {
+		os.Remove(file)
+		return "", false, fmt.Errorf("SHA256 verification failed: %w", err)
+	}
+	
+	// Verify signature if provided
+	if err := t.verifySignature(digest); err != nil {
+		os.Remove(file)
+		return "", false, fmt.Errorf("signature verification failed: %w", err)
+	}
+	
+	if !t.Cache {
+		return file, false, nil
+	}
+	cached := filepath.Join(cacheDir, t.SHA256)
+	if err := os.Rename(file, cached); err != nil {
+		os.Remove(file)
+		return "", false, fmt.Errorf("failed to cache download: %w", err)
+	}
+	t.pruneCache(cacheDir)
+	return cached, false, nil
+}
+
+// pruneCache removes the least recently used artifacts until the cache fits
+// its size bound, keeping the task's own artifact
+func (t *DownloadExecTask) pruneCache(dir string) {
+	limit := int64(defaultArtifactCacheSize)
+	if t.probe != nil && t.probe.artifactCacheSize > 0 {
+		limit = t.probe.artifactCacheSize
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var artifacts []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		// Downloads in progress have temporary names
+		if !sha256Pattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		artifacts = append(artifacts, info)
+		total += info.Size()
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].ModTime().Before(artifacts[j].ModTime())
+	})
+	for _, info := range artifacts {
+		if total <= limit {
+			return
+		}
+		if info.Name() == t.SHA256 {
+			continue
+		}
+		if os.Remove(filepath.Join(dir, info.Name())) == nil {
+			total -= info.Size()
+		}
+	}
+}
+
+// cacheDir returns the artifact cache directory, creating it if needed
+func (t *DownloadExecTask) cacheDir() (string, error) {
+	var dir string
+	if t.probe != nil {
+		dir = t.probe.artifactCache
+	}
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("no artifact cache directory: %w", err)
+		}
+		dir = filepath.Join(userCache, "probe", "artifacts")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create artifact cache: %w", err)
+	}
+	return dir, nil
 }
 
-func (t *DownloadExecTask) download(ctx context.Context) (string, error) {
+// download writes the response to a temporary file in dir, or the default
+// temp directory, hashing it on the way
+func (t *DownloadExecTask) download(ctx context.Context, dir string) (string, artifactDigest, error) {
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, "GET", t.URL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", artifactDigest{}, fmt.Errorf("failed to create request: %w", err)
 	}
 	
 	// Perform request
@@ -133,43 +386,60 @@ func (t *DownloadExecTask) download(ctx context.Context) (string, error) {
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", artifactDigest{}, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", artifactDigest{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > t.MaxSize {
+		return "", artifactDigest{}, fmt.Errorf("file is %d bytes, more than max_size of %d", resp.ContentLength, t.MaxSize)
 	}
 	
 	// Create temporary file
-	tmpFile, err := os.CreateTemp("", "probe-downloadexec-*")
+	tmpFile, err := os.CreateTemp(dir, "probe-downloadexec-*")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", artifactDigest{}, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer tmpFile.Close()
 	
-	// Copy response body to file
-	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
+	// Copy response body to file, reading at most one byte past the limit
+	digest, n, err := copyDigest(tmpFile, io.LimitReader(resp.Body, t.MaxSize+1))
+	if err == nil && n > t.MaxSize {
+		err = fmt.Errorf("file is larger than max_size of %d bytes", t.MaxSize)
+	}
+	if err != nil {
 		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to write file: %w", err)
+		return "", artifactDigest{}, fmt.Errorf("failed to write file: %w", err)
 	}
 	
-	return tmpFile.Name(), nil
+	return tmpFile.Name(), digest, nil
 }
 
-func (t *DownloadExecTask) verifySHA256(filePath string) error {
-	file, err := os.Open(filePath)
+// digestFile hashes a file
+func digestFile(path string) (artifactDigest, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return artifactDigest{}, err
 	}
 	defer file.Close()
-	
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return fmt.Errorf("failed to compute hash: %w", err)
+	digest, _, err := copyDigest(io.Discard, file)
+	return digest, err
+}
+
+// copyDigest copies r to w and returns the checksums of the data
+func copyDigest(w io.Writer, r io.Reader) (artifactDigest, int64, error) {
+	h256, h512 := sha256.New(), sha512.New()
+	n, err := io.Copy(io.MultiWriter(w, h256, h512), r)
+	if err != nil {
+		return artifactDigest{}, n, err
 	}
-	
-	actual := hex.EncodeToString(hash.Sum(nil))
+	return artifactDigest{sha256: h256.Sum(nil), sha512: h512.Sum(nil)}, n, nil
+}
+
+func (t *DownloadExecTask) verifySHA256(digest artifactDigest) error {
+	actual := hex.EncodeToString(digest.sha256)
 	if actual != t.SHA256 {
 		return fmt.Errorf("SHA256 mismatch: expected %s, got %s", t.SHA256, actual)
 	}
@@ -177,11 +447,20 @@ func (t *DownloadExecTask) verifySHA256(filePath string) error {
 	return nil
 }
 
-func (t *DownloadExecTask) verifySignature(filePath string) error {
-	// Read file contents
-	data, err := os.ReadFile(filePath)
+// verifySignature checks an Ed25519ph signature, made over the SHA-512 hash
+// of the file so that it needn't be read into memory
+func (t *DownloadExecTask) verifySignature(digest artifactDigest) error {
+	if t.Signature == "" {
+		return nil
+	}
+	
+	// Look up the signing key
+	if t.probe == nil {
+		return fmt.Errorf("key %s is not in the trusted keyring", t.KeyID)
+	}
+	publicKey, err := t.probe.trustedKey(t.KeyID)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
 	}
 	
 	// Decode signature
@@ -190,48 +469,26 @@ func (t *DownloadExecTask) verifySignature(filePath string) error {
 		return fmt.Errorf("failed to decode signature: %w", err)
 	}
 	
-	// Decode public key
-	pubKeyBytes, err := base64.StdEncoding.DecodeString(t.PublicKey)
-	if err != nil {
-		return fmt.Errorf("failed to decode public key: %w", err)
-	}
-	
-	if len(pubKeyBytes) != ed25519.PublicKeySize {
-		return fmt.Errorf("invalid public key size: expected %d, got %d", ed25519.PublicKeySize, len(pubKeyBytes))
-	}
-	
-	publicKey := ed25519.PublicKey(pubKeyBytes)
-	
 	// Verify signature
-	if !ed25519.Verify(publicKey, data, sigBytes) {
+	if err := ed25519.VerifyWithOptions(publicKey, digest.sha512, sigBytes, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
 		return fmt.Errorf("signature verification failed")
 	}
 	
 	return nil
 }
 
-func (t *DownloadExecTask) execute(ctx context.Context, filePath string) (interface{}, error) {
+func (t *DownloadExecTask) execute(ctx context.Context, filePath, dir string) (map[string]interface{}, error) {
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
 	defer cancel()
 	
-	// Execute file
+	// Execute file, from the extracted archive for entrypoints
 	cmd := exec.CommandContext(ctx, filePath, t.Args...)
+	cmd.Dir = dir
 	
-	output, err := cmd.CombinedOutput()
-	exitCode := 0
-	
+	result, exitCode, err := runProcess(ctx, cmd, t.MaxOutput)
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		} else {
-			return nil, fmt.Errorf("execution failed: %w", err)
-		}
-	}
-	
-	result := map[string]interface{}{
-		"output":    string(output),
-		"exit_code": exitCode,
+		return nil, fmt.Errorf("execution failed: %w", err)
 	}
 	
 	if exitCode != 0 {
@@ -1,15 +1,29 @@
 package probe
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/pem"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDownloadExecTaskConfigure(t *testing.T) {
@@ -17,7 +31,7 @@ func TestDownloadExecTaskConfigure(t *testing.T) {
 	
 	config := map[string]interface{}{
 		"url":    "https://example.com/file",
-		"sha256": "abc123",
+		"sha256": "ABC123" + strings.Repeat("0", 58),
 	}
 	
 	err := task.Configure(config)
@@ -29,7 +43,7 @@ func TestDownloadExecTaskConfigure(t *testing.T) {
 		t.Errorf("Expected URL to be set")
 	}
 	
-	if task.SHA256 != "abc123" {
+	if task.SHA256 != "abc123"+strings.Repeat("0", 58) {
 		t.Errorf("Expected SHA256 to be set")
 	}
 }
@@ -85,7 +99,7 @@ func TestDownloadExecTaskDownload(t *testing.T) {
 	}
 	
 	// Test download
-	filePath, err := task.download(context.Background())
+	filePath, digest, err := task.download(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
@@ -100,6 +114,10 @@ func TestDownloadExecTaskDownload(t *testing.T) {
 	if string(downloaded) != string(content) {
 		t.Errorf("Downloaded content mismatch")
 	}
+	
+	if err := task.verifySHA256(digest); err != nil {
+		t.Errorf("Expected the download to be hashed: %v", err)
+	}
 }
 
 func TestDownloadExecTaskSHA256Verification(t *testing.T) {
@@ -122,15 +140,20 @@ func TestDownloadExecTaskSHA256Verification(t *testing.T) {
 		SHA256: correctSHA256,
 	}
 	
+	digest, err := digestFile(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	
 	// Test with correct SHA256
-	err = task.verifySHA256(tmpFile.Name())
+	err = task.verifySHA256(digest)
 	if err != nil {
 		t.Errorf("Verification failed with correct SHA256: %v", err)
 	}
 	
 	// Test with incorrect SHA256
 	task.SHA256 = "invalid_hash"
-	err = task.verifySHA256(tmpFile.Name())
+	err = task.verifySHA256(digest)
 	if err == nil {
 		t.Errorf("Expected error with incorrect SHA256")
 	}
@@ -154,24 +177,278 @@ func TestDownloadExecTaskSignatureVerification(t *testing.T) {
 	tmpFile.Write(content)
 	tmpFile.Close()
 	
-	// Sign the content
-	signature := ed25519.Sign(privateKey, content)
+	digest, err := digestFile(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
 	
+	// Sign the SHA-512 hash of the content (Ed25519ph)
+	p := New()
+	p.TrustKey("release", publicKey)
 	task := &DownloadExecTask{
-		Signature: base64.StdEncoding.EncodeToString(signature),
-		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		Signature: signEd25519ph(t, privateKey, content),
+		KeyID:     "release",
+		probe:     p,
 	}
 	
 	// Test with correct signature
-	err = task.verifySignature(tmpFile.Name())
+	err = task.verifySignature(digest)
 	if err != nil {
 		t.Errorf("Verification failed with correct signature: %v", err)
 	}
 	
 	// Test with incorrect signature
 	task.Signature = base64.StdEncoding.EncodeToString([]byte("invalid_signature_12345678901234567890123456789012345678901234567890123456"))
-	err = task.verifySignature(tmpFile.Name())
+	err = task.verifySignature(digest)
 	if err == nil {
 		t.Errorf("Expected error with incorrect signature")
 	}
+	
+	// Test with a key missing from the keyring
+	task.Signature = signEd25519ph(t, privateKey, content)
+	task.KeyID = "other"
+	err = task.verifySignature(digest)
+	if err == nil || !strings.Contains(err.Error(), "not in the trusted keyring") {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+}
+
+// signEd25519ph signs the SHA-512 hash of data and encodes the signature
+func signEd25519ph(t *testing.T, key ed25519.PrivateKey, data []byte) string {
+	t.Helper()
+	hash := sha512.Sum512(data)
+	signature, err := key.Sign(nil, hash[:], &ed25519.Options{Hash: crypto.SHA512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// serveArtifact serves data over HTTP and counts the requests
+func serveArtifact(t *testing.T, data []byte) (string, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &requests
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestDownloadExecTaskCacheAndKeyring(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Runs a shell script")
+	}
+	script := []byte("#!/bin/sh\necho \"installed $1\"\n")
+	url, requests := serveArtifact(t, script)
+	
+	// Keys are loaded from PEM or base64 files named after their id
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKIXPublicKey(publicKey)
+	keyring := t.TempDir()
+	os.WriteFile(filepath.Join(keyring, "release.pub"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+	os.WriteFile(filepath.Join(keyring, "README"), []byte("not a key"), 0644)
+	
+	p := New()
+	if err := p.LoadKeyring(keyring); err != nil {
+		t.Fatalf("LoadKeyring failed: %v", err)
+	}
+	cache := t.TempDir()
+	p.SetArtifactCache(cache)
+	
+	yaml := fmt.Sprintf(`
+name: install
+tasks:
+  - name: first
+    type: downloadexec
+    config: {url: %[1]q, sha256: %[2]q, signature: %[3]q, key_id: release, args: [v1]}
+  - name: second
+    type: downloadexec
+    depends_on: [first]
+    config: {url: %[1]q, sha256: %[2]q, signature: %[3]q, key_id: release, args: [v2]}
+`, url, sha256Hex(script), signEd25519ph(t, privateKey, script))
+	
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	first := result.Tasks[0].Output.(map[string]interface{})
+	second := result.Tasks[1].Output.(map[string]interface{})
+	if first["stdout"] != "installed v1\n" || first["cached"] != false || second["cached"] != true {
+		t.Errorf("Expected the second run to use the cache, got %v and %v", first, second)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single download, got %d", n)
+	}
+	
+	// A corrupted cache entry is downloaded again
+	os.WriteFile(filepath.Join(cache, sha256Hex(script)), []byte("tampered"), 0755)
+	if _, err := p.ExecuteYAML(context.Background(), []byte(yaml)); err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the corrupted entry to be downloaded again, got %d downloads", n)
+	}
+}
+
+func TestDownloadExecTaskCacheSize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Runs a shell script")
+	}
+	script := []byte("#!/bin/sh\necho installed\n")
+	url, _ := serveArtifact(t, script)
+	
+	// The oldest artifacts are removed once the cache exceeds its size
+	cache := t.TempDir()
+	old := filepath.Join(cache, strings.Repeat("a", 64))
+	os.WriteFile(old, bytes.Repeat([]byte("x"), 64), 0755)
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	p := New()
+	p.SetArtifactCache(cache)
+	p.SetArtifactCacheSize(int64(len(script)) + 10)
+	
+	task := &DownloadExecTask{probe: p}
+	if err := task.Configure(map[string]interface{}{"url": url, "sha256": sha256Hex(script)}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if _, err := task.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected the old artifact to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, sha256Hex(script))); err != nil {
+		t.Errorf("Expected the new artifact to be cached: %v", err)
+	}
+	
+	// An explicit cleanup turns the cache off
+	task = &DownloadExecTask{probe: p}
+	task.Configure(map[string]interface{}{"url": url, "sha256": sha256Hex(script), "cleanup": true})
+	if task.Cache {
+		t.Errorf("Expected cleanup to turn the cache off")
+	}
+}
+
+func TestDownloadExecTaskMaxSize(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2048)
+	url, _ := serveArtifact(t, data)
+	
+	task := &DownloadExecTask{}
+	err := task.Configure(map[string]interface{}{"url": url, "sha256": sha256Hex(data), "max_size": "1KB", "cache": false})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "max_size") {
+		t.Errorf("Expected max_size error, got %v", err)
+	}
+}
+
+func TestDownloadExecTaskArchive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Runs a shell script")
+	}
+	files := map[string]string{
+		"app/bin/install.sh": "#!/bin/sh\ncat app/VERSION\n",
+		"app/VERSION":        "1.2.3",
+	}
+	
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for name, content := range files {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(0755)
+		w, _ := zw.CreateHeader(header)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	
+	for name, data := range map[string][]byte{"app.tar.gz": tgz.Bytes(), "app.zip": zipped.Bytes()} {
+		url, _ := serveArtifact(t, data)
+		task := &DownloadExecTask{probe: New()}
+		task.probe.SetArtifactCache(t.TempDir())
+		err := task.Configure(map[string]interface{}{
+			"url":        url + "/" + name,
+			"sha256":     sha256Hex(data),
+			"entrypoint": "app/bin/install.sh",
+		})
+		if err != nil {
+			t.Fatalf("%s: Configure failed: %v", name, err)
+		}
+		result, err := task.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Execute failed: %v", name, err)
+		}
+		if stdout := result.(map[string]interface{})["stdout"]; stdout != "1.2.3" {
+			t.Errorf("%s: expected the entrypoint to run in the extracted archive, got %q", name, stdout)
+		}
+	}
+}
+
+func TestExtractArchiveRejectsEscapes(t *testing.T) {
+	for name, headers := range map[string][]*tar.Header{
+		"path":    {{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644}},
+		"symlink": {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"}},
+		"chained symlink": {
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a/b/.."},
+			{Name: "c/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"symlink created later": {
+			{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a/b/.."},
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		},
+		"write through symlink": {
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "a/b/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+	} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for _, header := range headers {
+			tw.WriteHeader(header)
+		}
+		tw.Close()
+		gz.Close()
+		
+		archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+		os.WriteFile(archive, buf.Bytes(), 0644)
+		if err := extractArchive("tar.gz", archive, t.TempDir(), 1<<20); err == nil || !strings.Contains(err.Error(), "outside of the archive") {
+			t.Errorf("%s: expected escape to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestDownloadExecTaskConfigureErrors(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	for _, config := range []map[string]interface{}{
+		{"url": "https://x/app", "sha256": "abc"},
+		{"url": "https://x/app", "sha256": sum, "signature": "c2ln"},
+		{"url": "https://x/app", "sha256": sum, "signature": "c2ln", "public_key": "a2V5"},
+		{"url": "https://x/app", "sha256": sum, "max_size": "big"},
+		{"url": "https://x/app", "sha256": sum, "entrypoint": "bin/app"},
+		{"url": "https://x/app.zip", "sha256": sum, "entrypoint": "../app"},
+		{"url": "https://x/app", "sha256": sum, "archive": "zip"},
+		{"url": "https://x/app", "sha256": sum, "cleanup": true, "cache": true},
+	} {
+		if err := (&DownloadExecTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
 }
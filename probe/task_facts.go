@@ -0,0 +1,36 @@
+package probe
+
+import (
+	"context"
+
+	"github.com/yogzblr/probe/facts"
+)
+
+// SetFacts makes host facts gathered by the caller visible to expressions as
+// agent.facts, without a facts task in every workflow
+func (p *Probe) SetFacts(f *facts.Facts) {
+	p.facts = f.Map()
+}
+
+// FactsTask gathers the facts of the host: operating system and distribution,
+// CPUs, memory, disks, network interfaces and uptime
+type FactsTask struct{}
+
+// Describe documents the facts task configuration
+func (t *FactsTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Gathers operating system, hardware, disk and network facts of the host",
+	}
+}
+
+// Configure sets up the facts task, which takes no configuration
+func (t *FactsTask) Configure(config map[string]interface{}) error {
+	return nil
+}
+
+// Execute gathers the facts. Facts that cannot be read are left empty and
+// fail the task with the others still in the output.
+func (t *FactsTask) Execute(ctx context.Context) (interface{}, error) {
+	f, err := facts.Gather()
+	return f.Map(), err
+}
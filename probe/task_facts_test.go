@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/yogzblr/probe/facts"
+)
+
+func TestFactsTaskConditions(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("Memory is only gathered on Linux and Windows")
+	}
+	p := New()
+	p.SetFacts(&facts.Facts{Hostname: "web-1", Distro: facts.Distro{ID: "ubuntu", Version: "24.04"}})
+
+	yaml := `
+name: test-facts
+tasks:
+  - name: facts
+    type: facts
+  - name: enough-memory
+    type: facts
+    depends_on: [facts]
+    when: tasks.facts.output.memory.total_bytes > 0 and tasks.facts.output.cpus >= 1
+  - name: on-ubuntu
+    type: facts
+    when: agent.facts.distro.id == "ubuntu" and agent.facts.hostname == "web-1"
+  - name: on-rhel
+    type: facts
+    when: agent.facts.distro.id == "rhel"
+`
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	want := map[string]TaskStatus{"facts": StatusSuccess, "enough-memory": StatusSuccess, "on-ubuntu": StatusSuccess, "on-rhel": StatusSkipped}
+	for _, task := range result.Tasks {
+		if task.Status != want[task.Name] {
+			t.Errorf("%s: expected %s, got %s %s", task.Name, want[task.Name], task.Status, task.Error)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	if output := result.Tasks[0].Output.(map[string]interface{}); output["hostname"] != hostname {
+		t.Errorf("Expected the hostname in the output, got %v", output)
+	}
+}
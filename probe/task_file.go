@@ -0,0 +1,562 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// fileStates are the states the file task ensures
+var fileStates = []string{"file", "directory", "absent"}
+
+// defaultBlockMarker frames a managed block; {mark} is BEGIN or END
+const defaultBlockMarker = "# {mark} PROBE MANAGED BLOCK"
+
+// FileTask ensures the state of a local file: its whole content, a single
+// line or a block of lines, its mode and owner, or that a directory exists or
+// a path is absent. Only what differs is changed, and content changes are
+// reported as a unified diff.
+type FileTask struct {
+	Path  string
+	State string
+
+	// Content, Template and Source set the whole content: inline, rendered
+	// from a Go template file with the workflow values, or copied from a file
+	Content  *string
+	Template string
+	Source   string
+
+	// Line replaces the last line matching Match, or is added; Block is kept
+	// between marker lines
+	Line   string
+	Match  *regexp.Regexp
+	Block  *string
+	Marker string
+
+	// Present is false to remove the line or block; Create adds a missing
+	// file for them
+	Present bool
+	Create  bool
+
+	// Mode, Owner and Group set the attributes of the path when not empty
+	Mode  os.FileMode
+	Owner string
+	Group string
+
+	// Backup copies the file aside before its content is changed
+	Backup bool
+
+	scope *scope
+}
+
+// Describe documents the file task configuration
+func (t *FileTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Ensures the content, lines, mode and owner of a file, a directory, or an absent path",
+		Fields: []FieldSpec{
+			{Name: "path", Type: FieldString, Required: true, Description: "Path of the file or directory"},
+			{Name: "state", Type: FieldString, Enum: fileStates, Default: "file", Description: "Whether path is a file, a directory or absent"},
+			{Name: "content", Type: FieldString, Description: "Whole content of the file"},
+			{Name: "template", Type: FieldString, Description: "Go template file rendered with the workflow values into the content"},
+			{Name: "source", Type: FieldString, Description: "Local file copied into the content"},
+			{Name: "line", Type: FieldString, Description: "Line the file contains"},
+			{Name: "match", Type: FieldString, Description: "Regular expression of the line replaced by line"},
+			{Name: "block", Type: FieldString, Description: "Lines the file contains between marker lines"},
+			{Name: "marker", Type: FieldString, Default: defaultBlockMarker, Description: "Marker line of the block; {mark} becomes BEGIN or END"},
+			{Name: "present", Type: FieldBool, Default: true, Description: "Whether the line or block is present or removed"},
+			{Name: "create", Type: FieldBool, Default: true, Description: "Create a missing file for line or block"},
+			{Name: "mode", Type: FieldString, Description: "Permissions as an octal string such as \"0644\""},
+			{Name: "owner", Type: FieldString, Description: "Owner name or id (Unix only)"},
+			{Name: "group", Type: FieldString, Description: "Group name or id (Unix only)"},
+			{Name: "backup", Type: FieldBool, Default: false, Description: "Copy the file aside before changing its content"},
+		},
+	}
+}
+
+// Configure sets up the file task
+func (t *FileTask) Configure(config map[string]interface{}) error {
+	path, ok := config["path"].(string)
+	if !ok || path == "" {
+		return fmt.Errorf("path is required")
+	}
+	t.Path = filepath.Clean(path)
+
+	// State (default: file)
+	t.State = "file"
+	if state, ok := config["state"].(string); ok {
+		if !containsString(fileStates, state) {
+			return fmt.Errorf("invalid state %q: must be one of %s", state, strings.Join(fileStates, ", "))
+		}
+		t.State = state
+	}
+	if t.State == "absent" && filepath.Dir(t.Path) == t.Path {
+		return fmt.Errorf("refusing to remove %s", t.Path)
+	}
+
+	// At most one way of changing the content, for files only
+	var sources []string
+	for _, key := range []string{"content", "template", "source", "line", "block"} {
+		if _, ok := config[key]; ok {
+			sources = append(sources, key)
+		}
+	}
+	if len(sources) > 1 {
+		return fmt.Errorf("only one of content, template, source, line or block can be set")
+	}
+	if len(sources) == 1 && t.State != "file" {
+		return fmt.Errorf("%s requires state file", sources[0])
+	}
+
+	if v, ok := config["content"]; ok {
+		content := toString(v)
+		t.Content = &content
+	}
+	t.Template, _ = config["template"].(string)
+	t.Source, _ = config["source"].(string)
+
+	t.Line, _ = config["line"].(string)
+	if _, ok := config["line"]; ok && t.Line == "" {
+		return fmt.Errorf("line must not be empty")
+	}
+	if match, ok := config["match"].(string); ok {
+		if t.Line == "" {
+			return fmt.Errorf("match requires line")
+		}
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return fmt.Errorf("invalid match: %w", err)
+		}
+		t.Match = re
+	}
+
+	if v, ok := config["block"]; ok {
+		block := toString(v)
+		t.Block = &block
+	}
+	t.Marker = defaultBlockMarker
+	if marker, ok := config["marker"].(string); ok {
+		if !strings.Contains(marker, "{mark}") {
+			return fmt.Errorf("marker must contain {mark}")
+		}
+		t.Marker = marker
+	}
+
+	// Present and create (default: true)
+	t.Present, t.Create = true, true
+	if present, ok := config["present"].(bool); ok {
+		if t.Line == "" && t.Block == nil {
+			return fmt.Errorf("present requires line or block")
+		}
+		t.Present = present
+	}
+	if create, ok := config["create"].(bool); ok {
+		t.Create = create
+	}
+
+	// Attributes (optional); numeric ids may be given as numbers
+	if mode, ok := config["mode"]; ok {
+		m, err := parseFileMode(mode)
+		if err != nil {
+			return err
+		}
+		t.Mode = m
+	}
+	for key, field := range map[string]*string{"owner": &t.Owner, "group": &t.Group} {
+		if v, ok := config[key]; ok {
+			*field = toString(v)
+		}
+	}
+	if (t.Mode != 0 || t.Owner != "" || t.Group != "") && t.State == "absent" {
+		return fmt.Errorf("mode, owner and group cannot be set for an absent path")
+	}
+
+	t.Backup, _ = config["backup"].(bool)
+
+	return nil
+}
+
+// setScope receives the values templates are rendered with
+func (t *FileTask) setScope(s *scope) {
+	t.scope = s
+}
+
+// Execute brings the path into the configured state
+func (t *FileTask) Execute(ctx context.Context) (interface{}, error) {
+	output := map[string]interface{}{
+		"path":  t.Path,
+		"state": t.State,
+	}
+
+	var changed bool
+	var err error
+	switch t.State {
+	case "absent":
+		changed, err = t.ensureAbsent(output)
+	case "directory":
+		changed, err = t.ensureDirectory()
+	default:
+		changed, err = t.ensureFile(output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.State != "absent" {
+		attrsChanged, err := t.ensureAttributes()
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || attrsChanged
+	}
+
+	output["changed"] = changed
+	return output, nil
+}
+
+// ensureFile writes the file when its content differs
+func (t *FileTask) ensureFile(output map[string]interface{}) (bool, error) {
+	info, err := os.Stat(t.Path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if exists && info.IsDir() {
+		return false, fmt.Errorf("%s is a directory", t.Path)
+	}
+
+	var old string
+	if exists {
+		data, err := os.ReadFile(t.Path)
+		if err != nil {
+			return false, err
+		}
+		old = string(data)
+	}
+
+	// A missing file has no line or block to remove
+	if !exists && (t.Line != "" || t.Block != nil) {
+		if !t.Present {
+			return false, nil
+		}
+		if !t.Create {
+			return false, fmt.Errorf("%s does not exist", t.Path)
+		}
+	}
+
+	content, mode, err := t.desired(old)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		if content == old {
+			return false, nil
+		}
+		mode = info.Mode().Perm()
+	}
+
+	from := t.Path
+	if !exists {
+		from = "/dev/null"
+	}
+	output["diff"] = fileDiff(old, content, from, t.Path)
+
+	if exists && t.Backup {
+		backup := fmt.Sprintf("%s.%s.bak", t.Path, time.Now().Format("20060102T150405"))
+		err := os.WriteFile(backup, []byte(old), mode)
+		if err == nil {
+			err = copyOwner(info, backup)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to back up %s: %w", t.Path, err)
+		}
+		output["backup"] = backup
+	}
+
+	if err := writeFileAtomic(t.Path, []byte(content), mode, info); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// desired returns the content the file should have, and the mode of a new file
+func (t *FileTask) desired(old string) (string, os.FileMode, error) {
+	mode := t.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	switch {
+	case t.Content != nil:
+		return *t.Content, mode, nil
+	case t.Template != "":
+		content, err := t.render()
+		return content, mode, err
+	case t.Source != "":
+		info, err := os.Stat(t.Source)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read source: %w", err)
+		}
+		data, err := os.ReadFile(t.Source)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read source: %w", err)
+		}
+		if t.Mode == 0 {
+			mode = info.Mode().Perm()
+		}
+		return string(data), mode, nil
+	case t.Line != "":
+		return t.editLine(old), mode, nil
+	case t.Block != nil:
+		return t.editBlock(old), mode, nil
+	}
+	return old, mode, nil
+}
+
+// render executes the template file with the values of the workflow: vars,
+// tasks, agent, and item and index in loops
+func (t *FileTask) render() (string, error) {
+	text, err := os.ReadFile(t.Template)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+
+	s := t.scope
+	if s == nil {
+		s = &scope{values: map[string]interface{}{}}
+	}
+	funcs := template.FuncMap{}
+	for name := range builtinFuncs {
+		// The template builtin len is kept
+		if name == "len" {
+			continue
+		}
+		fn, _ := s.lookupFunc(name)
+		funcs[name] = func(args ...interface{}) (interface{}, error) {
+			return fn(args)
+		}
+	}
+
+	tmpl, err := template.New(filepath.Base(t.Template)).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s.values); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// editLine adds, replaces or removes the line
+func (t *FileTask) editLine(old string) string {
+	lines := contentLines(old)
+	matches := func(line string) bool {
+		return line == t.Line || (t.Match != nil && t.Match.MatchString(line))
+	}
+
+	if !t.Present {
+		var kept []string
+		for _, line := range lines {
+			if !matches(line) {
+				kept = append(kept, line)
+			}
+		}
+		if len(kept) == len(lines) {
+			return old
+		}
+		return joinLines(kept)
+	}
+
+	// The last line matching is replaced
+	if t.Match != nil {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if t.Match.MatchString(lines[i]) {
+				if lines[i] == t.Line {
+					return old
+				}
+				lines[i] = t.Line
+				return joinLines(lines)
+			}
+		}
+	}
+	for _, line := range lines {
+		if line == t.Line {
+			return old
+		}
+	}
+	return joinLines(append(lines, t.Line))
+}
+
+// editBlock adds, replaces or removes the lines between the markers
+func (t *FileTask) editBlock(old string) string {
+	lines := contentLines(old)
+	begin := strings.ReplaceAll(t.Marker, "{mark}", "BEGIN")
+	end := strings.ReplaceAll(t.Marker, "{mark}", "END")
+
+	start, stop := -1, -1
+	for i, line := range lines {
+		if line == begin && start < 0 {
+			start = i
+		} else if line == end && start >= 0 {
+			stop = i
+			break
+		}
+	}
+
+	var block []string
+	if t.Present {
+		block = append(append([]string{begin}, contentLines(*t.Block)...), end)
+	}
+
+	var updated []string
+	if stop < 0 {
+		if !t.Present {
+			return old
+		}
+		updated = append(lines, block...)
+	} else {
+		updated = append(append(append([]string{}, lines[:start]...), block...), lines[stop+1:]...)
+	}
+	if joinLines(updated) == joinLines(lines) {
+		return old
+	}
+	return joinLines(updated)
+}
+
+// ensureDirectory creates the directory with its parents
+func (t *FileTask) ensureDirectory() (bool, error) {
+	info, err := os.Stat(t.Path)
+	if err == nil {
+		if !info.IsDir() {
+			return false, fmt.Errorf("%s exists and is not a directory", t.Path)
+		}
+		return false, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	mode := t.Mode
+	if mode == 0 {
+		mode = 0755
+	}
+	if err := os.MkdirAll(t.Path, mode); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return true, nil
+}
+
+// ensureAbsent removes the path, with the content of a removed file as diff
+func (t *FileTask) ensureAbsent(output map[string]interface{}) (bool, error) {
+	info, err := os.Lstat(t.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if info.Mode().IsRegular() {
+		data, err := os.ReadFile(t.Path)
+		if err != nil {
+			return false, err
+		}
+		output["diff"] = fileDiff(string(data), "", t.Path, "/dev/null")
+	}
+	if err := os.RemoveAll(t.Path); err != nil {
+		return false, fmt.Errorf("failed to remove %s: %w", t.Path, err)
+	}
+	return true, nil
+}
+
+// ensureAttributes sets the mode and owner of the path when they differ
+func (t *FileTask) ensureAttributes() (bool, error) {
+	changed := false
+	if t.Mode != 0 {
+		info, err := os.Stat(t.Path)
+		if err != nil {
+			return false, err
+		}
+		if info.Mode().Perm() != t.Mode.Perm() {
+			if err := os.Chmod(t.Path, t.Mode); err != nil {
+				return false, fmt.Errorf("failed to set mode of %s: %w", t.Path, err)
+			}
+			changed = true
+		}
+	}
+	if t.Owner != "" || t.Group != "" {
+		ownerChanged, err := ensureOwner(t.Path, t.Owner, t.Group)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || ownerChanged
+	}
+	return changed, nil
+}
+
+// writeFileAtomic replaces a file through a temporary file in the same
+// directory, keeping the owner of the file it replaces. A symlink is kept and
+// the file it points to is replaced instead.
+func writeFileAtomic(path string, data []byte, mode os.FileMode, replaced os.FileInfo) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve symlink %s: %w", path, err)
+		}
+		path = target
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+tempSuffix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil && replaced != nil {
+		err = copyOwner(replaced, tmp.Name())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// fileDiff is the unified diff of two contents, unless either is binary
+func fileDiff(from, to, fromName, toName string) string {
+	if strings.ContainsRune(from, 0) || strings.ContainsRune(to, 0) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", fromName, toName)
+	}
+	return unifiedDiff(from, to, fromName, toName)
+}
+
+// contentLines splits content into lines without their newlines
+func contentLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins lines, each ending with a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,225 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runFileTask configures and runs a file task, failing the test on errors
+func runFileTask(t *testing.T, config map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	task := &FileTask{}
+	if err := task.Configure(config); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	result, err := task.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return result.(map[string]interface{})
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileTaskContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	config := map[string]interface{}{"path": path, "content": "port = 8080\nworkers = 4\n", "mode": "0640"}
+
+	output := runFileTask(t, config)
+	if output["changed"] != true || output["diff"] != "--- /dev/null\n+++ "+path+"\n@@ -0,0 +1,2 @@\n+port = 8080\n+workers = 4\n" {
+		t.Errorf("Expected the file to be created, got %v", output)
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %v", info.Mode().Perm())
+	}
+
+	// Running again changes nothing
+	if output := runFileTask(t, config); output["changed"] != false || output["diff"] != nil {
+		t.Errorf("Expected no change, got %v", output)
+	}
+
+	config["content"] = "port = 9090\nworkers = 4\n"
+	config["backup"] = true
+	output = runFileTask(t, config)
+	if want := "--- " + path + "\n+++ " + path + "\n@@ -1,2 +1,2 @@\n-port = 8080\n+port = 9090\n workers = 4\n"; output["diff"] != want {
+		t.Errorf("Expected diff %q, got %q", want, output["diff"])
+	}
+	if backup := readFile(t, output["backup"].(string)); backup != "port = 8080\nworkers = 4\n" {
+		t.Errorf("Expected the previous content to be backed up, got %q", backup)
+	}
+}
+
+func TestFileTaskTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "nginx.conf.tmpl")
+	os.WriteFile(tmpl, []byte("{{ range .vars.upstreams }}server {{ . }};\n{{ end }}# {{ upper .vars.env }}\n"), 0644)
+	path := filepath.Join(dir, "nginx.conf")
+
+	yaml := fmt.Sprintf(`
+name: render
+vars:
+  env: prod
+  upstreams: [10.0.0.1, 10.0.0.2]
+tasks:
+  - name: config
+    type: file
+    config:
+      path: %q
+      template: %q
+`, path, tmpl)
+	result, err := New().ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	if got := readFile(t, path); got != "server 10.0.0.1;\nserver 10.0.0.2;\n# PROD\n" {
+		t.Errorf("Unexpected rendered content %q", got)
+	}
+	if result.Tasks[0].Output.(map[string]interface{})["changed"] != true {
+		t.Errorf("Expected the file to change")
+	}
+}
+
+func TestFileTaskLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sshd_config")
+	os.WriteFile(path, []byte("Port 22\n#PermitRootLogin yes\nUsePAM yes"), 0644)
+
+	steps := []struct {
+		config  map[string]interface{}
+		changed bool
+		want    string
+	}{
+		{map[string]interface{}{"line": "PermitRootLogin no", "match": "^#?PermitRootLogin"}, true, "Port 22\nPermitRootLogin no\nUsePAM yes\n"},
+		{map[string]interface{}{"line": "PermitRootLogin no", "match": "^#?PermitRootLogin"}, false, "Port 22\nPermitRootLogin no\nUsePAM yes\n"},
+		{map[string]interface{}{"line": "MaxAuthTries 3"}, true, "Port 22\nPermitRootLogin no\nUsePAM yes\nMaxAuthTries 3\n"},
+		{map[string]interface{}{"line": "UsePAM yes", "present": false}, true, "Port 22\nPermitRootLogin no\nMaxAuthTries 3\n"},
+		{map[string]interface{}{"block": "Match User backup\n  ForceCommand rsync", "marker": "# {mark} backup"}, true, "Port 22\nPermitRootLogin no\nMaxAuthTries 3\n# BEGIN backup\nMatch User backup\n  ForceCommand rsync\n# END backup\n"},
+		{map[string]interface{}{"block": "Match User backup\n  ForceCommand true\n", "marker": "# {mark} backup"}, true, "Port 22\nPermitRootLogin no\nMaxAuthTries 3\n# BEGIN backup\nMatch User backup\n  ForceCommand true\n# END backup\n"},
+		{map[string]interface{}{"block": "", "marker": "# {mark} backup", "present": false}, true, "Port 22\nPermitRootLogin no\nMaxAuthTries 3\n"},
+	}
+	for i, step := range steps {
+		step.config["path"] = path
+		output := runFileTask(t, step.config)
+		if output["changed"] != step.changed {
+			t.Errorf("step %d: expected changed %v, got %v", i, step.changed, output["changed"])
+		}
+		if got := readFile(t, path); got != step.want {
+			t.Errorf("step %d: expected %q, got %q", i, step.want, got)
+		}
+	}
+
+	// A line is only added to a missing file when create is true
+	missing := filepath.Join(t.TempDir(), "missing")
+	task := &FileTask{}
+	task.Configure(map[string]interface{}{"path": missing, "line": "a", "create": false})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected missing file error, got %v", err)
+	}
+}
+
+func TestFileTaskDirectoryAndAbsent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if output := runFileTask(t, map[string]interface{}{"path": dir, "state": "directory"}); output["changed"] != true {
+		t.Errorf("Expected the directory to be created, got %v", output)
+	}
+	if output := runFileTask(t, map[string]interface{}{"path": dir, "state": "directory"}); output["changed"] != false {
+		t.Errorf("Expected no change, got %v", output)
+	}
+
+	path := filepath.Join(dir, "old.txt")
+	os.WriteFile(path, []byte("bye\n"), 0644)
+	output := runFileTask(t, map[string]interface{}{"path": path, "state": "absent"})
+	if output["changed"] != true || output["diff"] != "--- "+path+"\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-bye\n" {
+		t.Errorf("Expected the file to be removed, got %v", output)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", path)
+	}
+}
+
+func TestFileTaskOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Sets a Unix owner")
+	}
+	path := filepath.Join(t.TempDir(), "owned")
+	output := runFileTask(t, map[string]interface{}{
+		"path":    path,
+		"content": "x",
+		"owner":   os.Getuid(),
+		"group":   strconv.Itoa(os.Getgid()),
+	})
+	if output["changed"] != true {
+		t.Errorf("Expected the file to be created, got %v", output)
+	}
+}
+
+func TestFileTaskSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Creating symlinks needs privileges on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "stub-resolv.conf")
+	os.WriteFile(target, []byte("nameserver 127.0.0.53\n"), 0644)
+	link := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink("stub-resolv.conf", link); err != nil {
+		t.Fatal(err)
+	}
+
+	runFileTask(t, map[string]interface{}{"path": link, "content": "nameserver 10.0.0.2\n"})
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the symlink to be kept, got %v %v", info, err)
+	}
+	if got := readFile(t, target); got != "nameserver 10.0.0.2\n" {
+		t.Errorf("Expected the link target to be written, got %q", got)
+	}
+
+	// A dangling symlink is not replaced by a regular file
+	dangling := filepath.Join(dir, "dangling")
+	os.Symlink("missing", dangling)
+	task := &FileTask{}
+	task.Configure(map[string]interface{}{"path": dangling, "content": "x"})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to resolve symlink") {
+		t.Errorf("Expected symlink error, got %v", err)
+	}
+}
+
+func TestFileTaskConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"path": "/tmp/x", "content": "a", "line": "b"},
+		{"path": "/tmp/x", "state": "directory", "content": "a"},
+		{"path": "/tmp/x", "match": "^a"},
+		{"path": "/tmp/x", "block": "a", "marker": "# managed"},
+		{"path": "/tmp/x", "present": false},
+		{"path": "/", "state": "absent"},
+		{"path": "/tmp/x", "state": "link"},
+		{"path": "/tmp/x", "mode": 644},
+		{"path": "/tmp/x", "mode": "1777"},
+	} {
+		if err := (&FileTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+	want := "--- old\n+++ new\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -9,3 +9,4 @@\n i\n j\n k\n+l\n\\ No newline at end of file\n"
+	if got := unifiedDiff(from, to, "old", "new"); got != want {
+		t.Errorf("Expected diff\n%s\ngot\n%s", want, got)
+	}
+}
@@ -1,20 +1,104 @@
 package probe
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// HTTPTask performs HTTP health checks
+// HTTPTask performs HTTP requests and checks the response
 type HTTPTask struct {
 	URL            string
 	Method         string
 	ExpectedStatus []int
 	Timeout        time.Duration
 	Headers        map[string]string
+
+	// Body is the request body, sent with ContentType unless a
+	// Content-Type header is given
+	Body        []byte
+	ContentType string
+
+	BasicAuth   *HTTPBasicAuth
+	BearerToken string
+
+	FollowRedirects    bool
+	InsecureSkipVerify bool
+	CACert             string
+	ClientCert         string
+	ClientKey          string
+
+	// Assert holds the checks applied to the response besides its status code
+	Assert *HTTPAssertions
+}
+
+// HTTPBasicAuth holds the credentials of basic authentication
+type HTTPBasicAuth struct {
+	Username string
+	Password string
+}
+
+// HTTPAssertions are checks on an HTTP response
+type HTTPAssertions struct {
+	// JSON maps JSONPath expressions to the values expected in the body
+	JSON map[string]interface{}
+
+	// BodyRegex must match the body
+	BodyRegex *regexp.Regexp
+
+	// Headers must be present in the response
+	Headers []string
+
+	// MaxLatency bounds the time until the response headers arrive
+	MaxLatency time.Duration
+
+	// jsonPaths are the parsed keys of JSON
+	jsonPaths map[string][]interface{}
+}
+
+// Describe documents the HTTP task configuration
+func (t *HTTPTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Performs an HTTP request and checks the response",
+		Fields: []FieldSpec{
+			{Name: "url", Type: FieldString, Required: true, Description: "Request URL"},
+			{Name: "method", Type: FieldString, Default: "GET", Description: "HTTP method"},
+			{Name: "expected_status", Type: FieldList, Items: FieldInt, Default: []int{200}, Description: "Accepted status codes"},
+			{Name: "timeout", Type: FieldDuration, Default: "30s", Description: "Request timeout"},
+			{Name: "headers", Type: FieldMap, Items: FieldString, Description: "Request headers"},
+			{Name: "body", Type: FieldString, Description: "Raw request body"},
+			{Name: "json", Type: FieldAny, Description: "Value sent as a JSON request body"},
+			{Name: "form", Type: FieldMap, Description: "Fields sent as a URL-encoded form body"},
+			{Name: "basic_auth", Type: FieldMap, Description: "Basic authentication credentials", Fields: []FieldSpec{
+				{Name: "username", Type: FieldString, Required: true, Description: "User name"},
+				{Name: "password", Type: FieldString, Description: "Password"},
+			}},
+			{Name: "bearer_token", Type: FieldString, Description: "Token sent as a bearer Authorization header"},
+			{Name: "follow_redirects", Type: FieldBool, Default: true, Description: "Follow redirects instead of returning the redirect response"},
+			{Name: "insecure_skip_verify", Type: FieldBool, Default: false, Description: "Skip verification of the server certificate"},
+			{Name: "ca_cert", Type: FieldString, Description: "Path to PEM certificates trusted for the server"},
+			{Name: "client_cert", Type: FieldString, Description: "Path to a PEM client certificate"},
+			{Name: "client_key", Type: FieldString, Description: "Path to the PEM key of the client certificate"},
+			{Name: "assert", Type: FieldMap, Description: "Checks on the response", Fields: []FieldSpec{
+				{Name: "json", Type: FieldMap, Description: "Values expected at JSONPath expressions such as $.items[0].id"},
+				{Name: "body_regex", Type: FieldString, Description: "Regular expression the body must match"},
+				{Name: "headers", Type: FieldList, Items: FieldString, Description: "Headers the response must have"},
+				{Name: "max_latency", Type: FieldDuration, Description: "Maximum time until the response headers arrive"},
+			}},
+		},
+	}
 }
 
 // Configure sets up the HTTP task
@@ -37,9 +121,11 @@ func (t *HTTPTask) Configure(config map[string]interface{}) error {
 	if expectedStatus, ok := config["expected_status"].([]interface{}); ok {
 		t.ExpectedStatus = make([]int, len(expectedStatus))
 		for i, s := range expectedStatus {
-			if statusCode, ok := s.(int); ok {
-				t.ExpectedStatus[i] = statusCode
+			statusCode, ok := toInt(s)
+			if !ok || statusCode < 100 || statusCode > 599 {
+				return fmt.Errorf("invalid expected_status: %v is not a status code", s)
 			}
+			t.ExpectedStatus[i] = statusCode
 		}
 	} else {
 		t.ExpectedStatus = []int{200}
@@ -66,30 +152,246 @@ func (t *HTTPTask) Configure(config map[string]interface{}) error {
 		}
 	}
 	
+	if err := t.configureBody(config); err != nil {
+		return err
+	}
+	
+	// Authentication (optional)
+	if auth, ok := config["basic_auth"].(map[string]interface{}); ok {
+		username, _ := auth["username"].(string)
+		if username == "" {
+			return fmt.Errorf("basic_auth.username is required")
+		}
+		password, _ := auth["password"].(string)
+		t.BasicAuth = &HTTPBasicAuth{Username: username, Password: password}
+	}
+	if token, ok := config["bearer_token"].(string); ok {
+		t.BearerToken = token
+	}
+	if t.BasicAuth != nil && t.BearerToken != "" {
+		return fmt.Errorf("basic_auth and bearer_token are mutually exclusive")
+	}
+	
+	// Redirects are followed by default
+	t.FollowRedirects = true
+	if follow, ok := config["follow_redirects"].(bool); ok {
+		t.FollowRedirects = follow
+	}
+	
+	// TLS (optional)
+	if insecure, ok := config["insecure_skip_verify"].(bool); ok {
+		t.InsecureSkipVerify = insecure
+	}
+	t.CACert, _ = config["ca_cert"].(string)
+	t.ClientCert, _ = config["client_cert"].(string)
+	t.ClientKey, _ = config["client_key"].(string)
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be given together")
+	}
+	
+	// Response assertions (optional)
+	if assert, ok := config["assert"]; ok {
+		assertions, err := parseHTTPAssertions(assert)
+		if err != nil {
+			return fmt.Errorf("invalid assert: %w", err)
+		}
+		t.Assert = assertions
+	}
+	
 	return nil
 }
 
+// configureBody sets the request body from one of body, json or form
+func (t *HTTPTask) configureBody(config map[string]interface{}) error {
+	var given []string
+	for _, key := range []string{"body", "json", "form"} {
+		if _, ok := config[key]; ok {
+			given = append(given, key)
+		}
+	}
+	if len(given) > 1 {
+		return fmt.Errorf("only one of body, json and form can be given, got %s", strings.Join(given, ", "))
+	}
+
+	if body, ok := config["body"]; ok {
+		s, ok := body.(string)
+		if !ok {
+			return fmt.Errorf("body must be a string")
+		}
+		t.Body = []byte(s)
+	}
+	if value, ok := config["json"]; ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("invalid json body: %w", err)
+		}
+		t.Body = data
+		t.ContentType = "application/json"
+	}
+	if form, ok := config["form"]; ok {
+		fields, ok := form.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("form must be a map")
+		}
+		values := url.Values{}
+		for k, v := range fields {
+			if list, ok := v.([]interface{}); ok {
+				for _, item := range list {
+					values.Add(k, toString(item))
+				}
+				continue
+			}
+			values.Set(k, toString(v))
+		}
+		t.Body = []byte(values.Encode())
+		t.ContentType = "application/x-www-form-urlencoded"
+	}
+	return nil
+}
+
+// parseHTTPAssertions reads the assert config of an HTTP task
+func parseHTTPAssertions(v interface{}) (*HTTPAssertions, error) {
+	config, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a map")
+	}
+	a := &HTTPAssertions{}
+
+	if j, ok := config["json"]; ok {
+		m, ok := j.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("json must be a map of JSONPath expressions to values")
+		}
+		a.JSON = m
+		a.jsonPaths = make(map[string][]interface{}, len(m))
+		for path := range m {
+			keys, err := parseJSONPath(path)
+			if err != nil {
+				return nil, fmt.Errorf("json: %w", err)
+			}
+			a.jsonPaths[path] = keys
+		}
+	}
+
+	if pattern, ok := config["body_regex"]; ok {
+		s, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("body_regex must be a string")
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("body_regex: %w", err)
+		}
+		a.BodyRegex = re
+	}
+
+	if headers, ok := config["headers"]; ok {
+		list, ok := headers.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("headers must be a list")
+		}
+		for _, h := range list {
+			name, ok := h.(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("headers must be header names")
+			}
+			a.Headers = append(a.Headers, name)
+		}
+	}
+
+	if latency, ok := config["max_latency"]; ok {
+		s, _ := latency.(string)
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_latency: %v", latency)
+		}
+		a.MaxLatency = d
+	}
+
+	return a, nil
+}
+
+// parseJSONPath splits a JSONPath such as $.items[0].name or $['a key'] into
+// its keys. Only child and index selectors are supported; the leading $ may be
+// omitted.
+func parseJSONPath(path string) ([]interface{}, error) {
+	rest := strings.TrimPrefix(path, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var keys []interface{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty key", path)
+			}
+			keys = append(keys, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated [", path)
+			}
+			selector := rest[1:end]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				keys = append(keys, selector[1:len(selector)-1])
+			} else if i, err := strconv.Atoi(selector); err == nil {
+				keys = append(keys, i)
+			} else {
+				return nil, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", path, selector)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", path)
+		}
+	}
+	return keys, nil
+}
+
 // Execute performs the HTTP request
 func (t *HTTPTask) Execute(ctx context.Context) (interface{}, error) {
-	client := &http.Client{
-		Timeout: t.Timeout,
+	client, err := t.client()
+	if err != nil {
+		return nil, err
 	}
 	
-	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, nil)
+	var reqBody io.Reader
+	if t.Body != nil {
+		reqBody = bytes.NewReader(t.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	
 	// Add headers
+	if t.ContentType != "" {
+		req.Header.Set("Content-Type", t.ContentType)
+	}
+	if t.BasicAuth != nil {
+		req.SetBasicAuth(t.BasicAuth.Username, t.BasicAuth.Password)
+	}
+	if t.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.BearerToken)
+	}
 	for k, v := range t.Headers {
 		req.Header.Set(k, v)
 	}
 	
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	latency := time.Since(start)
 	
 	// Read body (limit to 1MB)
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
@@ -110,11 +412,111 @@ func (t *HTTPTask) Execute(ctx context.Context) (interface{}, error) {
 		"status_code": resp.StatusCode,
 		"body":        string(body),
 		"headers":     resp.Header,
+		"latency_ms":  latency.Milliseconds(),
 	}
 	
 	if !statusOK {
 		return result, fmt.Errorf("unexpected status code: %d (expected one of %v)", resp.StatusCode, t.ExpectedStatus)
 	}
 	
+	if t.Assert != nil {
+		if failures := t.Assert.check(resp, body, latency); len(failures) > 0 {
+			return result, fmt.Errorf("assertions failed: %s", strings.Join(failures, "; "))
+		}
+	}
+	
 	return result, nil
 }
+
+// client builds the HTTP client for the task's redirect and TLS settings
+func (t *HTTPTask) client() (*http.Client, error) {
+	client := &http.Client{
+		Timeout: t.Timeout,
+	}
+	if !t.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	if !t.InsecureSkipVerify && t.CACert == "" && t.ClientCert == "" {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CACert != "" {
+		pool, err := loadCertPool(t.CACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client, nil
+}
+
+// loadCertPool reads the PEM certificates of a CA file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// check returns a description of every assertion the response fails
+func (a *HTTPAssertions) check(resp *http.Response, body []byte, latency time.Duration) []string {
+	var failures []string
+
+	if a.MaxLatency > 0 && latency > a.MaxLatency {
+		failures = append(failures, fmt.Sprintf("latency %s exceeds %s", latency.Round(time.Millisecond), a.MaxLatency))
+	}
+
+	for _, name := range a.Headers {
+		if len(resp.Header.Values(name)) == 0 {
+			failures = append(failures, fmt.Sprintf("header %s is missing", name))
+		}
+	}
+
+	if a.BodyRegex != nil && !a.BodyRegex.Match(body) {
+		failures = append(failures, fmt.Sprintf("body does not match %s", a.BodyRegex))
+	}
+
+	if len(a.JSON) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return append(failures, fmt.Sprintf("body is not JSON: %v", err))
+		}
+		paths := make([]string, 0, len(a.JSON))
+		for path := range a.JSON {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			value := doc
+			for _, key := range a.jsonPaths[path] {
+				value = lookupValue(value, key)
+			}
+			if want := a.JSON[path]; !valuesEqual(value, want) {
+				failures = append(failures, fmt.Sprintf("%s is %s, expected %s", path, toString(value), toString(want)))
+			}
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,192 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPTaskRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		user, pass, _ := r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "42")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":        r.Method,
+			"content_type":  r.Header.Get("Content-Type"),
+			"authorization": r.Header.Get("Authorization"),
+			"user":          user + ":" + pass,
+			"body":          string(body),
+			"items":         []map[string]interface{}{{"id": 7, "name": "first"}},
+		})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		assert map[string]interface{}
+	}{
+		{
+			name:   "json",
+			config: map[string]interface{}{"method": "POST", "json": map[string]interface{}{"a": 1}, "bearer_token": "t0k"},
+			assert: map[string]interface{}{
+				"$.body":          `{"a":1}`,
+				"$.content_type":  "application/json",
+				"$.authorization": "Bearer t0k",
+				"$.items[0].id":   7,
+				"items.0['name']": "first",
+				"$.missing":       nil,
+			},
+		},
+		{
+			name: "form",
+			config: map[string]interface{}{
+				"method":     "PUT",
+				"form":       map[string]interface{}{"b": "x y", "a": []interface{}{1, 2}},
+				"basic_auth": map[string]interface{}{"username": "admin", "password": "pw"},
+			},
+			assert: map[string]interface{}{
+				"$.body":         "a=1&a=2&b=x+y",
+				"$.content_type": "application/x-www-form-urlencoded",
+				"$.user":         "admin:pw",
+			},
+		},
+		{
+			name:   "raw body",
+			config: map[string]interface{}{"method": "POST", "body": "hello", "headers": map[string]interface{}{"Content-Type": "text/plain"}},
+			assert: map[string]interface{}{"$.body": "hello", "$.content_type": "text/plain"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["url"] = server.URL
+			tt.config["assert"] = map[string]interface{}{
+				"json":        tt.assert,
+				"headers":     []interface{}{"X-Request-Id"},
+				"body_regex":  `"items":\[`,
+				"max_latency": "10s",
+			}
+			task := &HTTPTask{}
+			if err := task.Configure(tt.config); err != nil {
+				t.Fatalf("Configure failed: %v", err)
+			}
+			if _, err := task.Execute(context.Background()); err != nil {
+				t.Errorf("Execute failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestHTTPTaskAssertionFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`{"status": "degraded"}`))
+	}))
+	defer server.Close()
+
+	task := &HTTPTask{}
+	err := task.Configure(map[string]interface{}{
+		"url": server.URL,
+		"assert": map[string]interface{}{
+			"json":       map[string]interface{}{"$.status": "ok"},
+			"headers":    []interface{}{"X-Version"},
+			"body_regex": "healthy",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	result, err := task.Execute(context.Background())
+	for _, want := range []string{"$.status is degraded, expected ok", "header X-Version is missing", "body does not match healthy"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got %v", want, err)
+		}
+	}
+	if result == nil {
+		t.Errorf("Expected the response to be returned with failed assertions")
+	}
+
+	// Redirects are returned as is when not followed
+	task = &HTTPTask{}
+	err = task.Configure(map[string]interface{}{
+		"url":              server.URL + "/old",
+		"follow_redirects": false,
+		"expected_status":  []interface{}{302},
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if _, err := task.Execute(context.Background()); err != nil {
+		t.Errorf("Expected the redirect response, got %v", err)
+	}
+}
+
+func TestHTTPTaskTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caCert, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		"untrusted": {map[string]interface{}{}, true},
+		"ca_cert":   {map[string]interface{}{"ca_cert": caCert}, false},
+		"insecure":  {map[string]interface{}{"insecure_skip_verify": true}, false},
+	} {
+		tt.config["url"] = server.URL
+		task := &HTTPTask{}
+		if err := task.Configure(tt.config); err != nil {
+			t.Fatalf("%s: Configure failed: %v", name, err)
+		}
+		_, err := task.Execute(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestHTTPTaskConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"url": "http://x", "body": "a", "json": map[string]interface{}{}},
+		{"url": "http://x", "bearer_token": "t", "basic_auth": map[string]interface{}{"username": "u"}},
+		{"url": "http://x", "client_cert": "cert.pem"},
+		{"url": "http://x", "assert": map[string]interface{}{"json": map[string]interface{}{"$.a[*]": 1}}},
+		{"url": "http://x", "assert": map[string]interface{}{"body_regex": "("}},
+		{"url": "http://x", "assert": map[string]interface{}{"max_latency": "fast"}},
+		{"url": "http://x", "expected_status": []interface{}{"ok"}},
+		{"url": "http://x", "expected_status": []interface{}{2000}},
+		{"url": "http://x", "expected_status": []interface{}{200.5}},
+	} {
+		if err := (&HTTPTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+
+	// Numbers decoded from JSON are float64
+	task := &HTTPTask{}
+	if err := task.Configure(map[string]interface{}{"url": "http://x", "expected_status": []interface{}{float64(204)}}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if len(task.ExpectedStatus) != 1 || task.ExpectedStatus[0] != 204 {
+		t.Errorf("Expected status 204, got %v", task.ExpectedStatus)
+	}
+}
@@ -2,31 +2,90 @@ package probe
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/exec"
-	"runtime"
+	"sort"
+	"strings"
 	"time"
 )
 
-// PowerShellTask executes PowerShell scripts on Windows
+// powerShellExecutables are tried in order when no executable is configured:
+// PowerShell 7 runs on every platform, Windows PowerShell only on Windows
+var powerShellExecutables = []string{"pwsh", "powershell.exe"}
+
+// executionPolicies are the values accepted by -ExecutionPolicy
+var executionPolicies = []string{"AllSigned", "Bypass", "Default", "RemoteSigned", "Restricted", "Undefined", "Unrestricted"}
+
+// PowerShellTask executes PowerShell scripts with pwsh or Windows PowerShell
 type PowerShellTask struct {
-	Script  string
-	Timeout time.Duration
+	Script    string
+	Timeout   time.Duration
+	MaxOutput int
+
+	// File runs a script file instead of Script, with Parameters passed as
+	// -Name value arguments
+	File       string
+	Parameters map[string]interface{}
+
+	// Executable overrides the detected PowerShell executable
+	Executable      string
+	ExecutionPolicy string
+}
+
+// Describe documents the PowerShell task configuration
+func (t *PowerShellTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs a PowerShell script",
+		Fields: []FieldSpec{
+			{Name: "script", Type: FieldString, Description: "Script to run; either script or file is required"},
+			{Name: "file", Type: FieldString, Description: "Script file to run"},
+			{Name: "parameters", Type: FieldMap, Description: "Parameters passed to the script file"},
+			{Name: "executable", Type: FieldString, Description: "PowerShell executable; pwsh or powershell.exe is looked up by default"},
+			{Name: "execution_policy", Type: FieldString, Enum: executionPolicies, Description: "Execution policy of the session"},
+			{Name: "timeout", Type: FieldDuration, Default: "30s", Description: "Script timeout"},
+			{Name: "max_output", Type: FieldAny, Default: "1MB", Description: "Output kept per stream, in bytes or with a KB/MB/GB suffix; the middle of longer output is dropped"},
+		},
+	}
 }
 
 // Configure sets up the PowerShell task
 func (t *PowerShellTask) Configure(config map[string]interface{}) error {
-	// Check if running on Windows
-	if runtime.GOOS != "windows" {
-		return fmt.Errorf("PowerShell task only works on Windows")
+	// Either an inline script or a script file is required
+	t.Script, _ = config["script"].(string)
+	t.File, _ = config["file"].(string)
+	if t.Script == "" && t.File == "" {
+		return fmt.Errorf("script or file is required")
+	}
+	if t.Script != "" && t.File != "" {
+		return fmt.Errorf("only one of script or file can be set")
+	}
+	
+	// Parameters (optional, file only)
+	if params, ok := config["parameters"]; ok {
+		values, ok := params.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("parameters must be a map")
+		}
+		if t.File == "" {
+			return fmt.Errorf("parameters require file")
+		}
+		for name := range values {
+			if name == "" || strings.ContainsAny(name, " -:") {
+				return fmt.Errorf("invalid parameter name %q", name)
+			}
+		}
+		t.Parameters = values
 	}
 	
-	// Script is required
-	script, ok := config["script"].(string)
-	if !ok || script == "" {
-		return fmt.Errorf("script is required")
+	// Executable and execution policy (optional)
+	t.Executable, _ = config["executable"].(string)
+	if policy, ok := config["execution_policy"].(string); ok {
+		if !containsString(executionPolicies, policy) {
+			return fmt.Errorf("invalid execution_policy %q: must be one of %s", policy, strings.Join(executionPolicies, ", "))
+		}
+		t.ExecutionPolicy = policy
 	}
-	t.Script = script
 	
 	// Timeout (default: 30s)
 	if timeoutStr, ok := config["timeout"].(string); ok {
@@ -39,32 +98,41 @@ func (t *PowerShellTask) Configure(config map[string]interface{}) error {
 		t.Timeout = 30 * time.Second
 	}
 	
+	// Output limit per stream (default: 1MB)
+	maxOutput, err := parseMaxOutput(config)
+	if err != nil {
+		return err
+	}
+	t.MaxOutput = maxOutput
+	
 	return nil
 }
 
 // Execute runs the PowerShell script
 func (t *PowerShellTask) Execute(ctx context.Context) (interface{}, error) {
+	executable, err := t.executable()
+	if err != nil {
+		return nil, err
+	}
+	
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
 	defer cancel()
 	
 	// Execute PowerShell script
-	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", t.Script)
-	
-	output, err := cmd.CombinedOutput()
-	exitCode := 0
+	cmd := exec.CommandContext(ctx, executable, t.args()...)
 	
+	result, exitCode, err := runProcess(ctx, cmd, t.MaxOutput)
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		} else {
-			return nil, fmt.Errorf("PowerShell execution failed: %w", err)
-		}
+		return nil, fmt.Errorf("PowerShell execution failed: %w", err)
 	}
 	
-	result := map[string]interface{}{
-		"output":    string(output),
-		"exit_code": exitCode,
+	// Output written with ConvertTo-Json is returned as structured data
+	if stdout := strings.TrimSpace(toString(result["stdout"])); result["truncated"] != true && (strings.HasPrefix(stdout, "{") || strings.HasPrefix(stdout, "[")) {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(stdout), &doc); err == nil {
+			result["json"] = doc
+		}
 	}
 	
 	if exitCode != 0 {
@@ -73,3 +141,49 @@ func (t *PowerShellTask) Execute(ctx context.Context) (interface{}, error) {
 	
 	return result, nil
 }
+
+// executable returns the configured PowerShell executable, or the first one
+// found on the PATH
+func (t *PowerShellTask) executable() (string, error) {
+	if t.Executable != "" {
+		return t.Executable, nil
+	}
+	for _, name := range powerShellExecutables {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("PowerShell not found: install pwsh or set executable")
+}
+
+// args returns the PowerShell command line arguments
+func (t *PowerShellTask) args() []string {
+	args := []string{"-NoProfile", "-NonInteractive"}
+	if t.ExecutionPolicy != "" {
+		args = append(args, "-ExecutionPolicy", t.ExecutionPolicy)
+	}
+	if t.File == "" {
+		return append(args, "-Command", t.Script)
+	}
+
+	args = append(args, "-File", t.File)
+	names := make([]string, 0, len(t.Parameters))
+	for name := range t.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Switches are passed as -Name or -Name:$false
+		switch v := t.Parameters[name].(type) {
+		case bool:
+			if v {
+				args = append(args, "-"+name)
+			} else {
+				args = append(args, "-"+name+":$false")
+			}
+		default:
+			args = append(args, "-"+name, toString(v))
+		}
+	}
+	return args
+}
@@ -2,14 +2,39 @@ package probe
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
-func TestPowerShellTask(t *testing.T) {
-	if runtime.GOOS != "windows" {
-		t.Skip("PowerShell task only works on Windows")
+// requirePowerShell skips the test when neither pwsh nor powershell.exe is
+// installed
+func requirePowerShell(t *testing.T) {
+	t.Helper()
+	if _, err := (&PowerShellTask{}).executable(); err != nil {
+		t.Skip("PowerShell is not installed")
+	}
+}
+
+// fakePowerShell writes a shell script standing in for PowerShell, which
+// prints its arguments one per line
+func fakePowerShell(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses /bin/sh")
 	}
+	path := filepath.Join(t.TempDir(), "pwsh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPowerShellTask(t *testing.T) {
+	requirePowerShell(t)
 	
 	task := &PowerShellTask{}
 	
@@ -35,28 +60,65 @@ func TestPowerShellTask(t *testing.T) {
 	}
 }
 
-func TestPowerShellTaskNonWindows(t *testing.T) {
-	if runtime.GOOS == "windows" {
-		t.Skip("This test is for non-Windows platforms")
+func TestPowerShellTaskArguments(t *testing.T) {
+	task := &PowerShellTask{}
+	err := task.Configure(map[string]interface{}{
+		"file":             "deploy.ps1",
+		"parameters":       map[string]interface{}{"Version": "1.2.0", "Force": true, "DryRun": false, "Port": 8080},
+		"execution_policy": "Bypass",
+		"executable":       fakePowerShell(t, `for arg in "$@"; do echo "$arg"; done`),
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	
+	result, err := task.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
 	}
 	
+	args := strings.Split(strings.TrimSpace(result.(map[string]interface{})["stdout"].(string)), "\n")
+	want := []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", "deploy.ps1", "-DryRun:$false", "-Force", "-Port", "8080", "-Version", "1.2.0"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected arguments %v, got %v", want, args)
+	}
+}
+
+func TestPowerShellTaskJSONOutput(t *testing.T) {
 	task := &PowerShellTask{}
+	err := task.Configure(map[string]interface{}{
+		"script":     "Get-Service | ConvertTo-Json",
+		"executable": fakePowerShell(t, `echo '[{"Name": "sshd", "Status": 4}]'`),
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
 	
-	config := map[string]interface{}{
-		"script": "Write-Output 'test'",
+	result, err := task.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
 	}
 	
-	err := task.Configure(config)
-	if err == nil {
-		t.Errorf("Expected error on non-Windows platform")
+	want := []interface{}{map[string]interface{}{"Name": "sshd", "Status": float64(4)}}
+	if doc := result.(map[string]interface{})["json"]; !reflect.DeepEqual(doc, want) {
+		t.Errorf("Expected JSON output %v, got %v", want, doc)
 	}
 }
 
-func TestPowerShellTaskMissingScript(t *testing.T) {
-	if runtime.GOOS != "windows" {
-		t.Skip("PowerShell task only works on Windows")
+func TestPowerShellTaskConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"script": "a", "file": "b.ps1"},
+		{"script": "a", "parameters": map[string]interface{}{"Name": "x"}},
+		{"file": "b.ps1", "parameters": map[string]interface{}{"-Name": "x"}},
+		{"script": "a", "execution_policy": "Lenient"},
+	} {
+		if err := (&PowerShellTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
 	}
-	
+}
+
+func TestPowerShellTaskMissingScript(t *testing.T) {
 	task := &PowerShellTask{}
 	
 	config := map[string]interface{}{}
@@ -68,9 +130,7 @@ func TestPowerShellTaskMissingScript(t *testing.T) {
 }
 
 func TestPowerShellTaskExitCode(t *testing.T) {
-	if runtime.GOOS != "windows" {
-		t.Skip("PowerShell task only works on Windows")
-	}
+	requirePowerShell(t)
 	
 	task := &PowerShellTask{}
 	
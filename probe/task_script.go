@@ -0,0 +1,328 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// defaultScriptMaxSteps bounds the computation of a script, stopping runaway loops
+const defaultScriptMaxSteps = 10_000_000
+
+// scriptOptions are the Starlark dialect of scripts: top-level statements and
+// while loops are allowed to keep small transforms short
+var scriptOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// scriptGlobals are the names predeclared for scripts besides the Starlark builtins
+var scriptGlobals = []string{"vars", "tasks", "agent", "item", "index", "inputs", "json", "math"}
+
+// scopedTask is implemented by tasks that read the template scope of the
+// running task directly rather than through their rendered config
+type scopedTask interface {
+	setScope(s *scope)
+}
+
+// ScriptTask runs a Starlark script for in-workflow logic. The script sees
+// the workflow vars, the finished tasks and its inputs, and returns a value by
+// assigning it to the global result. Scripts have no filesystem, network or
+// environment access and cannot load other files.
+type ScriptTask struct {
+	Script   string
+	Inputs   map[string]interface{}
+	Timeout  time.Duration
+	MaxSteps uint64
+
+	program *starlark.Program
+	scope   *scope
+}
+
+// Describe documents the script task configuration
+func (t *ScriptTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs a sandboxed Starlark script",
+		Fields: []FieldSpec{
+			{Name: "script", Type: FieldString, Required: true, Description: "Starlark source; the value assigned to result becomes the task output"},
+			{Name: "inputs", Type: FieldMap, Description: "Values available to the script as inputs"},
+			{Name: "timeout", Type: FieldDuration, Default: "10s", Description: "Script timeout"},
+			{Name: "max_steps", Type: FieldInt, Default: defaultScriptMaxSteps, Description: "Maximum number of computation steps"},
+		},
+	}
+}
+
+// Configure compiles the script
+func (t *ScriptTask) Configure(config map[string]interface{}) error {
+	script, ok := config["script"].(string)
+	if !ok || script == "" {
+		return fmt.Errorf("script is required")
+	}
+	t.Script = script
+
+	// Inputs (optional)
+	if inputs, ok := config["inputs"]; ok {
+		m, ok := inputs.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("inputs must be a map")
+		}
+		t.Inputs = m
+	}
+
+	// Timeout (default: 10s)
+	t.Timeout = 10 * time.Second
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	// Step limit (default: 10 million)
+	t.MaxSteps = defaultScriptMaxSteps
+	if v, ok := config["max_steps"]; ok {
+		steps, ok := toInt(v)
+		if !ok || steps <= 0 {
+			return fmt.Errorf("max_steps must be a positive integer")
+		}
+		t.MaxSteps = uint64(steps)
+	}
+
+	isPredeclared := func(name string) bool {
+		return containsString(scriptGlobals, name)
+	}
+	_, program, err := starlark.SourceProgramOptions(scriptOptions, "script", script, isPredeclared)
+	if err != nil {
+		return fmt.Errorf("invalid script: %w", err)
+	}
+	t.program = program
+
+	return nil
+}
+
+// setScope receives the values visible to the running task
+func (t *ScriptTask) setScope(s *scope) {
+	t.scope = s
+}
+
+// Execute runs the script and returns its result
+func (t *ScriptTask) Execute(ctx context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	predeclared := starlark.StringDict{
+		"json": json.Module,
+		"math": starlarkmath.Module,
+	}
+	values := map[string]interface{}{}
+	if t.scope != nil {
+		values = t.scope.values
+	}
+	for _, name := range []string{"vars", "tasks", "agent", "item", "index"} {
+		v, err := toStarlark(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		predeclared[name] = v
+	}
+	inputs, err := toStarlark(t.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("inputs: %w", err)
+	}
+	predeclared["inputs"] = inputs
+	predeclared.Freeze()
+
+	stdout := OutputWriter(ctx, "stdout")
+	thread := &starlark.Thread{
+		Name: "script",
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(stdout, msg)
+		},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not available in scripts")
+		},
+	}
+	thread.SetMaxExecutionSteps(t.MaxSteps)
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel(context.Cause(ctx).Error())
+	})
+	defer stop()
+
+	globals, err := t.program.Init(thread, predeclared)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("script interrupted: %w", ctx.Err())
+		}
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return nil, errors.New(evalErr.Backtrace())
+		}
+		return nil, err
+	}
+
+	result, ok := globals["result"]
+	if !ok {
+		return nil, nil
+	}
+	output, err := fromStarlark(result)
+	if err != nil {
+		return nil, fmt.Errorf("result: %w", err)
+	}
+	return output, nil
+}
+
+// toStarlark converts a Go value from the template scope to a Starlark value
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(x), nil
+	case string:
+		return starlark.String(x), nil
+	case []byte:
+		return starlark.String(x), nil
+	case int:
+		return starlark.MakeInt(x), nil
+	case int64:
+		return starlark.MakeInt64(x), nil
+	case uint64:
+		return starlark.MakeUint64(x), nil
+	case float64:
+		return starlark.Float(x), nil
+	case time.Duration:
+		return starlark.String(x.String()), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(x))
+		for i, item := range x {
+			elem, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case []map[string]interface{}:
+		elems := make([]starlark.Value, len(x))
+		for i, item := range x {
+			elem, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case []string:
+		elems := make([]starlark.Value, len(x))
+		for i, item := range x {
+			elems[i] = starlark.String(item)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(x))
+		for _, k := range keys {
+			value, err := toStarlark(x[k])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			dict.SetKey(starlark.String(k), value)
+		}
+		return dict, nil
+	case map[string]string:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			m[k] = item
+		}
+		return toStarlark(m)
+	case map[string][]string:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			m[k] = item
+		}
+		return toStarlark(m)
+	}
+	if i, ok := toInt(v); ok {
+		return starlark.MakeInt(i), nil
+	}
+	if f, ok := toFloat(v); ok {
+		return starlark.Float(f), nil
+	}
+	// Other values, such as HTTP headers, are passed as their string form
+	return starlark.String(toString(v)), nil
+}
+
+// fromStarlark converts a Starlark value to the plain Go values used for task output
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch x := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(x), nil
+	case starlark.String:
+		return string(x), nil
+	case starlark.Bytes:
+		return string(x), nil
+	case starlark.Int:
+		if i, ok := x.Int64(); ok && i >= math.MinInt && i <= math.MaxInt {
+			return int(i), nil
+		}
+		return x.String(), nil
+	case starlark.Float:
+		return float64(x), nil
+	case starlark.Indexable:
+		// Lists and tuples
+		out := make([]interface{}, x.Len())
+		for i := range out {
+			item, err := fromStarlark(x.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case *starlark.Dict:
+		out := make(map[string]interface{}, x.Len())
+		for _, item := range x.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			value, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[string(key)] = value
+		}
+		return out, nil
+	case *starlark.Set:
+		out := make([]interface{}, 0, x.Len())
+		iter := x.Iterate()
+		defer iter.Done()
+		var item starlark.Value
+		for iter.Next(&item) {
+			value, err := fromStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot return a %s", v.Type())
+}
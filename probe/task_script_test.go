@@ -0,0 +1,103 @@
+package probe
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptTask(t *testing.T) {
+	p := New()
+	p.RegisterTask("value", func() Task { return &valueTask{} })
+	events := &eventLog{}
+	p.RegisterObserver(events)
+
+	yaml := `
+name: test-script
+vars:
+  hosts: [web-1, db-1, web-2]
+tasks:
+  - name: inventory
+    type: value
+    config:
+      value: {zone: eu, ports: [80, 443]}
+  - name: transform
+    type: script
+    depends_on: [inventory]
+    config:
+      inputs: {prefix: web}
+      script: |
+        def sum_ports(ports):
+            total = 0
+            for port in ports:
+                total += port
+            return total
+
+        web = [h for h in vars["hosts"] if h.startswith(inputs["prefix"])]
+        print("found", len(web))
+        zone = tasks["inventory"]["output"]["zone"]
+        result = {
+            "hosts": [h + "." + zone for h in web],
+            "total": sum_ports(tasks["inventory"]["output"]["ports"]),
+            "encoded": json.encode({"n": len(web)}),
+        }
+  - name: check
+    type: value
+    depends_on: [transform]
+    config:
+      value: "{{ tasks.transform.output.hosts.1 }}"
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"hosts":   []interface{}{"web-1.eu", "web-2.eu"},
+		"total":   523,
+		"encoded": `{"n":2}`,
+	}
+	if got := result.Tasks[1].Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if result.Tasks[2].Output != "web-2.eu" {
+		t.Errorf("Expected script output in templates, got %v", result.Tasks[2].Output)
+	}
+	if !containsString(events.events, "output transform stdout: found 2") {
+		t.Errorf("Expected print to be streamed, got %v", events.events)
+	}
+}
+
+func TestScriptTaskErrors(t *testing.T) {
+	configureErrors := map[string]string{
+		"result = (":                   "invalid script",
+		"result = open('/etc/passwd')": "undefined: open",
+	}
+	for script, want := range configureErrors {
+		err := (&ScriptTask{}).Configure(map[string]interface{}{"script": script})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Configure(%q): expected %q, got %v", script, want, err)
+		}
+	}
+
+	runErrors := map[string]string{
+		"load('x.star', 'y')":         "load is not available",
+		"fail('bad input')":           "bad input",
+		"while True:\n    pass":       "too many steps",
+		"result = {1: 'integer key'}": "dict keys must be strings",
+		"result = lambda: 1":          "cannot return a function",
+	}
+	for script, want := range runErrors {
+		task := &ScriptTask{}
+		config := map[string]interface{}{"script": script, "max_steps": 10000}
+		if err := task.Configure(config); err != nil {
+			t.Fatalf("Configure(%q): %v", script, err)
+		}
+		_, err := task.Execute(context.Background())
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Execute(%q): expected %q, got %v", script, want, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// serviceActions are the actions of the service task
+var serviceActions = []string{"start", "stop", "restart", "enable", "disable", "status"}
+
+// servicePollInterval is how often the state of a service is checked while
+// waiting for it
+var servicePollInterval = 500 * time.Millisecond
+
+// serviceStatus is the state of a service: running, stopped, starting,
+// stopping, failed or paused, and whether it starts at boot. ID identifies the
+// current run of the service, such as its systemd invocation or process id,
+// when the service manager reports one.
+type serviceStatus struct {
+	State   string
+	Enabled bool
+	ID      string
+}
+
+func (s serviceStatus) result() map[string]interface{} {
+	return map[string]interface{}{
+		"state":   s.State,
+		"enabled": s.Enabled,
+	}
+}
+
+// serviceManager controls the services of the host: systemd units on Linux,
+// and services of the service control manager on Windows. Start, stop and
+// restart may return before the service reaches its new state.
+type serviceManager interface {
+	status(ctx context.Context, name string) (serviceStatus, error)
+	start(ctx context.Context, name string) error
+	stop(ctx context.Context, name string) error
+	restart(ctx context.Context, name string) error
+	setEnabled(ctx context.Context, name string, enabled bool) error
+}
+
+// ServiceTask starts, stops, restarts, enables or disables a service, or
+// reports its status. Actions that find the service already in the requested
+// state change nothing; otherwise the task waits for the service to get there.
+type ServiceTask struct {
+	Name    string
+	Action  string
+	Timeout time.Duration
+
+	manager serviceManager
+}
+
+// Describe documents the service task configuration
+func (t *ServiceTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Manages a systemd or Windows service",
+		Fields: []FieldSpec{
+			{Name: "name", Type: FieldString, Required: true, Description: "Service name, such as nginx or W3SVC"},
+			{Name: "action", Type: FieldString, Required: true, Enum: serviceActions, Description: "What to do with the service"},
+			{Name: "timeout", Type: FieldDuration, Default: "60s", Description: "How long to wait for the service to reach the requested state"},
+		},
+		Platforms: []string{"linux", "windows"},
+	}
+}
+
+// Configure sets up the service task
+func (t *ServiceTask) Configure(config map[string]interface{}) error {
+	name, ok := config["name"].(string)
+	if !ok || name == "" {
+		return fmt.Errorf("name is required")
+	}
+	t.Name = name
+
+	action, ok := config["action"].(string)
+	if !ok || !containsString(serviceActions, action) {
+		return fmt.Errorf("action must be one of %s", strings.Join(serviceActions, ", "))
+	}
+	t.Action = action
+
+	// Timeout (default: 60s)
+	t.Timeout = 60 * time.Second
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	if t.manager == nil {
+		manager, err := newServiceManager()
+		if err != nil {
+			return err
+		}
+		t.manager = manager
+	}
+
+	return nil
+}
+
+// Execute performs the action and reports the previous and current status
+func (t *ServiceTask) Execute(ctx context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	previous, err := t.manager.status(ctx, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]interface{}{
+		"name":     t.Name,
+		"action":   t.Action,
+		"previous": previous.result(),
+	}
+
+	changed := false
+	switch t.Action {
+	case "start":
+		if previous.State != "running" {
+			err = t.manager.start(ctx, t.Name)
+			changed = true
+		}
+	case "stop":
+		if previous.State != "stopped" {
+			err = t.manager.stop(ctx, t.Name)
+			changed = true
+		}
+	case "restart":
+		err = t.manager.restart(ctx, t.Name)
+		changed = true
+	case "enable", "disable":
+		enabled := t.Action == "enable"
+		if previous.Enabled != enabled {
+			err = t.manager.setEnabled(ctx, t.Name, enabled)
+			changed = true
+		}
+	}
+	if err != nil {
+		return output, fmt.Errorf("failed to %s %s: %w", t.Action, t.Name, err)
+	}
+
+	current := previous
+	if changed {
+		current, err = t.wait(ctx, previous)
+	}
+	output["current"] = current.result()
+	output["changed"] = changed
+	return output, err
+}
+
+// wait polls the service until it reaches the state of the action. After a
+// start or restart, a state read while the service still has its previous ID
+// may predate the request: a restart is only done once the ID changed, and a
+// failure only counts once the service ran again.
+func (t *ServiceTask) wait(ctx context.Context, previous serviceStatus) (serviceStatus, error) {
+	want := map[string]string{"start": "running", "restart": "running", "stop": "stopped"}[t.Action]
+	for {
+		status, err := t.manager.status(ctx, t.Name)
+		if err != nil {
+			return status, err
+		}
+		stale := t.Action != "stop" && status.ID != "" && status.ID == previous.ID
+		if want == "" || (status.State == want && !(t.Action == "restart" && stale)) {
+			return status, nil
+		}
+		if status.State == "failed" && !stale {
+			return status, fmt.Errorf("service %s failed", t.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("service %s did not reach %s within %s, it is %s", t.Name, want, t.Timeout, status.State)
+		case <-time.After(servicePollInterval):
+		}
+	}
+}
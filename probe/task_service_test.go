@@ -0,0 +1,199 @@
+package probe
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServiceManager records the calls made to it. Started services report
+// starting once before they are running; failing services fail to start.
+type fakeServiceManager struct {
+	mu      sync.Mutex
+	state   string
+	enabled bool
+	failing bool
+	calls   []string
+}
+
+func (f *fakeServiceManager) status(ctx context.Context, name string) (serviceStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := serviceStatus{State: f.state, Enabled: f.enabled}
+	switch {
+	case f.state == "starting" && f.failing:
+		f.state = "failed"
+	case f.state == "starting":
+		f.state = "running"
+	}
+	return status, nil
+}
+
+func (f *fakeServiceManager) record(call, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+	if state != "" {
+		f.state = state
+	}
+	return nil
+}
+
+func (f *fakeServiceManager) start(ctx context.Context, name string) error {
+	return f.record("start", "starting")
+}
+
+func (f *fakeServiceManager) stop(ctx context.Context, name string) error {
+	return f.record("stop", "stopped")
+}
+
+func (f *fakeServiceManager) restart(ctx context.Context, name string) error {
+	return f.record("restart", "starting")
+}
+
+func (f *fakeServiceManager) setEnabled(ctx context.Context, name string, enabled bool) error {
+	f.enabled = enabled
+	return f.record("enable="+map[bool]string{true: "true", false: "false"}[enabled], "")
+}
+
+func TestServiceTask(t *testing.T) {
+	servicePollInterval = time.Millisecond
+	defer func() { servicePollInterval = 500 * time.Millisecond }()
+
+	manager := &fakeServiceManager{state: "stopped"}
+	steps := []struct {
+		action   string
+		changed  bool
+		previous string
+		current  string
+	}{
+		{"start", true, "stopped", "running"},
+		{"start", false, "running", "running"},
+		{"enable", true, "running", "running"},
+		{"enable", false, "running", "running"},
+		{"restart", true, "running", "running"},
+		{"stop", true, "running", "stopped"},
+		{"status", false, "stopped", "stopped"},
+	}
+	for _, step := range steps {
+		task := &ServiceTask{manager: manager}
+		if err := task.Configure(map[string]interface{}{"name": "nginx", "action": step.action}); err != nil {
+			t.Fatalf("Configure failed: %v", err)
+		}
+		result, err := task.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("%s failed: %v", step.action, err)
+		}
+		output := result.(map[string]interface{})
+		previous := output["previous"].(map[string]interface{})["state"]
+		current := output["current"].(map[string]interface{})["state"]
+		if output["changed"] != step.changed || previous != step.previous || current != step.current {
+			t.Errorf("%s: expected changed %v from %s to %s, got %v", step.action, step.changed, step.previous, step.current, output)
+		}
+	}
+	if calls := strings.Join(manager.calls, ","); calls != "start,enable=true,restart,stop" {
+		t.Errorf("Unexpected service calls: %s", calls)
+	}
+}
+
+func TestServiceTaskFailure(t *testing.T) {
+	servicePollInterval = time.Millisecond
+	defer func() { servicePollInterval = 500 * time.Millisecond }()
+
+	task := &ServiceTask{manager: &fakeServiceManager{state: "stopped", failing: true}}
+	task.Configure(map[string]interface{}{"name": "nginx", "action": "start"})
+	if _, err := task.Execute(context.Background()); err == nil || err.Error() != "service nginx failed" {
+		t.Errorf("Expected start failure, got %v", err)
+	}
+
+	// A service stuck stopping times out
+	task = &ServiceTask{manager: &stuckServiceManager{fakeServiceManager{state: "running"}}}
+	task.Configure(map[string]interface{}{"name": "nginx", "action": "stop", "timeout": "50ms"})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "did not reach stopped within 50ms") {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+
+	if err := (&ServiceTask{manager: &fakeServiceManager{}}).Configure(map[string]interface{}{"name": "nginx", "action": "reload"}); err == nil {
+		t.Errorf("Expected error for unknown action")
+	}
+}
+
+// stuckServiceManager never finishes stopping
+type stuckServiceManager struct {
+	fakeServiceManager
+}
+
+func (s *stuckServiceManager) stop(ctx context.Context, name string) error {
+	return s.record("stop", "stopping")
+}
+
+// asyncServiceManager carries out start and restart after they return, like
+// a service manager queueing jobs: until then, status still reports the
+// state and ID of the previous run
+type asyncServiceManager struct {
+	fakeServiceManager
+	id   int
+	done chan struct{}
+}
+
+func (a *asyncServiceManager) status(ctx context.Context, name string) (serviceStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return serviceStatus{State: a.state, ID: strconv.Itoa(a.id)}, nil
+}
+
+func (a *asyncServiceManager) run(call string) error {
+	a.record(call, "")
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		time.Sleep(20 * time.Millisecond)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.id++
+		a.state = "running"
+	}()
+	return nil
+}
+
+func (a *asyncServiceManager) start(ctx context.Context, name string) error {
+	return a.run("start")
+}
+
+func (a *asyncServiceManager) restart(ctx context.Context, name string) error {
+	return a.run("restart")
+}
+
+func TestServiceTaskAsyncManager(t *testing.T) {
+	servicePollInterval = time.Millisecond
+	defer func() { servicePollInterval = 500 * time.Millisecond }()
+
+	for _, tt := range []struct {
+		action string
+		state  string
+	}{
+		// A failed service reads failed until the start job runs
+		{"start", "failed"},
+		// A running service reads running until the restart job runs
+		{"restart", "running"},
+	} {
+		manager := &asyncServiceManager{fakeServiceManager: fakeServiceManager{state: tt.state}, id: 1}
+		task := &ServiceTask{manager: manager}
+		task.Configure(map[string]interface{}{"name": "nginx", "action": tt.action})
+		result, err := task.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("%s from %s failed: %v", tt.action, tt.state, err)
+		}
+		select {
+		case <-manager.done:
+		default:
+			t.Errorf("%s from %s returned before the job ran", tt.action, tt.state)
+		}
+		if current := result.(map[string]interface{})["current"].(map[string]interface{})["state"]; current != "running" {
+			t.Errorf("%s from %s: expected running, got %v", tt.action, tt.state, current)
+		}
+	}
+}
@@ -2,14 +2,24 @@ package probe
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+// defaultSSHMaxParallel bounds the hosts a task runs on at the same time
+const defaultSSHMaxParallel = 10
+
 // SSHTask performs SSH operations
 type SSHTask struct {
 	Host     string
@@ -18,25 +28,76 @@ type SSHTask struct {
 	Key      string
 	Password string
 	Command  string
-	Upload   *SSHUpload
 	Timeout  time.Duration
+
+	// Upload is copied to the host before the command runs, and Download
+	// from it afterwards
+	Upload   *SSHTransfer
+	Download *SSHTransfer
+
+	// MaxOutput limits the command output kept per stream
+	MaxOutput int
+
+	// Hosts runs the task on several hosts, given as [user@]host[:port],
+	// instead of Host, up to MaxParallel at a time
+	Hosts       []string
+	MaxParallel int
+
+	// Passphrase decrypts Key; UseAgent authenticates through the ssh-agent
+	// listening on SSH_AUTH_SOCK
+	Passphrase string
+	UseAgent   bool
+
+	// Host keys are verified against the KnownHosts files (default:
+	// ~/.ssh/known_hosts) unless HostKeyFingerprints pins them, or
+	// InsecureIgnoreHostKey disables verification
+	KnownHosts            []string
+	HostKeyFingerprints   []string
+	InsecureIgnoreHostKey bool
+
+	// Jump lists bastion hosts, as [user@]host[:port], connected through in order
+	Jump []string
+
+	targets []sshTarget
+	jumps   []sshTarget
 }
 
-// SSHUpload represents a file upload configuration
-type SSHUpload struct {
-	Local  string
-	Remote string
+// sshTarget is a host to connect to
+type sshTarget struct {
+	name string
+	user string
+	addr string
+}
+
+// Describe documents the SSH task configuration
+func (t *SSHTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs a command or transfers files over SSH",
+		Fields: []FieldSpec{
+			{Name: "host", Type: FieldString, Description: "Remote host; required unless hosts is given"},
+			{Name: "hosts", Type: FieldList, Items: FieldString, Description: "Hosts to run on in parallel, as [user@]host[:port]"},
+			{Name: "max_parallel", Type: FieldInt, Default: defaultSSHMaxParallel, Description: "Maximum number of hosts run on at the same time"},
+			{Name: "port", Type: FieldInt, Default: 22, Description: "SSH port"},
+			{Name: "user", Type: FieldString, Description: "Remote user, unless given as user@host"},
+			{Name: "key", Type: FieldString, Description: "Path to a private key"},
+			{Name: "passphrase", Type: FieldString, Description: "Passphrase of an encrypted private key"},
+			{Name: "password", Type: FieldString, Description: "Password authentication"},
+			{Name: "ssh_agent", Type: FieldBool, Default: false, Description: "Authenticate with the keys of the ssh-agent at SSH_AUTH_SOCK"},
+			{Name: "known_hosts", Type: FieldAny, Default: "~/.ssh/known_hosts", Description: "known_hosts file, or list of files, verifying host keys"},
+			{Name: "host_key_fingerprint", Type: FieldAny, Description: "SHA256 fingerprint, or list of fingerprints, the host keys must have"},
+			{Name: "insecure_ignore_host_key", Type: FieldBool, Default: false, Description: "Skip host key verification"},
+			{Name: "jump", Type: FieldList, Items: FieldString, Description: "Bastion hosts connected through in order, as [user@]host[:port]"},
+			{Name: "command", Type: FieldString, Description: "Command to run"},
+			{Name: "upload", Type: FieldMap, Description: "File or directory uploaded over SFTP before running the command", Fields: transferFields()},
+			{Name: "download", Type: FieldMap, Description: "File or directory downloaded over SFTP after running the command", Fields: transferFields()},
+			{Name: "timeout", Type: FieldDuration, Default: "60s", Description: "Connection timeout"},
+			{Name: "max_output", Type: FieldAny, Default: "1MB", Description: "Output kept per stream, in bytes or with a KB/MB/GB suffix; the middle of longer output is dropped"},
+		},
+	}
 }
 
 // Configure sets up the SSH task
 func (t *SSHTask) Configure(config map[string]interface{}) error {
-	// Host is required
-	host, ok := config["host"].(string)
-	if !ok || host == "" {
-		return fmt.Errorf("host is required")
-	}
-	t.Host = host
-	
 	// Port (default: 22)
 	if port, ok := config["port"].(int); ok {
 		t.Port = port
@@ -44,23 +105,110 @@ func (t *SSHTask) Configure(config map[string]interface{}) error {
 		t.Port = 22
 	}
 	
-	// User is required
-	user, ok := config["user"].(string)
-	if !ok || user == "" {
-		return fmt.Errorf("user is required")
+	// User (optional when given with each host)
+	if user, ok := config["user"].(string); ok {
+		t.User = user
+	}
+	
+	// Either a host or a list of hosts is required
+	if hosts, ok := config["hosts"]; ok {
+		if _, ok := config["host"]; ok {
+			return fmt.Errorf("host and hosts are mutually exclusive")
+		}
+		list, err := parseStringList(hosts)
+		if err != nil || len(list) == 0 {
+			return fmt.Errorf("hosts must be a non-empty list of hosts")
+		}
+		t.Hosts = list
+		for _, host := range list {
+			target, err := parseSSHTarget(host, t.User, t.Port)
+			if err != nil {
+				return fmt.Errorf("invalid hosts: %w", err)
+			}
+			t.targets = append(t.targets, target)
+		}
+	} else {
+		host, ok := config["host"].(string)
+		if !ok || host == "" {
+			return fmt.Errorf("host is required")
+		}
+		if t.User == "" {
+			return fmt.Errorf("user is required")
+		}
+		t.Host = host
+		t.targets = []sshTarget{{name: host, user: t.User, addr: net.JoinHostPort(host, strconv.Itoa(t.Port))}}
+	}
+	
+	// Parallelism across hosts (default: 10)
+	t.MaxParallel = defaultSSHMaxParallel
+	if v, ok := config["max_parallel"]; ok {
+		n, ok := toInt(v)
+		if !ok || n < 1 {
+			return fmt.Errorf("max_parallel must be a positive integer")
+		}
+		t.MaxParallel = n
 	}
-	t.User = user
 	
-	// Authentication: key or password
+	// Jump hosts (optional)
+	if jump, ok := config["jump"]; ok {
+		list, err := parseStringList(jump)
+		if err != nil {
+			return fmt.Errorf("invalid jump: %w", err)
+		}
+		t.Jump = list
+		for _, host := range list {
+			target, err := parseSSHTarget(host, t.User, 22)
+			if err != nil {
+				return fmt.Errorf("invalid jump: %w", err)
+			}
+			t.jumps = append(t.jumps, target)
+		}
+	}
+	
+	// Authentication: key, ssh-agent or password
 	if key, ok := config["key"].(string); ok {
 		t.Key = key
 	}
+	if passphrase, ok := config["passphrase"].(string); ok {
+		t.Passphrase = passphrase
+	}
+	if useAgent, ok := config["ssh_agent"].(bool); ok {
+		t.UseAgent = useAgent
+	}
 	if password, ok := config["password"].(string); ok {
 		t.Password = password
 	}
 	
-	if t.Key == "" && t.Password == "" {
-		return fmt.Errorf("either key or password is required")
+	if t.Key == "" && t.Password == "" && !t.UseAgent {
+		return fmt.Errorf("one of key, password or ssh_agent is required")
+	}
+	
+	// Host key verification (default: ~/.ssh/known_hosts)
+	if v, ok := config["known_hosts"]; ok {
+		files, err := parseStringList(v)
+		if err != nil {
+			return fmt.Errorf("invalid known_hosts: %w", err)
+		}
+		t.KnownHosts = files
+	}
+	if v, ok := config["host_key_fingerprint"]; ok {
+		fingerprints, err := parseStringList(v)
+		if err != nil {
+			return fmt.Errorf("invalid host_key_fingerprint: %w", err)
+		}
+		t.HostKeyFingerprints = fingerprints
+	}
+	if insecure, ok := config["insecure_ignore_host_key"].(bool); ok {
+		t.InsecureIgnoreHostKey = insecure
+	}
+	verifiers := 0
+	for _, set := range []bool{len(t.KnownHosts) > 0, len(t.HostKeyFingerprints) > 0, t.InsecureIgnoreHostKey} {
+		if set {
+			verifiers++
+		}
+	}
+	if verifiers > 1 {
+		return fmt.Errorf("known_hosts, host_key_fingerprint and insecure_ignore_host_key are mutually exclusive")
 	}
 	
 	// Command (optional)
@@ -68,16 +216,20 @@ func (t *SSHTask) Configure(config map[string]interface{}) error {
 		t.Command = command
 	}
 	
-	// Upload (optional)
-	if upload, ok := config["upload"].(map[string]interface{}); ok {
-		local, _ := upload["local"].(string)
-		remote, _ := upload["remote"].(string)
-		if local != "" && remote != "" {
-			t.Upload = &SSHUpload{
-				Local:  local,
-				Remote: remote,
-			}
+	// Upload and download (optional)
+	if upload, ok := config["upload"]; ok {
+		transfer, err := parseSSHTransfer(upload)
+		if err != nil {
+			return fmt.Errorf("invalid upload: %w", err)
 		}
+		t.Upload = transfer
+	}
+	if download, ok := config["download"]; ok {
+		transfer, err := parseSSHTransfer(download)
+		if err != nil {
+			return fmt.Errorf("invalid download: %w", err)
+		}
+		t.Download = transfer
 	}
 	
 	// Timeout (default: 60s)
@@ -91,119 +243,341 @@ func (t *SSHTask) Configure(config map[string]interface{}) error {
 		t.Timeout = 60 * time.Second
 	}
 	
+	// Output limit per stream (default: 1MB)
+	maxOutput, err := parseMaxOutput(config)
+	if err != nil {
+		return err
+	}
+	t.MaxOutput = maxOutput
+	
 	return nil
 }
 
 // Execute performs the SSH operation
 func (t *SSHTask) Execute(ctx context.Context) (interface{}, error) {
-	// Create SSH client config
-	config := &ssh.ClientConfig{
-		User:            t.User,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // WARNING: insecure, use proper host key verification in production
+	hostKeyCallback, err := t.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	
+	auth, closeAuth, err := t.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
+	
+	config := ssh.ClientConfig{
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         t.Timeout,
 	}
 	
-	// Add authentication method
-	if t.Key != "" {
-		key, err := os.ReadFile(t.Key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read private key: %w", err)
-		}
-		
-		signer, err := ssh.ParsePrivateKey(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse private key: %w", err)
+	if len(t.Hosts) > 0 {
+		return t.fanOut(ctx, config)
+	}
+	return t.run(ctx, t.targets[0], config, newProcessOutput(ctx, t.MaxOutput))
+}
+
+// fanOut runs the task on every host, up to MaxParallel at a time. Every host
+// is reported in order with its result or error, and the task fails when any
+// host failed.
+func (t *SSHTask) fanOut(ctx context.Context, config ssh.ClientConfig) (interface{}, error) {
+	results := make([]map[string]interface{}, len(t.targets))
+	sem := make(chan struct{}, t.MaxParallel)
+	var wg sync.WaitGroup
+	for i, target := range t.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			out := newPrefixedOutput(ctx, t.MaxOutput, target.name+": ")
+			result, err := t.run(ctx, target, config, out)
+			if result == nil {
+				result = make(map[string]interface{})
+			}
+			result["host"] = target.name
+			if err != nil {
+				result["error"] = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for _, result := range results {
+		if _, ok := result["error"]; ok {
+			failed = append(failed, result["host"].(string))
 		}
-		
-		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
-	} else if t.Password != "" {
-		config.Auth = []ssh.AuthMethod{ssh.Password(t.Password)}
 	}
-	
-	// Connect to SSH server
-	addr := fmt.Sprintf("%s:%d", t.Host, t.Port)
-	client, err := ssh.Dial("tcp", addr, config)
+	output := map[string]interface{}{
+		"hosts":  results,
+		"failed": failed,
+	}
+	if len(failed) > 0 {
+		return output, fmt.Errorf("%d of %d hosts failed: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return output, nil
+}
+
+// run connects to a host, uploads files, runs the command and downloads files
+func (t *SSHTask) run(ctx context.Context, target sshTarget, config ssh.ClientConfig, out *processOutput) (map[string]interface{}, error) {
+	client, closeClient, err := t.connect(ctx, target, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+		return nil, err
 	}
-	defer client.Close()
+	defer closeClient()
 	
 	result := make(map[string]interface{})
 	
 	// Handle file upload if specified
 	if t.Upload != nil {
-		if err := t.uploadFile(client); err != nil {
-			return nil, fmt.Errorf("file upload failed: %w", err)
+		upload, err := sftpUpload(client, t.Upload)
+		result["upload"] = upload
+		if err != nil {
+			return result, fmt.Errorf("file upload failed: %w", err)
 		}
-		result["upload"] = "success"
 	}
 	
 	// Execute command if specified
 	if t.Command != "" {
-		output, err := t.executeCommand(client)
+		output, err := t.executeCommand(ctx, client, out)
+		for k, v := range output {
+			result[k] = v
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	
+	// Handle file download if specified; with several hosts every host has
+	// its own directory
+	if t.Download != nil {
+		local := t.Download.Local
+		if len(t.Hosts) > 0 {
+			local = filepath.Join(local, hostDirName(target.name))
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return result, fmt.Errorf("file download failed: %w", err)
+			}
+		}
+		download, err := sftpDownload(client, t.Download, local)
+		result["download"] = download
 		if err != nil {
-			return nil, fmt.Errorf("command execution failed: %w", err)
+			return result, fmt.Errorf("file download failed: %w", err)
 		}
-		result["output"] = output
 	}
 	
 	return result, nil
 }
 
-func (t *SSHTask) executeCommand(client *ssh.Client) (string, error) {
-	session, err := client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
+// hostDirName turns a host of the hosts list into a directory name
+func hostDirName(host string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(host)
+}
+
+// connect opens a client to target through the jump hosts. The returned
+// function closes the client and the connections to the jump hosts.
+func (t *SSHTask) connect(ctx context.Context, target sshTarget, config ssh.ClientConfig) (*ssh.Client, func(), error) {
+	var clients []*ssh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
 	}
-	defer session.Close()
-	
-	output, err := session.CombinedOutput(t.Command)
-	if err != nil {
-		return string(output), fmt.Errorf("command failed: %w", err)
+
+	hops := append(append([]sshTarget{}, t.jumps...), target)
+	for i, hop := range hops {
+		var conn net.Conn
+		var err error
+		if i == 0 {
+			dialer := net.Dialer{Timeout: t.Timeout}
+			conn, err = dialer.DialContext(ctx, "tcp", hop.addr)
+		} else {
+			conn, err = clients[i-1].DialContext(ctx, "tcp", hop.addr)
+		}
+
+		var client *ssh.Client
+		if err == nil {
+			client, err = t.handshake(conn, hop, config)
+		}
+		if err != nil {
+			closeAll()
+			if i < len(hops)-1 {
+				return nil, nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.name, err)
+			}
+			return nil, nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+		}
+		clients = append(clients, client)
 	}
-	
-	return string(output), nil
+	return clients[len(clients)-1], closeAll, nil
 }
 
-func (t *SSHTask) uploadFile(client *ssh.Client) error {
-	// Open local file
-	localFile, err := os.Open(t.Upload.Local)
+// handshake sets up an SSH client over conn, within the task timeout
+func (t *SSHTask) handshake(conn net.Conn, hop sshTarget, config ssh.ClientConfig) (*ssh.Client, error) {
+	config.User = hop.user
+	conn.SetDeadline(time.Now().Add(t.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, hop.addr, &config)
 	if err != nil {
-		return fmt.Errorf("failed to open local file: %w", err)
+		conn.Close()
+		return nil, err
 	}
-	defer localFile.Close()
-	
-	// Get file info
-	fileInfo, err := localFile.Stat()
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// hostKeyCallback returns the host key verification of the task
+func (t *SSHTask) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if t.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if len(t.HostKeyFingerprints) > 0 {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if containsString(t.HostKeyFingerprints, fingerprint) {
+				return nil
+			}
+			return fmt.Errorf("host key %s of %s does not match host_key_fingerprint", fingerprint, hostname)
+		}, nil
+	}
+
+	files := t.KnownHosts
+	if len(files) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find known_hosts: %w", err)
+		}
+		files = []string{filepath.Join(home, ".ssh", "known_hosts")}
+	}
+	callback, err := knownhosts.New(files...)
 	if err != nil {
-		return fmt.Errorf("failed to stat local file: %w", err)
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
 	}
-	
-	// Create session
+	return callback, nil
+}
+
+// authMethods returns the configured authentication methods, tried in the
+// order key, ssh-agent, password. The returned function closes the connection
+// to the agent.
+func (t *SSHTask) authMethods() ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+
+	if t.Key != "" {
+		key, err := os.ReadFile(t.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+
+		var signer ssh.Signer
+		if t.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(t.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, nil, fmt.Errorf("private key is encrypted, passphrase is required")
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	closeAgent := func() {}
+	if t.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, nil, fmt.Errorf("ssh_agent requires SSH_AUTH_SOCK to be set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		closeAgent = func() { conn.Close() }
+	}
+
+	if t.Password != "" {
+		methods = append(methods, ssh.Password(t.Password))
+	}
+	return methods, closeAgent, nil
+}
+
+// parseSSHTarget parses a host given as [user@]host[:port]
+func parseSSHTarget(s, user string, port int) (sshTarget, error) {
+	target := sshTarget{name: s, user: user}
+	host := s
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		target.user, host = host[:i], host[i+1:]
+	}
+	portStr := strconv.Itoa(port)
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, portStr = h, p
+	}
+	if host == "" {
+		return target, fmt.Errorf("%q has no host", s)
+	}
+	if target.user == "" {
+		return target, fmt.Errorf("%q has no user, and user is not set", s)
+	}
+	target.addr = net.JoinHostPort(host, portStr)
+	return target, nil
+}
+
+// parseStringList reads a string or a list of strings
+func parseStringList(v interface{}) ([]string, error) {
+	switch x := v.(type) {
+	case string:
+		return []string{x}, nil
+	case []interface{}:
+		list := make([]string, len(x))
+		for i, item := range x {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a string", item)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("must be a string or a list of strings")
+}
+
+// executeCommand runs the command in a new session, streaming its stdout and
+// stderr while capturing them separately. The session is closed when ctx is done.
+func (t *SSHTask) executeCommand(ctx context.Context, client *ssh.Client, out *processOutput) (map[string]interface{}, error) {
 	session, err := client.NewSession()
 	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 	
-	// Create stdin pipe
-	stdin, err := session.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
+	session.Stdout = out.Stdout
+	session.Stderr = out.Stderr
 	
-	// Start SCP command
-	go func() {
-		defer stdin.Close()
-		fmt.Fprintf(stdin, "C0644 %d %s\n", fileInfo.Size(), fileInfo.Name())
-		io.Copy(stdin, localFile)
-		fmt.Fprint(stdin, "\x00")
-	}()
+	stop := context.AfterFunc(ctx, func() {
+		session.Close()
+	})
+	defer stop()
 	
-	// Run SCP command
-	if err := session.Run(fmt.Sprintf("scp -t %s", t.Upload.Remote)); err != nil {
-		return fmt.Errorf("scp command failed: %w", err)
+	exitCode := 0
+	if err := session.Run(t.Command); err != nil {
+		exitError, ok := err.(*ssh.ExitError)
+		if !ok {
+			if ctx.Err() != nil {
+				return out.result(-1), ctx.Err()
+			}
+			return out.result(-1), fmt.Errorf("command failed: %w", err)
+		}
+		exitCode = exitError.ExitStatus()
 	}
 	
-	return nil
+	result := out.result(exitCode)
+	if exitCode != 0 {
+		return result, fmt.Errorf("command exited with code %d", exitCode)
+	}
+	return result, nil
 }
@@ -0,0 +1,299 @@
+package probe
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSSHServer runs a minimal SSH server accepting deploy/secret. A command
+// is echoed to stdout, except "fail N" which writes to stderr and exits with
+// N. Forwarded connections are supported, so the server can be a jump host,
+// and the sftp subsystem serves the local filesystem.
+func startSSHServer(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "deploy" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("access denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config)
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go serveSSHSession(channel, requests)
+		case "direct-tcpip":
+			var target struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			ssh.Unmarshal(newChannel.ExtraData(), &target)
+			remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				remote.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				io.Copy(channel, remote)
+				channel.Close()
+			}()
+			go func() {
+				io.Copy(remote, channel)
+				remote.Close()
+			}()
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func serveSSHSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type == "subsystem" {
+			var subsystem struct{ Name string }
+			ssh.Unmarshal(req.Payload, &subsystem)
+			if subsystem.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(channel)
+			if err == nil {
+				server.Serve()
+				server.Close()
+			}
+			return
+		}
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		ssh.Unmarshal(req.Payload, &exec)
+		req.Reply(true, nil)
+
+		status := 0
+		if code, ok := strings.CutPrefix(exec.Command, "fail "); ok {
+			fmt.Fprintln(channel.Stderr(), "failing")
+			status, _ = strconv.Atoi(code)
+		} else {
+			fmt.Fprintln(channel, exec.Command)
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+// writeKnownHosts writes a known_hosts file for the given addresses and keys
+func writeKnownHosts(t *testing.T, entries map[string]ssh.PublicKey) string {
+	t.Helper()
+	var lines []string
+	for addr, key := range entries {
+		lines = append(lines, knownhosts.Line([]string{addr}, key))
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runSSHTask configures and runs an ssh task logging in as deploy
+func runSSHTask(t *testing.T, config map[string]interface{}) (interface{}, error) {
+	t.Helper()
+	config["user"] = "deploy"
+	config["password"] = "secret"
+	config["timeout"] = "5s"
+	task := &SSHTask{}
+	if err := task.Configure(config); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	return task.Execute(context.Background())
+}
+
+func TestSSHTaskHostKeys(t *testing.T) {
+	addr, key := startSSHServer(t)
+	_, otherKey := startSSHServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{"known_hosts", map[string]interface{}{"known_hosts": writeKnownHosts(t, map[string]ssh.PublicKey{addr: key})}, ""},
+		{"known_hosts mismatch", map[string]interface{}{"known_hosts": writeKnownHosts(t, map[string]ssh.PublicKey{addr: otherKey})}, "key mismatch"},
+		{"unknown host", map[string]interface{}{"known_hosts": writeKnownHosts(t, map[string]ssh.PublicKey{"10.0.0.1:22": key})}, "key is unknown"},
+		{"fingerprint", map[string]interface{}{"host_key_fingerprint": []interface{}{"SHA256:other", ssh.FingerprintSHA256(key)}}, ""},
+		{"fingerprint mismatch", map[string]interface{}{"host_key_fingerprint": ssh.FingerprintSHA256(otherKey)}, "does not match host_key_fingerprint"},
+		{"insecure", map[string]interface{}{"insecure_ignore_host_key": true}, ""},
+		{"default known_hosts", map[string]interface{}{}, "failed to load known_hosts"},
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	for _, tt := range tests {
+		tt.config["host"] = host
+		tt.config["port"] = portNum
+		tt.config["command"] = "hostname"
+		result, err := runSSHTask(t, tt.config)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			} else if stdout := result.(map[string]interface{})["stdout"]; stdout != "hostname\n" {
+				t.Errorf("%s: expected command output, got %q", tt.name, stdout)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestSSHTaskExitStatus(t *testing.T) {
+	addr, _ := startSSHServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	result, err := runSSHTask(t, map[string]interface{}{
+		"host":                     host,
+		"port":                     portNum,
+		"command":                  "fail 3",
+		"insecure_ignore_host_key": true,
+	})
+	if err == nil || err.Error() != "command exited with code 3" {
+		t.Errorf("Expected exit code error, got %v", err)
+	}
+	output := result.(map[string]interface{})
+	if output["exit_code"] != 3 || output["stderr"] != "failing\n" || output["stdout"] != "" {
+		t.Errorf("Expected exit code and stderr in output, got %v", output)
+	}
+}
+
+func TestSSHTaskFanOutAndJump(t *testing.T) {
+	addr1, key1 := startSSHServer(t)
+	addr2, key2 := startSSHServer(t)
+	knownHosts := writeKnownHosts(t, map[string]ssh.PublicKey{addr1: key1, addr2: key2})
+
+	p := New()
+	events := &eventLog{}
+	p.RegisterObserver(events)
+
+	yaml := fmt.Sprintf(`
+name: test-ssh
+tasks:
+  - name: fanout
+    type: ssh
+    continue_on_error: true
+    config:
+      hosts: [%[1]q, %[2]q, "nobody@%[1]s"]
+      max_parallel: 2
+      user: deploy
+      password: secret
+      known_hosts: %[3]q
+      command: uptime
+  - name: jump
+    type: ssh
+    config:
+      hosts: [%[2]q]
+      jump: ["deploy@%[1]s"]
+      user: deploy
+      password: secret
+      known_hosts: %[3]q
+      command: uptime
+`, addr1, addr2, knownHosts)
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+
+	fanout := result.Tasks[0]
+	if fanout.Error == "" || !strings.Contains(fanout.Error, "1 of 3 hosts failed: nobody@"+addr1) {
+		t.Errorf("Expected one failed host, got %q", fanout.Error)
+	}
+	output := fanout.Output.(map[string]interface{})
+	hosts := output["hosts"].([]map[string]interface{})
+	var names []interface{}
+	for _, h := range hosts {
+		names = append(names, h["host"])
+	}
+	if want := []interface{}{addr1, addr2, "nobody@" + addr1}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected hosts in order %v, got %v", want, names)
+	}
+	if hosts[1]["stdout"] != "uptime\n" || hosts[1]["exit_code"] != 0 {
+		t.Errorf("Expected command output per host, got %v", hosts[1])
+	}
+	if !strings.Contains(toString(hosts[2]["error"]), "unable to authenticate") {
+		t.Errorf("Expected authentication error, got %v", hosts[2])
+	}
+	if !containsString(events.events, "output fanout stdout: "+addr2+": uptime") {
+		t.Errorf("Expected output lines prefixed with the host, got %v", events.events)
+	}
+
+	if result.Tasks[1].Status != StatusSuccess {
+		t.Errorf("Expected command through jump host to succeed, got %s", result.Tasks[1].Error)
+	}
+}
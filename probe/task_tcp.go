@@ -0,0 +1,168 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultTCPReadBytes bounds the data read from a TCP service
+const defaultTCPReadBytes = 64 << 10
+
+// TCPTask checks that a TCP port accepts connections, without relying on
+// ICMP. It can read the banner the service greets with, send a request and
+// expect a response matching a pattern.
+type TCPTask struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+
+	// Banner reads the greeting the service sends on connect
+	Banner bool
+
+	// Send is written after connecting; Expect must match the data received
+	Send     string
+	Expect   *regexp.Regexp
+	MaxBytes int
+}
+
+// Describe documents the TCP task configuration
+func (t *TCPTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Connects to a TCP port, optionally exchanging data with the service",
+		Fields: []FieldSpec{
+			{Name: "host", Type: FieldString, Required: true, Description: "Host name or address"},
+			{Name: "port", Type: FieldInt, Required: true, Description: "TCP port"},
+			{Name: "timeout", Type: FieldDuration, Default: "5s", Description: "Timeout of the whole check"},
+			{Name: "banner", Type: FieldBool, Default: false, Description: "Read the banner the service sends on connect"},
+			{Name: "send", Type: FieldString, Description: "Data sent after connecting"},
+			{Name: "expect", Type: FieldString, Description: "Regular expression the data received must match"},
+			{Name: "max_bytes", Type: FieldAny, Default: "64KB", Description: "Most data read, in bytes or with a KB/MB/GB suffix"},
+		},
+	}
+}
+
+// Configure sets up the TCP task
+func (t *TCPTask) Configure(config map[string]interface{}) error {
+	host, ok := config["host"].(string)
+	if !ok || host == "" {
+		return fmt.Errorf("host is required")
+	}
+	t.Host = host
+
+	port, ok := toInt(config["port"])
+	if !ok || port < 1 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	t.Port = port
+
+	// Timeout (default: 5s)
+	t.Timeout = 5 * time.Second
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	t.Banner, _ = config["banner"].(bool)
+	t.Send, _ = config["send"].(string)
+	if expect, ok := config["expect"].(string); ok {
+		re, err := regexp.Compile(expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %w", err)
+		}
+		t.Expect = re
+	}
+
+	// Read limit (default: 64KB)
+	t.MaxBytes = defaultTCPReadBytes
+	if v, ok := config["max_bytes"]; ok {
+		size, err := parseSize(v)
+		if err != nil {
+			return fmt.Errorf("invalid max_bytes: %w", err)
+		}
+		if size <= 0 {
+			return fmt.Errorf("invalid max_bytes: must be positive")
+		}
+		t.MaxBytes = size
+	}
+
+	return nil
+}
+
+// Execute connects and exchanges data with the service
+func (t *TCPTask) Execute(ctx context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	defer conn.Close()
+
+	output := map[string]interface{}{
+		"address":    address,
+		"remote":     conn.RemoteAddr().String(),
+		"connect_ms": time.Since(start).Milliseconds(),
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	// The banner is whatever the service sends before it is spoken to
+	if t.Banner {
+		buf := make([]byte, t.MaxBytes)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return output, fmt.Errorf("failed to read banner: %w", err)
+		}
+		output["banner"] = string(buf[:n])
+	}
+
+	if t.Send != "" {
+		if _, err := conn.Write([]byte(t.Send)); err != nil {
+			return output, fmt.Errorf("failed to send: %w", err)
+		}
+	}
+
+	if t.Expect != nil {
+		response, err := t.readUntilMatch(conn)
+		output["response"] = string(response)
+		if err != nil {
+			return output, err
+		}
+	}
+
+	return output, nil
+}
+
+// readUntilMatch reads until the data received matches Expect, the service
+// closes the connection, MaxBytes are read or the deadline passes
+func (t *TCPTask) readUntilMatch(conn net.Conn) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, 4096)
+	for len(data) < t.MaxBytes {
+		n, err := conn.Read(buf[:min(len(buf), t.MaxBytes-len(data))])
+		data = append(data, buf[:n]...)
+		if t.Expect.Match(data) {
+			return data, nil
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return data, fmt.Errorf("response does not match %s before the timeout", t.Expect)
+			}
+			return data, fmt.Errorf("response does not match %s: %w", t.Expect, err)
+		}
+	}
+	return data, fmt.Errorf("response does not match %s within %d bytes", t.Expect, t.MaxBytes)
+}
@@ -0,0 +1,92 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startTCPServer runs a line based service that greets with a banner and
+// answers PING with PONG
+func startTCPServer(t *testing.T) (string, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("220 test ready\r\n"))
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if scanner.Text() == "PING" {
+						conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestTCPTask(t *testing.T) {
+	host, port := startTCPServer(t)
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{"connect", map[string]interface{}{}, nil, ""},
+		{"banner", map[string]interface{}{"banner": true}, map[string]interface{}{"banner": "220 test ready\r\n"}, ""},
+		{"send expect", map[string]interface{}{"banner": true, "send": "PING\r\n", "expect": `^\+PONG`}, map[string]interface{}{"response": "+PONG\r\n"}, ""},
+		{"no match", map[string]interface{}{"send": "PING\r\n", "expect": "OK", "timeout": "200ms"}, nil, "does not match OK before the timeout"},
+	}
+
+	for _, tt := range tests {
+		tt.config["host"] = host
+		tt.config["port"] = port
+		task := &TCPTask{}
+		if err := task.Configure(tt.config); err != nil {
+			t.Fatalf("%s: Configure failed: %v", tt.name, err)
+		}
+		result, err := task.Execute(context.Background())
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		output := result.(map[string]interface{})
+		for k, v := range tt.want {
+			if output[k] != v {
+				t.Errorf("%s: expected %s %q, got %q", tt.name, k, v, output[k])
+			}
+		}
+	}
+
+	// A closed port fails to connect
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	task := &TCPTask{}
+	task.Configure(map[string]interface{}{"host": "127.0.0.1", "port": closedPort})
+	if _, err := task.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "connection failed") {
+		t.Errorf("Expected connection error, got %v", err)
+	}
+}
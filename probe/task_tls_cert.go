@@ -0,0 +1,171 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+// TLSCertTask connects to a TLS service and reports its certificate. It fails
+// when the certificate is not trusted or expires within MinDaysRemaining.
+type TLSCertTask struct {
+	Host       string
+	Port       int
+	ServerName string
+	Timeout    time.Duration
+
+	// MinDaysRemaining is the least number of days the certificate must
+	// remain valid
+	MinDaysRemaining int
+
+	// Verify checks the chain and host name against the system roots, or
+	// CACert when set
+	Verify bool
+	CACert string
+}
+
+// Describe documents the TLS certificate task configuration
+func (t *TLSCertTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Checks the certificate of a TLS service",
+		Fields: []FieldSpec{
+			{Name: "host", Type: FieldString, Required: true, Description: "Host name or address"},
+			{Name: "port", Type: FieldInt, Default: 443, Description: "TCP port"},
+			{Name: "server_name", Type: FieldString, Description: "Server name sent with SNI and verified; the host by default"},
+			{Name: "timeout", Type: FieldDuration, Default: "10s", Description: "Handshake timeout"},
+			{Name: "min_days_remaining", Type: FieldInt, Default: 0, Description: "Fail when the certificate expires within this many days"},
+			{Name: "verify", Type: FieldBool, Default: true, Description: "Fail when the certificate is not trusted for the server name"},
+			{Name: "ca_cert", Type: FieldString, Description: "PEM file of the CA certificates to trust instead of the system roots"},
+		},
+	}
+}
+
+// Configure sets up the TLS certificate task
+func (t *TLSCertTask) Configure(config map[string]interface{}) error {
+	host, ok := config["host"].(string)
+	if !ok || host == "" {
+		return fmt.Errorf("host is required")
+	}
+	t.Host = host
+
+	// Port (default: 443)
+	t.Port = 443
+	if v, ok := config["port"]; ok {
+		port, ok := toInt(v)
+		if !ok || port < 1 || port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+		t.Port = port
+	}
+
+	t.ServerName, _ = config["server_name"].(string)
+	if t.ServerName == "" {
+		t.ServerName = host
+	}
+
+	// Timeout (default: 10s)
+	t.Timeout = 10 * time.Second
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	if v, ok := config["min_days_remaining"]; ok {
+		days, ok := toInt(v)
+		if !ok || days < 0 {
+			return fmt.Errorf("min_days_remaining must be a non-negative integer")
+		}
+		t.MinDaysRemaining = days
+	}
+
+	// Verify (default: true)
+	t.Verify = true
+	if verify, ok := config["verify"].(bool); ok {
+		t.Verify = verify
+	}
+	t.CACert, _ = config["ca_cert"].(string)
+
+	return nil
+}
+
+// Execute performs the handshake and checks the certificate. The certificate
+// is reported even when the checks fail.
+func (t *TLSCertTask) Execute(ctx context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var roots *x509.CertPool
+	if t.CACert != "" {
+		pool, err := loadCertPool(t.CACert)
+		if err != nil {
+			return nil, err
+		}
+		roots = pool
+	}
+
+	// Verification is done after the handshake, so that untrusted
+	// certificates can still be reported
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.Host, strconv.Itoa(t.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server sent no certificate")
+	}
+	cert := state.PeerCertificates[0]
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	daysRemaining := int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+
+	output := map[string]interface{}{
+		"subject":        cert.Subject.String(),
+		"issuer":         cert.Issuer.String(),
+		"sans":           sans,
+		"serial":         cert.SerialNumber.String(),
+		"not_before":     cert.NotBefore.UTC().Format(time.RFC3339),
+		"not_after":      cert.NotAfter.UTC().Format(time.RFC3339),
+		"days_remaining": daysRemaining,
+		"tls_version":    tls.VersionName(state.Version),
+	}
+
+	var verifyErr error
+	if t.Verify {
+		intermediates := x509.NewCertPool()
+		for _, c := range state.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		_, verifyErr = cert.Verify(x509.VerifyOptions{
+			DNSName:       t.ServerName,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		output["verified"] = verifyErr == nil
+	}
+
+	if verifyErr != nil {
+		return output, fmt.Errorf("certificate is not trusted: %w", verifyErr)
+	}
+	if daysRemaining < t.MinDaysRemaining {
+		return output, fmt.Errorf("certificate expires in %d days, less than min_days_remaining of %d", daysRemaining, t.MinDaysRemaining)
+	}
+	return output, nil
+}
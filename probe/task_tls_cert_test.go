@@ -0,0 +1,62 @@
+package probe
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTLSCertTask(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{"trusted", map[string]interface{}{"ca_cert": caCert, "server_name": "example.com", "min_days_remaining": 30}, ""},
+		{"untrusted", map[string]interface{}{}, "certificate is not trusted"},
+		{"wrong name", map[string]interface{}{"ca_cert": caCert, "server_name": "other.org"}, "certificate is not trusted"},
+		{"unverified", map[string]interface{}{"verify": false}, ""},
+		{"expiring", map[string]interface{}{"verify": false, "min_days_remaining": 100000}, "less than min_days_remaining of 100000"},
+	}
+
+	for _, tt := range tests {
+		tt.config["host"] = host
+		tt.config["port"] = port
+		task := &TLSCertTask{}
+		if err := task.Configure(tt.config); err != nil {
+			t.Fatalf("%s: Configure failed: %v", tt.name, err)
+		}
+		result, err := task.Execute(context.Background())
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.wantErr, err)
+		}
+
+		// The certificate is reported whether or not the checks pass
+		output := result.(map[string]interface{})
+		if !containsString(output["sans"].([]string), "example.com") || !strings.Contains(output["issuer"].(string), "Acme Co") {
+			t.Errorf("%s: expected certificate details, got %v", tt.name, output)
+		}
+		if days := output["days_remaining"].(int); days < 365 {
+			t.Errorf("%s: expected the test certificate to be valid for years, got %d days", tt.name, days)
+		}
+	}
+}
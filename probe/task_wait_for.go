@@ -0,0 +1,276 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// waitConditions are the conditions the wait_for task can wait for
+var waitConditions = []string{"port", "file", "process", "http", "db", "task"}
+
+// waitCondition is checked by the wait_for task until it holds. Check returns
+// nil when it does, or an error saying why it does not yet.
+type waitCondition interface {
+	check(ctx context.Context) (interface{}, error)
+}
+
+// WaitForTask polls a condition until it holds or the timeout passes, in place
+// of fixed sleeps between deployment steps. The condition is a port accepting
+// connections, a file existing or matching a pattern, a running process, or
+// an http, db or any other task succeeding.
+type WaitForTask struct {
+	Condition string
+	Timeout   time.Duration
+	Interval  time.Duration
+
+	condition waitCondition
+	probe     *Probe
+}
+
+// Describe documents the wait_for task configuration
+func (t *WaitForTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Waits until a port, file, process, HTTP or database condition holds",
+		Fields: []FieldSpec{
+			{Name: "port", Type: FieldMap, Fields: (&TCPTask{}).Describe().Fields, Description: "Wait until a TCP port accepts connections; takes the tcp task configuration"},
+			{Name: "file", Type: FieldMap, Fields: []FieldSpec{
+				{Name: "path", Type: FieldString, Required: true, Description: "Path of the file"},
+				{Name: "contains", Type: FieldString, Description: "Regular expression the file content must match"},
+			}, Description: "Wait until a file exists, optionally with matching content"},
+			{Name: "process", Type: FieldMap, Fields: []FieldSpec{
+				{Name: "name", Type: FieldString, Required: true, Description: "Executable name, such as nginx or w3wp.exe"},
+			}, Description: "Wait until a process is running"},
+			{Name: "http", Type: FieldMap, Fields: (&HTTPTask{}).Describe().Fields, Description: "Wait until a request succeeds; takes the http task configuration"},
+			{Name: "db", Type: FieldMap, Fields: (&DBTask{}).Describe().Fields, Description: "Wait until a query returns rows, or meets its expectations; takes the db task configuration"},
+			{Name: "task", Type: FieldMap, Fields: []FieldSpec{
+				{Name: "type", Type: FieldString, Required: true, Description: "Task type"},
+				{Name: "config", Type: FieldMap, Description: "Task configuration"},
+			}, Description: "Wait until a task of any registered type succeeds"},
+			{Name: "timeout", Type: FieldDuration, Default: "5m", Description: "How long to wait for the condition"},
+			{Name: "interval", Type: FieldDuration, Default: "2s", Description: "Time between checks"},
+		},
+	}
+}
+
+// Configure sets up the wait_for task
+func (t *WaitForTask) Configure(config map[string]interface{}) error {
+	var given []string
+	for _, name := range waitConditions {
+		if _, ok := config[name]; ok {
+			given = append(given, name)
+		}
+	}
+	if len(given) != 1 {
+		return fmt.Errorf("exactly one of %s is required", strings.Join(waitConditions, ", "))
+	}
+	t.Condition = given[0]
+
+	conditionConfig, ok := config[t.Condition].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be a map", t.Condition)
+	}
+	condition, err := t.newCondition(conditionConfig)
+	if err != nil {
+		return fmt.Errorf("invalid %s condition: %w", t.Condition, err)
+	}
+	t.condition = condition
+
+	// Timeout (default: 5m)
+	t.Timeout = 5 * time.Minute
+	if timeoutStr, ok := config["timeout"].(string); ok {
+		duration, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.Timeout = duration
+	}
+
+	// Interval (default: 2s)
+	t.Interval = 2 * time.Second
+	if intervalStr, ok := config["interval"].(string); ok {
+		duration, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid interval: %w", err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		t.Interval = duration
+	}
+
+	return nil
+}
+
+// newCondition creates the condition of the configured kind
+func (t *WaitForTask) newCondition(config map[string]interface{}) (waitCondition, error) {
+	switch t.Condition {
+	case "file":
+		path, ok := config["path"].(string)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		condition := &fileCondition{path: path}
+		if contains, ok := config["contains"].(string); ok {
+			re, err := regexp.Compile(contains)
+			if err != nil {
+				return nil, fmt.Errorf("invalid contains: %w", err)
+			}
+			condition.contains = re
+		}
+		return condition, nil
+
+	case "process":
+		name, ok := config["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+		return &processCondition{name: name}, nil
+
+	case "port":
+		task := &TCPTask{}
+		if err := task.Configure(config); err != nil {
+			return nil, err
+		}
+		return &taskCondition{task: task}, nil
+
+	case "http":
+		task := &HTTPTask{}
+		if err := task.Configure(config); err != nil {
+			return nil, err
+		}
+		return &taskCondition{task: task}, nil
+
+	case "db":
+		task := &DBTask{}
+		if err := task.Configure(config); err != nil {
+			return nil, err
+		}
+		// Without expectations, a query must return a row
+		requireRows := task.ExpectCount == nil && task.ExpectRows == nil && !task.Exec && task.Transaction == nil
+		return &taskCondition{task: task, requireRows: requireRows}, nil
+	}
+
+	taskType, ok := config["type"].(string)
+	if !ok || taskType == "" {
+		return nil, fmt.Errorf("type is required")
+	}
+	if t.probe == nil {
+		return nil, fmt.Errorf("task conditions need a probe to create the task")
+	}
+	factory, ok := t.probe.tasks[taskType]
+	if !ok {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+	taskConfig, _ := config["config"].(map[string]interface{})
+	if taskConfig == nil {
+		taskConfig = map[string]interface{}{}
+	}
+	task := factory()
+	if err := task.Configure(taskConfig); err != nil {
+		return nil, err
+	}
+	return &taskCondition{task: task}, nil
+}
+
+// Execute checks the condition every interval until it holds, and reports how
+// long it waited
+func (t *WaitForTask) Execute(ctx context.Context) (interface{}, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	attempts := 0
+	var lastErr error
+	for {
+		attempts++
+		result, err := t.condition.check(ctx)
+		output := map[string]interface{}{
+			"condition": t.Condition,
+			"met":       err == nil,
+			"attempts":  attempts,
+			"waited_ms": time.Since(start).Milliseconds(),
+		}
+		if err == nil {
+			output["result"] = result
+			return output, nil
+		}
+		// A check cut short by the timeout says less than the previous one
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			output["waited_ms"] = time.Since(start).Milliseconds()
+			return output, fmt.Errorf("%s condition not met within %s: %w", t.Condition, t.Timeout, lastErr)
+		case <-time.After(t.Interval):
+		}
+	}
+}
+
+// taskCondition holds when its task succeeds
+type taskCondition struct {
+	task Task
+
+	// requireRows also requires the task to return at least one row
+	requireRows bool
+}
+
+func (c *taskCondition) check(ctx context.Context) (interface{}, error) {
+	result, err := c.task.Execute(ctx)
+	if err != nil {
+		return result, err
+	}
+	if c.requireRows {
+		if output, ok := result.(map[string]interface{}); ok && output["count"] == 0 {
+			return result, fmt.Errorf("query returned no rows")
+		}
+	}
+	return result, nil
+}
+
+// fileCondition holds when a file exists, and its content matches contains
+type fileCondition struct {
+	path     string
+	contains *regexp.Regexp
+}
+
+func (c *fileCondition) check(ctx context.Context) (interface{}, error) {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"path": c.path, "size": info.Size()}
+	if c.contains == nil {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if !c.contains.Match(data) {
+		return nil, fmt.Errorf("%s does not match %s", c.path, c.contains)
+	}
+	return result, nil
+}
+
+// processCondition holds when a process with the given name is running
+type processCondition struct {
+	name string
+}
+
+func (c *processCondition) check(ctx context.Context) (interface{}, error) {
+	running, err := processRunning(ctx, c.name)
+	if err != nil {
+		return nil, err
+	}
+	if !running {
+		return nil, fmt.Errorf("process %s is not running", c.name)
+	}
+	return map[string]interface{}{"name": c.name}, nil
+}
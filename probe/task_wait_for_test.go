@@ -0,0 +1,177 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runWaitForTask configures and runs a wait_for task checking every 20ms
+func runWaitForTask(t *testing.T, config map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	config["interval"] = "20ms"
+	task := &WaitForTask{probe: New()}
+	if err := task.Configure(config); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	result, err := task.Execute(context.Background())
+	output, _ := result.(map[string]interface{})
+	return output, err
+}
+
+func TestWaitForTaskFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("starting\n"), 0644)
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("starting\nlistening on :8080\n"), 0644)
+	}()
+
+	output, err := runWaitForTask(t, map[string]interface{}{
+		"file":    map[string]interface{}{"path": path, "contains": `listening on :\d+`},
+		"timeout": "5s",
+	})
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if output["met"] != true || output["attempts"].(int) < 2 || output["waited_ms"].(int64) < 100 {
+		t.Errorf("Expected to wait for the content, got %v", output)
+	}
+}
+
+func TestWaitForTaskTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	output, err := runWaitForTask(t, map[string]interface{}{
+		"port":    map[string]interface{}{"host": "127.0.0.1", "port": port},
+		"timeout": "200ms",
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "port condition not met within 200ms: ") || strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Expected timeout with the last connection error, got %v", err)
+	}
+	if output["met"] != false || output["attempts"].(int) < 2 {
+		t.Errorf("Expected several failed attempts, got %v", output)
+	}
+}
+
+func TestWaitForTaskDB(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	runDBTask(t, dsn, map[string]interface{}{"query": "CREATE TABLE migrations (version INTEGER)", "exec": true})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		insert := &DBTask{Driver: "sqlite", DSN: dsn, Query: "INSERT INTO migrations VALUES (42)", Exec: true, Timeout: time.Second}
+		insert.Execute(context.Background())
+	}()
+
+	output, err := runWaitForTask(t, map[string]interface{}{
+		"db": map[string]interface{}{
+			"driver": "sqlite",
+			"dsn":    dsn,
+			"query":  "SELECT version FROM migrations WHERE version >= 42",
+		},
+		"timeout": "5s",
+	})
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if output["attempts"].(int) < 2 || output["result"].(map[string]interface{})["count"] != 1 {
+		t.Errorf("Expected to wait for a row, got %v", output)
+	}
+}
+
+func TestWaitForTaskProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test binaries are matched on the .exe name")
+	}
+	if _, err := runWaitForTask(t, map[string]interface{}{
+		"process": map[string]interface{}{"name": filepath.Base(os.Args[0])},
+	}); err != nil {
+		t.Errorf("Expected the test process to be found: %v", err)
+	}
+
+	_, err := runWaitForTask(t, map[string]interface{}{
+		"process": map[string]interface{}{"name": "no-such-process-for-probe"},
+		"timeout": "50ms",
+	})
+	if err == nil || !strings.Contains(err.Error(), "process no-such-process-for-probe is not running") {
+		t.Errorf("Expected process error, got %v", err)
+	}
+}
+
+func TestWaitForTaskWorkflow(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	yaml := fmt.Sprintf(`
+name: test-wait
+tasks:
+  - name: wait-api
+    type: wait_for
+    config:
+      interval: 20ms
+      timeout: 5s
+      http:
+        url: %q
+        assert:
+          json: {"$.status": "ok"}
+  - name: wait-port
+    type: wait_for
+    depends_on: [wait-api]
+    config:
+      interval: 20ms
+      task:
+        type: tcp
+        config: {host: 127.0.0.1, port: %s}
+`, server.URL, server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	result, err := New().ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	for _, task := range result.Tasks {
+		if task.Status != StatusSuccess {
+			t.Errorf("%s failed: %s", task.Name, task.Error)
+		}
+	}
+	if attempts := result.Tasks[0].Output.(map[string]interface{})["attempts"]; attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %v", attempts)
+	}
+}
+
+func TestWaitForTaskConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{},
+		{"file": map[string]interface{}{"path": "a"}, "process": map[string]interface{}{"name": "b"}},
+		{"file": "ready"},
+		{"file": map[string]interface{}{"path": "a", "contains": "("}},
+		{"http": map[string]interface{}{}},
+		{"task": map[string]interface{}{"type": "missing"}},
+		{"process": map[string]interface{}{"name": "b"}, "interval": "0s"},
+	} {
+		if err := (&WaitForTask{probe: New()}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+}
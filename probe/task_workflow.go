@@ -0,0 +1,146 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxWorkflowDepth bounds the nesting of workflow tasks, catching workflows
+// that include themselves
+const maxWorkflowDepth = 10
+
+type workflowDepthKey struct{}
+
+// RegisterWorkflow adds a workflow to the library of workflows that workflow
+// tasks can run by name
+func (p *Probe) RegisterWorkflow(name string, workflow *Workflow) {
+	p.workflows[name] = workflow
+}
+
+// LoadWorkflows registers every .yaml and .yml file of a directory in the
+// workflow library, named after the file without its extension
+func (p *Probe) LoadWorkflows(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read workflow library: %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		workflow, err := loadWorkflowFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		p.RegisterWorkflow(strings.TrimSuffix(entry.Name(), ext), workflow)
+	}
+	return nil
+}
+
+// loadWorkflowFile reads and parses a workflow file
+func loadWorkflowFile(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow: %w", err)
+	}
+	workflow, err := ParseWorkflow(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return workflow, nil
+}
+
+// nestedWorkflow is implemented by tasks running a sub-workflow, whose result
+// is nested in the task result
+type nestedWorkflow interface {
+	nestedResult() *WorkflowResult
+}
+
+// WorkflowTask runs another workflow as a single step, either a workflow of
+// the library by name or a workflow file. Inputs override the variables of the
+// sub-workflow, and its outputs become the output of the task.
+type WorkflowTask struct {
+	Workflow string
+	Path     string
+	Inputs   map[string]interface{}
+
+	probe  *Probe
+	result *WorkflowResult
+}
+
+// Describe documents the workflow task configuration
+func (t *WorkflowTask) Describe() TaskSpec {
+	return TaskSpec{
+		Description: "Runs another workflow as a nested step",
+		Fields: []FieldSpec{
+			{Name: "workflow", Type: FieldString, Description: "Name of a workflow in the library (set workflow or path)"},
+			{Name: "path", Type: FieldString, Description: "Path of a workflow file (set workflow or path)"},
+			{Name: "inputs", Type: FieldMap, Description: "Values overriding the vars of the sub-workflow"},
+		},
+	}
+}
+
+// Configure sets up the workflow task
+func (t *WorkflowTask) Configure(config map[string]interface{}) error {
+	t.Workflow, _ = config["workflow"].(string)
+	t.Path, _ = config["path"].(string)
+	if (t.Workflow == "") == (t.Path == "") {
+		return fmt.Errorf("exactly one of workflow and path is required")
+	}
+
+	if inputs, ok := config["inputs"]; ok {
+		m, ok := inputs.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("inputs must be a map")
+		}
+		t.Inputs = m
+	}
+
+	return nil
+}
+
+// Execute runs the sub-workflow. The library and files are resolved only now,
+// as they are local to the host running the workflow.
+func (t *WorkflowTask) Execute(ctx context.Context) (interface{}, error) {
+	depth, _ := ctx.Value(workflowDepthKey{}).(int)
+	if depth >= maxWorkflowDepth {
+		return nil, fmt.Errorf("workflows nested more than %d levels deep", maxWorkflowDepth)
+	}
+	ctx = context.WithValue(ctx, workflowDepthKey{}, depth+1)
+
+	var workflow *Workflow
+	if t.Workflow != "" {
+		var ok bool
+		workflow, ok = t.probe.workflows[t.Workflow]
+		if !ok {
+			return nil, fmt.Errorf("unknown workflow: %s", t.Workflow)
+		}
+	} else {
+		var err error
+		workflow, err = loadWorkflowFile(t.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := t.probe.run(ctx, workflow, t.Inputs)
+	t.result = result
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s: %w", workflow.Name, err)
+	}
+
+	outputs := result.Outputs
+	if outputs == nil {
+		outputs = map[string]interface{}{}
+	}
+	return outputs, nil
+}
+
+// nestedResult returns the result of the last run of the sub-workflow
+func (t *WorkflowTask) nestedResult() *WorkflowResult {
+	return t.result
+}
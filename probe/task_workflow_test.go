@@ -0,0 +1,160 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// valueTask returns its configured value as output, or fails with it
+type valueTask struct {
+	value interface{}
+	fail  bool
+}
+
+func (t *valueTask) Configure(config map[string]interface{}) error {
+	t.value = config["value"]
+	t.fail, _ = config["fail"].(bool)
+	return nil
+}
+
+func (t *valueTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.fail {
+		return nil, fmt.Errorf("failed with %v", t.value)
+	}
+	return t.value, nil
+}
+
+const deployWorkflow = `
+name: deploy
+vars:
+  node: default-node
+  version: "1.0"
+tasks:
+  - name: drain
+    type: value
+    config: {value: "drained {{ vars.node }}"}
+  - name: install
+    type: value
+    depends_on: [drain]
+    config: {value: "{{ vars.version }}", fail: "{{ vars.version == 'bad' }}"}
+outputs:
+  installed: "{{ tasks.install.output }}"
+  node: "{{ vars.node }}"
+`
+
+func TestWorkflowTaskLibrary(t *testing.T) {
+	library, err := ParseWorkflow([]byte(deployWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	p.RegisterTask("value", func() Task { return &valueTask{} })
+	p.RegisterWorkflow("deploy", library)
+
+	yaml := `
+name: rollout
+tasks:
+  - name: deploy
+    type: workflow
+    loop: [web-1, web-2]
+    config:
+      workflow: deploy
+      inputs: {node: "{{ item }}", version: "2.0"}
+  - name: report
+    type: value
+    depends_on: [deploy]
+    config: {value: "{{ tasks.deploy.output.1.node }}@{{ tasks.deploy.output.1.installed }}"}
+`
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+
+	if got := result.Tasks[2].Output; got != "web-2@2.0" {
+		t.Errorf("Expected outputs mapped back to the parent, got %v", got)
+	}
+
+	nested := result.Tasks[0].Workflow
+	if nested == nil || nested.Name != "deploy" || len(nested.Tasks) != 2 {
+		t.Fatalf("Expected nested result, got %+v", nested)
+	}
+	if nested.Tasks[0].Output != "drained web-1" {
+		t.Errorf("Expected input to override vars, got %v", nested.Tasks[0].Output)
+	}
+}
+
+func TestWorkflowTaskFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deploy.yaml")
+	if err := os.WriteFile(path, []byte(deployWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	p.RegisterTask("value", func() Task { return &valueTask{} })
+
+	yaml := fmt.Sprintf(`
+name: rollout
+tasks:
+  - name: deploy
+    type: workflow
+    config:
+      path: %q
+      inputs: {version: bad}
+`, path)
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err == nil || !strings.Contains(err.Error(), "workflow deploy: task 1 (install): failed with bad") {
+		t.Fatalf("Expected nested failure, got %v", err)
+	}
+	r := result.Tasks[0]
+	if r.Status != StatusFailed || r.Workflow == nil || r.Workflow.Success || r.Workflow.Outputs != nil {
+		t.Errorf("Expected failed nested result without outputs, got %+v", r.Workflow)
+	}
+
+	// Library directories are loaded by file name
+	if err := p.LoadWorkflows(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.workflows["deploy"]; !ok {
+		t.Errorf("Expected deploy to be loaded from %s", dir)
+	}
+}
+
+func TestWorkflowTaskRecursion(t *testing.T) {
+	p := New()
+	workflow, err := ParseWorkflow([]byte(`
+name: loop
+tasks:
+  - name: again
+    type: workflow
+    config: {workflow: loop}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RegisterWorkflow("loop", workflow)
+
+	_, err = p.Execute(context.Background(), workflow)
+	if err == nil || !strings.Contains(err.Error(), "nested more than 10 levels") {
+		t.Errorf("Expected nesting limit error, got %v", err)
+	}
+}
+
+func TestWorkflowTaskConfigure(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{},
+		{"workflow": "a", "path": "b.yaml"},
+		{"workflow": "a", "inputs": "x"},
+	} {
+		if err := (&WorkflowTask{}).Configure(config); err == nil {
+			t.Errorf("Expected error for %v", config)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package probe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// endRawPattern closes a {{ raw }} block
+var endRawPattern = regexp.MustCompile(`\{\{\s*endraw\s*\}\}`)
+
+// renderConfig resolves {{ ... }} templates in every string of a task configuration.
+// The original configuration is left untouched.
+func renderConfig(config map[string]interface{}, s *scope) (map[string]interface{}, error) {
+	rendered, err := renderValue(config, s)
+	if err != nil {
+		return nil, err
+	}
+	if rendered == nil {
+		return nil, nil
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+// renderValue resolves templates in strings nested inside maps and lists
+func renderValue(v interface{}, s *scope) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return renderString(x, s)
+	case map[string]interface{}:
+		if x == nil {
+			return x, nil
+		}
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			rendered, err := renderValue(item, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			rendered, err := renderValue(item, s)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// renderString evaluates the templates in a string. A string consisting of a single
+// template keeps the native type of its value, so `{{ tasks.count.output.count }}`
+// yields a number; otherwise values are formatted and concatenated. Text between
+// {{ raw }} and {{ endraw }} is copied as is, and {{ "{{" }} yields a literal {{.
+func renderString(str string, s *scope) (interface{}, error) {
+	if !strings.Contains(str, "{{") {
+		return str, nil
+	}
+
+	var sb strings.Builder
+	rest := str
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		end := findTemplateEnd(rest, start+2)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated template in %q", str)
+		}
+
+		if strings.TrimSpace(rest[start+2:end]) == "raw" {
+			loc := endRawPattern.FindStringIndex(rest[end+2:])
+			if loc == nil {
+				return nil, fmt.Errorf("raw block without endraw in %q", str)
+			}
+			sb.WriteString(rest[:start])
+			sb.WriteString(rest[end+2 : end+2+loc[0]])
+			rest = rest[end+2+loc[1]:]
+			continue
+		}
+
+		value, err := evalExpr(rest[start+2:end], s)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", strings.TrimSpace(rest[start+2:end]), err)
+		}
+
+		// Whole-string template: return the value itself
+		if start == 0 && end+2 == len(rest) && sb.Len() == 0 {
+			return value, nil
+		}
+
+		sb.WriteString(rest[:start])
+		sb.WriteString(toString(value))
+		rest = rest[end+2:]
+	}
+
+	return sb.String(), nil
+}
+
+// findTemplateEnd returns the index of the closing "}}", skipping quoted strings
+func findTemplateEnd(str string, from int) int {
+	var quote byte
+	for i := from; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}' && i+1 < len(str) && str[i+1] == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// hasTemplate reports whether a configuration value contains any template
+func hasTemplate(v interface{}) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.Contains(x, "{{")
+	case map[string]interface{}:
+		for _, item := range x {
+			if hasTemplate(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range x {
+			if hasTemplate(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
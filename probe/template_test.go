@@ -0,0 +1,172 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	s := &scope{
+		values: map[string]interface{}{
+			"vars": map[string]interface{}{
+				"region": "eu",
+				"hosts":  []interface{}{"a", "b"},
+				"count":  3,
+			},
+			"tasks": map[string]interface{}{
+				"login": map[string]interface{}{
+					"output": map[string]interface{}{
+						"status_code": 200,
+						"body":        `{"token": "abc", "user": {"id": 7}}`,
+					},
+					"success": true,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{`vars.region`, "eu"},
+		{`vars.hosts.1`, "b"},
+		{`vars.hosts[0]`, "a"},
+		{`vars["region"] | upper`, "EU"},
+		{`tasks.login.output.body | json "token"`, "abc"},
+		{`tasks.login.output.body | json "user.id"`, float64(7)},
+		{`tasks.login.output.status_code == 200`, true},
+		{`tasks.login.output.status_code == "200"`, true},
+		{`vars.count >= 3 and vars.region != "us"`, true},
+		{`not tasks.login.success or vars.count < 1`, false},
+		{`vars.region in ["eu", "us"]`, true},
+		{`vars.missing | default "fallback"`, "fallback"},
+		{`tasks.other.output.body`, nil},
+		{`len vars.hosts`, 2},
+		{`tasks.login.output.body | contains "token"`, true},
+	}
+
+	for _, tt := range tests {
+		got, err := evalExpr(tt.expr, s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", tt.expr, tt.want, tt.want, got, got)
+		}
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	s := &scope{values: map[string]interface{}{"vars": map[string]interface{}{}}}
+
+	for _, expr := range []string{
+		`undefined.value`,
+		`vars.a ==`,
+		`vars.a | nosuchfunc`,
+		`"unterminated`,
+	} {
+		if _, err := evalExpr(expr, s); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
+
+func TestRenderString(t *testing.T) {
+	os.Setenv("PROBE_TEST_HOST", "example.com")
+	defer os.Unsetenv("PROBE_TEST_HOST")
+
+	s := &scope{
+		values: map[string]interface{}{
+			"vars": map[string]interface{}{"port": 8080},
+			"env":  envScope{},
+		},
+	}
+
+	got, err := renderString("https://{{ env.PROBE_TEST_HOST }}:{{ vars.port }}/health", s)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got != "https://example.com:8080/health" {
+		t.Errorf("unexpected render result: %v", got)
+	}
+
+	// A whole-string template keeps the native type
+	got, err = renderString("{{ vars.port }}", s)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got != 8080 {
+		t.Errorf("expected int 8080, got %v (%T)", got, got)
+	}
+
+	if _, err := renderString("{{ vars.port", s); err == nil {
+		t.Errorf("expected error for unterminated template")
+	}
+
+	// Literal braces are escaped with a string literal or a raw block
+	for src, want := range map[string]string{
+		`docker ps --format '{{ "{{" }}.Names}}'`:                           "docker ps --format '{{.Names}}'",
+		`{{ raw }}{{ .Values.image }}:{{ vars.port }}{{ endraw }}`:          "{{ .Values.image }}:{{ vars.port }}",
+		"port {{ vars.port }}\n{{raw}}{{ item }}{{endraw}} {{ vars.port }}": "port 8080\n{{ item }} 8080",
+	} {
+		got, err := renderString(src, s)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %q, got %q %v", src, want, got, err)
+		}
+	}
+	if _, err := renderString("{{ raw }}{{ .Names }}", s); err == nil {
+		t.Errorf("expected error for raw block without endraw")
+	}
+}
+
+func TestProbeTaskOutputTemplating(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte(`{"token": "secret-token"}`))
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer secret-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	p := New()
+
+	yaml := fmt.Sprintf(`
+name: test-templating
+vars:
+  base_url: %s
+tasks:
+  - name: login
+    type: http
+    config:
+      url: "{{ vars.base_url }}/login"
+      method: POST
+  - name: orders
+    type: http
+    depends_on: [login]
+    config:
+      url: "{{ vars.base_url }}/orders"
+      headers:
+        Authorization: "Bearer {{ tasks.login.output.body | json \"token\" }}"
+`, server.URL)
+
+	result, err := p.ExecuteYAML(context.Background(), []byte(yaml))
+	if err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	if !result.Success || len(result.Tasks) != 2 {
+		t.Errorf("Expected 2 successful tasks, got %+v", result.Tasks)
+	}
+}
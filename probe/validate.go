@@ -0,0 +1,210 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem found in a workflow definition
+type ValidationError struct {
+	// Line is the line in the workflow YAML, or 0 when unknown
+	Line int
+
+	// Task identifies the task, e.g. "task 2 (deploy)"; empty for workflow settings
+	Task string
+
+	// Field is the offending key, e.g. "config.url"
+	Field string
+
+	Message string
+}
+
+// Error formats the problem with its location
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Task != "" {
+		b.WriteString(e.Task + ": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationErrors lists every problem found in a workflow
+type ValidationErrors []*ValidationError
+
+// Error joins the problems, one per line
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// validator collects validation errors
+type validator struct {
+	errs ValidationErrors
+}
+
+// add records a problem; node locates it in the YAML and may be nil
+func (v *validator) add(node *yaml.Node, task, field string, err error) {
+	line := 0
+	if node != nil {
+		line = node.Line
+	}
+	v.errs = append(v.errs, &ValidationError{
+		Line:    line,
+		Task:    task,
+		Field:   field,
+		Message: err.Error(),
+	})
+}
+
+// err returns the collected problems in line order, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Line < v.errs[j].Line
+	})
+	return v.errs
+}
+
+// unknownKeys reports keys of a YAML mapping that do not match a yaml tag of
+// the given struct type
+func (v *validator) unknownKeys(node *yaml.Node, typ reflect.Type, task string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	known := make(map[string]bool, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			v.add(key, task, key.Value, fmt.Errorf("unknown key"))
+		}
+	}
+}
+
+// keyNode returns the value node at the given key path of a YAML mapping,
+// or the deepest node found along the path
+func keyNode(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return node
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+// UnmarshalYAML decodes the workflow and keeps its YAML node for error locations
+func (w *Workflow) UnmarshalYAML(node *yaml.Node) error {
+	type plain Workflow
+	if err := node.Decode((*plain)(w)); err != nil {
+		return err
+	}
+	w.node = node
+	return nil
+}
+
+// UnmarshalYAML decodes the task and keeps its YAML node for error locations
+func (t *TaskDefinition) UnmarshalYAML(node *yaml.Node) error {
+	type plain TaskDefinition
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.node = node
+	return nil
+}
+
+// Validate checks a workflow without executing it. Besides the checks done by
+// ParseWorkflow, it flags unknown keys and task types, checks task configs
+// against the Describe spec of their task type and configures every task whose
+// config contains no templates. All problems are returned at once as
+// ValidationErrors, with YAML line numbers when the workflow was parsed from YAML.
+func (p *Probe) Validate(workflow *Workflow) error {
+	v := &validator{}
+	v.unknownKeys(workflow.node, reflect.TypeOf(Workflow{}), "")
+	workflow.check(v)
+
+	for i, taskDef := range workflow.Tasks {
+		p.checkTask(v, fmt.Sprintf("task %d (%s)", i, taskDef.Name), taskDef)
+	}
+	for i, taskDef := range workflow.Finally {
+		p.checkTask(v, fmt.Sprintf("finally task %d (%s)", i, taskDef.Name), taskDef)
+	}
+
+	return v.err()
+}
+
+// ValidateYAML parses a YAML workflow and validates it like Validate. YAML
+// syntax errors are returned as a single ValidationError.
+func (p *Probe) ValidateYAML(yamlData []byte) error {
+	var workflow Workflow
+	if err := yaml.Unmarshal(yamlData, &workflow); err != nil {
+		return ValidationErrors{{Message: err.Error()}}
+	}
+	return p.Validate(&workflow)
+}
+
+// checkTask checks the keys, type and config of a task definition
+func (p *Probe) checkTask(v *validator, label string, taskDef TaskDefinition) {
+	v.unknownKeys(taskDef.node, reflect.TypeOf(TaskDefinition{}), label)
+
+	factory, ok := p.tasks[taskDef.Type]
+	if !ok {
+		v.add(keyNode(taskDef.node, "type"), label, "type", fmt.Errorf("unknown task type: %s", taskDef.Type))
+		return
+	}
+	task := factory()
+
+	canConfigure := true
+	if describer, ok := task.(Describer); ok {
+		spec := describer.Describe()
+		for _, cerr := range checkConfig(spec.Fields, taskDef.Config, nil) {
+			path := append([]string{"config"}, cerr.path...)
+			v.add(keyNode(taskDef.node, path...), label, strings.Join(path, "."), errors.New(cerr.message))
+			canConfigure = false
+		}
+		if !spec.supports(runtime.GOOS) {
+			canConfigure = false
+		}
+	}
+
+	// Templated values are only known at run time
+	if !canConfigure || hasTemplate(taskDef.Config) {
+		return
+	}
+	if err := task.Configure(taskDef.Config); err != nil {
+		v.add(keyNode(taskDef.node, "config"), label, "config", err)
+	}
+}
@@ -0,0 +1,183 @@
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	p := New()
+
+	yaml := `
+name: test-validate
+timout: 5m
+tasks:
+  - name: health
+    type: http
+    config:
+      urll: https://example.com
+      expected_status: [200, ok]
+      timeout: soon
+  - name: deploy
+    type: deploy
+    dependson: [health]
+  - name: cleanup
+    type: command
+    when: agent.os ==
+    config:
+      command: rm
+      args: [-rf, 1]
+  - name: query
+    type: db
+    config: {driver: mssql, dsn: x, query: SELECT 1}
+`
+
+	err := p.ValidateYAML([]byte(yaml))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	want := []struct {
+		line  int
+		field string
+	}{
+		{3, "timout"},
+		{8, "config.url"},
+		{9, "config.expected_status"},
+		{10, "config.timeout"},
+		{8, "config.urll"},
+		{13, "dependson"},
+		{12, "type"},
+		{14, ""},
+		{19, "config.args"},
+		{22, "config.driver"},
+	}
+
+	for _, w := range want {
+		found := false
+		for _, e := range errs {
+			if e.Line == w.line && e.Field == w.field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error at line %d for %q, got:\n%v", w.line, w.field, err)
+		}
+	}
+}
+
+func TestValidateConfigure(t *testing.T) {
+	p := New()
+
+	// Configure catches rules the spec cannot express
+	yaml := `
+name: test-validate-configure
+tasks:
+  - name: login
+    type: ssh
+    config: {host: example.com, user: deploy}
+`
+	err := p.ValidateYAML([]byte(yaml))
+	if err == nil || !strings.Contains(err.Error(), "line 6: task 0 (login): config: one of key, password or ssh_agent is required") {
+		t.Errorf("Expected configure error with line number, got %v", err)
+	}
+
+	// Templated configs are only checked against the spec
+	yaml = `
+name: test-validate-templates
+vars:
+  codes: [200, 204]
+tasks:
+  - name: health
+    type: http
+    loop: [a, b]
+    config:
+      url: "https://{{ item }}.example.com"
+      expected_status: "{{ vars.codes }}"
+`
+	if err := p.ValidateYAML([]byte(yaml)); err != nil {
+		t.Errorf("Expected templated workflow to be valid, got %v", err)
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	p := New()
+
+	files, _ := filepath.Glob("examples/*.yaml")
+	if len(files) == 0 {
+		t.Fatal("No example workflows found")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.ValidateYAML(data); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	p := New()
+	p.RegisterTask("record", func() Task { return &recordTask{} })
+
+	data, err := json.Marshal(p.Schema())
+	if err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	var schema struct {
+		Defs struct {
+			Task struct {
+				Properties struct {
+					Type struct {
+						Enum []string `json:"enum"`
+					} `json:"type"`
+				} `json:"properties"`
+				AllOf []struct {
+					Then struct {
+						Properties struct {
+							Config struct {
+								Required   []string               `json:"required"`
+								Properties map[string]interface{} `json:"properties"`
+							} `json:"config"`
+						} `json:"properties"`
+					} `json:"then"`
+				} `json:"allOf"`
+			} `json:"task"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	types := strings.Join(schema.Defs.Task.Properties.Type.Enum, ",")
+	if types != "command,db,dns,downloadexec,facts,file,http,powershell,record,script,service,ssh,tcp,tls_cert,wait_for,workflow" {
+		t.Errorf("Unexpected task types: %s", types)
+	}
+
+	// One condition per built-in task; record does not describe itself
+	if len(schema.Defs.Task.AllOf) != 15 {
+		t.Fatalf("Expected 15 config schemas, got %d", len(schema.Defs.Task.AllOf))
+	}
+	found := false
+	for _, cond := range schema.Defs.Task.AllOf {
+		config := cond.Then.Properties.Config
+		if _, ok := config.Properties["expected_status"]; ok {
+			found = true
+			if strings.Join(config.Required, ",") != "url" {
+				t.Errorf("Expected url to be required, got %v", config.Required)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("HTTP config schema not found")
+	}
+}